@@ -0,0 +1,105 @@
+package paylio
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// ErrCircuitOpen is the cause of the APIConnectionError returned while the
+// circuit breaker configured with WithCircuitBreaker is open. Check for it
+// with errors.Is.
+var ErrCircuitOpen = errors.New("circuit open")
+
+// circuitBreaker stops sending requests after consecutive failures. It opens
+// after threshold failures in a row, rejects requests until cooldown has
+// elapsed, and then half-opens to let a single probe through: a successful
+// probe closes it and a failed one opens it again.
+type circuitBreaker struct {
+	threshold int
+	cooldown  time.Duration
+
+	mu       sync.Mutex
+	failures int
+	open     bool
+	openedAt time.Time
+	probing  bool
+	// generation counts transitions between open and closed, so results of
+	// requests allowed before a transition can be told apart and ignored.
+	generation int
+}
+
+// breakerTicket identifies a request let through by circuitBreaker.allow.
+type breakerTicket struct {
+	generation int
+	probe      bool
+}
+
+func newCircuitBreaker(threshold int, cooldown time.Duration) *circuitBreaker {
+	return &circuitBreaker{threshold: threshold, cooldown: cooldown}
+}
+
+// allow reports whether a request may be sent at now and, if so, returns the
+// ticket to record its outcome with. Once the cooldown has elapsed, only one
+// request at a time, the probe, is let through until it is recorded.
+func (b *circuitBreaker) allow(now time.Time) (breakerTicket, bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if !b.open {
+		return breakerTicket{generation: b.generation}, true
+	}
+	if b.probing || now.Sub(b.openedAt) < b.cooldown {
+		return breakerTicket{}, false
+	}
+	b.probing = true
+	return breakerTicket{generation: b.generation, probe: true}, true
+}
+
+// record updates the breaker with the outcome of the request allowed with t.
+// Only connection failures, including the client's own timeout, and 5xx
+// responses count as failures; a request canceled or timed out by its
+// caller's context, or never sent, says nothing about the API's health.
+// While half-open only the probe's outcome counts, and results of requests
+// allowed before the circuit last opened or closed are ignored.
+func (b *circuitBreaker) record(now time.Time, t breakerTicket, err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if t.probe {
+		b.probing = false
+	}
+	if t.generation != b.generation || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) || isUnsent(err) {
+		return
+	}
+	if !isServerFailure(err) {
+		b.failures = 0
+		if t.probe {
+			b.open = false
+			b.generation++
+		}
+		return
+	}
+	b.failures++
+	if t.probe || b.failures >= b.threshold {
+		b.open, b.openedAt = true, now
+		b.generation++
+	}
+}
+
+// isServerFailure reports whether err shows the API unavailable or failing:
+// a network failure or a 5xx response.
+func isServerFailure(err error) bool {
+	if isNetworkFailure(err) {
+		return true
+	}
+	var pe *PaylioError
+	return errors.As(err, &pe) && pe.HTTPStatus >= 500
+}
+
+// newCircuitOpenError returns the error for a request rejected by an open
+// circuit breaker.
+func newCircuitOpenError() error {
+	err := NewAPIConnectionError(ErrorParams{Message: "circuit open"})
+	err.cause = ErrCircuitOpen
+	return err
+}
@@ -0,0 +1,212 @@
+package paylio
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestCircuitBreakerTransitions(t *testing.T) {
+	var status atomic.Int32
+	status.Store(503)
+	var hits atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		hits.Add(1)
+		w.WriteHeader(int(status.Load()))
+		_, _ = w.Write([]byte(`{"id":"sub_1","error":{"message":"unavailable"}}`))
+	}))
+	defer srv.Close()
+
+	fc := newFakeClock()
+	client, err := NewClient("sk_test", WithBaseURL(srv.URL), withClock(fc), WithCircuitBreaker(2, time.Minute))
+	if err != nil {
+		t.Fatal(err)
+	}
+	retrieve := func() error {
+		_, err := client.Subscription.Retrieve(context.Background(), "user_1")
+		return err
+	}
+	var apiErr *APIError
+	var connErr *APIConnectionError
+
+	// Closed: failures reach the API until the threshold opens the circuit.
+	for i := 0; i < 2; i++ {
+		if err := retrieve(); !errors.As(err, &apiErr) {
+			t.Fatalf("failure %d: expected *APIError, got %T: %v", i+1, err, err)
+		}
+	}
+	// Open: requests fail fast without reaching the API.
+	if err := retrieve(); !errors.As(err, &connErr) || !errors.Is(err, ErrCircuitOpen) || connErr.Message != "circuit open" {
+		t.Fatalf("open: err = %v", err)
+	}
+	if n := hits.Load(); n != 2 {
+		t.Errorf("hits while open = %d, want 2", n)
+	}
+
+	// Half-open: after the cooldown one probe goes through; failing it
+	// reopens the circuit.
+	fc.After(time.Minute)
+	if err := retrieve(); !errors.As(err, &apiErr) {
+		t.Fatalf("failed probe: expected *APIError, got %T: %v", err, err)
+	}
+	if err := retrieve(); !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("after failed probe: err = %v, want circuit open", err)
+	}
+
+	// A successful probe closes the circuit.
+	fc.After(time.Minute)
+	status.Store(200)
+	for i := 0; i < 3; i++ {
+		if err := retrieve(); err != nil {
+			t.Fatalf("closed request %d: %v", i+1, err)
+		}
+	}
+	if n := hits.Load(); n != 6 {
+		t.Errorf("hits = %d, want 6", n)
+	}
+}
+
+func TestCircuitBreakerAllowsOneProbe(t *testing.T) {
+	start := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	refused := newConnectionError(errors.New("connection refused"))
+	b := newCircuitBreaker(1, time.Second)
+	ticket, _ := b.allow(start)
+	b.record(start, ticket, refused)
+	if _, ok := b.allow(start); ok {
+		t.Fatal("allow should be false while open")
+	}
+	later := start.Add(time.Second)
+	probe, ok := b.allow(later)
+	if !ok || !probe.probe {
+		t.Fatal("allow should let a probe through after the cooldown")
+	}
+	if _, ok := b.allow(later); ok {
+		t.Error("allow should reject a second request while the probe is in flight")
+	}
+	// A canceled probe leaves the circuit half-open for the next request.
+	canceled := NewAPIConnectionError(ErrorParams{Message: "canceled"})
+	canceled.cause = context.Canceled
+	b.record(later, probe, canceled)
+	probe, ok = b.allow(later)
+	if !ok {
+		t.Error("allow should let a new probe through after a canceled one")
+	}
+	// So does a probe that was never sent.
+	unsent := NewAPIConnectionError(ErrorParams{Message: "before-request hook failed"})
+	unsent.unsent = true
+	b.record(later, probe, unsent)
+	probe, ok = b.allow(later)
+	if !ok {
+		t.Error("allow should let a new probe through after an unsent one")
+	}
+	b.record(later, probe, NewNotFoundError(ErrorParams{HTTPStatus: 404}))
+	if _, ok := b.allow(later); !ok || b.open {
+		t.Error("a non-server error should close the circuit")
+	}
+}
+
+func TestCircuitBreakerCountsOnlyClientTimeouts(t *testing.T) {
+	start := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	timedOut := func(cause error) error {
+		err := NewAPIConnectionError(ErrorParams{Message: "Request timed out"})
+		err.cause = cause
+		return err
+	}
+	b := newCircuitBreaker(1, time.Second)
+	// The caller's own deadline says nothing about the API.
+	ticket, _ := b.allow(start)
+	b.record(start, ticket, timedOut(context.DeadlineExceeded))
+	if _, ok := b.allow(start); !ok {
+		t.Fatal("a caller deadline should not open the circuit")
+	}
+	// The client timeout does.
+	ticket, _ = b.allow(start)
+	b.record(start, ticket, timedOut(os.ErrDeadlineExceeded))
+	if _, ok := b.allow(start); ok {
+		t.Error("a client timeout should open the circuit")
+	}
+}
+
+func TestCircuitBreakerIgnoresLateResults(t *testing.T) {
+	start := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	refused := newConnectionError(errors.New("connection refused"))
+	b := newCircuitBreaker(1, time.Second)
+	early, _ := b.allow(start)
+	stale, _ := b.allow(start)
+	b.record(start, early, refused)
+
+	// A request allowed before the circuit opened does not close it.
+	b.record(start, stale, nil)
+	if !b.open {
+		t.Fatal("a late success should not close the open circuit")
+	}
+
+	// Once half-open, only the probe decides; a late failure neither
+	// reopens the circuit nor frees the probe slot.
+	later := start.Add(time.Second)
+	probe, _ := b.allow(later)
+	b.record(later, stale, refused)
+	if _, ok := b.allow(later); ok {
+		t.Fatal("a late failure should not free the probe slot")
+	}
+	b.record(later, probe, nil)
+	if b.open {
+		t.Fatal("a successful probe should close the circuit")
+	}
+
+	// A request allowed while the circuit was open before does not reopen
+	// it after the probe closed it.
+	b.record(later, early, refused)
+	if b.open || b.failures != 0 {
+		t.Errorf("a late failure counted after closing: open = %v, failures = %d", b.open, b.failures)
+	}
+}
+
+func TestCircuitBreakerDoesNotRecordUnsentRequests(t *testing.T) {
+	var hits atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		hits.Add(1)
+		w.WriteHeader(503)
+		_, _ = w.Write([]byte(`{"error":{"message":"unavailable"}}`))
+	}))
+	defer srv.Close()
+
+	fc := newFakeClock()
+	client, err := NewClient("sk_test", WithBaseURL(srv.URL), withClock(fc), WithCircuitBreaker(1, time.Minute))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.Subscription.Retrieve(context.Background(), "user_1"); errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("first request: err = %v", err)
+	}
+
+	// A probe rejected before it is sent neither closes the circuit nor
+	// uses up the half-open probe.
+	fc.After(time.Minute)
+	var authErr *AuthenticationError
+	if _, err := client.Subscription.Retrieve(context.Background(), "user_1", WithAPIKey("")); !errors.As(err, &authErr) {
+		t.Fatalf("empty API key: expected *AuthenticationError, got %T: %v", err, err)
+	}
+	var apiErr *APIError
+	if _, err := client.Subscription.Retrieve(context.Background(), "user_1"); !errors.As(err, &apiErr) {
+		t.Fatalf("probe: expected *APIError, got %T: %v", err, err)
+	}
+	if _, err := client.Subscription.Retrieve(context.Background(), "user_1"); !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("after failed probe: err = %v, want circuit open", err)
+	}
+	if n := hits.Load(); n != 2 {
+		t.Errorf("hits = %d, want 2", n)
+	}
+}
+
+func TestCircuitOpenErrorIsNotRetried(t *testing.T) {
+	hc := newHTTPClient("sk_test", "http://localhost", 0, &http.Client{})
+	if hc.shouldRetry("GET", nil, newCircuitOpenError()) {
+		t.Error("circuit open errors should not be retried")
+	}
+}
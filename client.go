@@ -1,15 +1,36 @@
 package paylio
 
 import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io"
 	"net/http"
+	"net/url"
+	"strings"
 	"time"
 )
 
-// Client is the entry point for the Paylio SDK.
+// Client is the entry point for the Paylio SDK. A Client is safe for
+// concurrent use by multiple goroutines; per-client state such as the last
+// reported rate limit is guarded internally. Reset is the one exception.
 type Client struct {
 	// Subscription provides access to subscription operations.
 	Subscription *SubscriptionService
 
+	// Refunds provides access to refund operations.
+	Refunds *RefundService
+
+	// Invoices provides access to invoice operations.
+	Invoices *InvoiceService
+
+	// Disputes provides access to dispute operations.
+	Disputes *DisputeService
+
+	// Usage provides access to metered usage reporting.
+	Usage *UsageService
+
 	hc *httpClient
 }
 
@@ -17,28 +38,374 @@ type Client struct {
 type Option func(*clientConfig)
 
 type clientConfig struct {
-	baseURL    string
-	timeout    time.Duration
-	httpClient *http.Client
+	baseURL          string
+	baseURLSet       bool
+	environment      string
+	failoverBaseURL  string
+	pathPrefix       string
+	timeout          time.Duration
+	maxRetries       int
+	maxRetryDelay    time.Duration
+	maxPageSize      int
+	httpClient       *http.Client
+	clock            clock
+	batchConcurrency int
+	beforeRequest    func(*http.Request) error
+	defaultMetadata  map[string]string
+	defaultHeaders   http.Header
+	requestID        func() string
+	requestDump      io.Writer
+	onBackoff        func(wait time.Duration, attempt int)
+	logger           Logger
+	strict           bool
+	coalesce         bool
+	apiKeyQueryParam string
+	keyRefresher     func(context.Context) (string, error)
+	compress         bool
+	retryPolicy      RetryPolicy
+	redactFields     []string
+	breakerThreshold int
+	retryableCodes   []string
+	breakerCooldown  time.Duration
+	proxyURL         string
+	// transportOptions configure the default transport. They are ignored
+	// when a custom http.Client is supplied.
+	transportOptions []func(*http.Transport)
 }
 
-// WithBaseURL sets a custom base URL for API requests.
+// WithBaseURL sets a custom base URL for API requests. It takes precedence
+// over WithEnvironment.
 func WithBaseURL(url string) Option {
-	return func(c *clientConfig) { c.baseURL = url }
+	return func(c *clientConfig) {
+		c.baseURL = url
+		c.baseURLSet = true
+	}
+}
+
+// WithPathPrefix replaces the path of the base URL with prefix, such as
+// "/paylio/v1", so that requests go to the base URL's host followed by
+// prefix, for proxies that mount the API under their own path. Leading and
+// trailing slashes are optional. The prefix also applies to
+// WithFailoverBaseURL.
+//
+//	// Requests go to https://gateway.example.com/paylio/v1/subscription/...
+//	paylio.WithBaseURL("https://gateway.example.com/flying/v1")
+//	paylio.WithPathPrefix("paylio/v1/")
+func WithPathPrefix(prefix string) Option {
+	return func(c *clientConfig) { c.pathPrefix = prefix }
+}
+
+// Named environments accepted by WithEnvironment.
+const (
+	EnvironmentProduction = "production"
+	EnvironmentSandbox    = "sandbox"
+	EnvironmentLocal      = "local"
+)
+
+// environmentBaseURLs maps each named environment to its base URL.
+var environmentBaseURLs = map[string]string{
+	EnvironmentProduction: DefaultBaseURL,
+	EnvironmentSandbox:    "https://sandbox.api.paylio.pro/flying/v1",
+	EnvironmentLocal:      "http://localhost:8000/flying/v1",
+}
+
+// WithEnvironment selects the base URL of a named environment: one of
+// EnvironmentProduction, EnvironmentSandbox, or EnvironmentLocal. NewClient
+// returns an InvalidRequestError for any other name. WithBaseURL overrides it.
+func WithEnvironment(env string) Option {
+	return func(c *clientConfig) { c.environment = env }
+}
+
+// WithFailoverBaseURL sets a secondary base URL used when the primary is
+// unreachable or returns 503 Service Unavailable. Only idempotent requests
+// (such as GET) fail over, and only once per request.
+func WithFailoverBaseURL(url string) Option {
+	return func(c *clientConfig) { c.failoverBaseURL = url }
 }
 
-// WithTimeout sets a custom request timeout.
+// WithTimeout sets a custom request timeout. A zero or negative timeout
+// disables the SDK's per-request timeout, leaving deadlines entirely to the
+// caller's context and http.Client.
 func WithTimeout(timeout time.Duration) Option {
 	return func(c *clientConfig) { c.timeout = timeout }
 }
 
+// WithMaxRetries enables automatic retries of idempotent requests that fail
+// with a transient connection error, 429, or transient 5xx status, up to n
+// additional attempts. Permanent connection failures, such as certificate
+// verification errors, are not retried. Retries back off exponentially,
+// deferring to the server's Retry-After header on 429 and maintenance 503
+// responses. Retries are disabled by default.
+func WithMaxRetries(n int) Option {
+	return func(c *clientConfig) { c.maxRetries = n }
+}
+
+// WithRetryableErrorCodes also retries requests that fail with one of the
+// given error codes, such as "provider_temporarily_unavailable", whatever
+// the HTTP status. Like other retries, it applies only to idempotent
+// requests and requires WithMaxRetries.
+func WithRetryableErrorCodes(codes ...string) Option {
+	return func(c *clientConfig) { c.retryableCodes = append(c.retryableCodes, codes...) }
+}
+
+// WithMaxRetryDelay caps the wait between retries, including waits requested
+// by a Retry-After header. The default is 8 seconds.
+func WithMaxRetryDelay(d time.Duration) Option {
+	return func(c *clientConfig) { c.maxRetryDelay = d }
+}
+
+// WithMaxPageSize sets the largest ListOptions.PageSize that list calls
+// accept; larger sizes fail with an InvalidRequestError before any request
+// is sent. The default is MaxPageSize. NewClient returns an
+// InvalidRequestError if n is less than 1.
+func WithMaxPageSize(n int) Option {
+	return func(c *clientConfig) { c.maxPageSize = n }
+}
+
+// WithRetryPolicy sets the policy that computes the backoff between retries,
+// such as FullJitterPolicy. By default the delay doubles from 500ms without
+// jitter. WithMaxRetries and WithMaxRetryDelay still apply.
+func WithRetryPolicy(p RetryPolicy) Option {
+	return func(c *clientConfig) { c.retryPolicy = p }
+}
+
+// WithCircuitBreaker stops sending requests after failureThreshold
+// consecutive connection failures or 5xx responses, so that a struggling API
+// is not flooded with retries. The client timeout counts as a connection
+// failure; a deadline or cancellation of the caller's context does not.
+// While the circuit is open, requests fail
+// immediately with an APIConnectionError whose cause is ErrCircuitOpen.
+// After cooldown, a single request is let through: if it succeeds the
+// circuit closes, otherwise it stays open for another cooldown. The breaker
+// is shared by all requests of the client and is off by default.
+func WithCircuitBreaker(failureThreshold int, cooldown time.Duration) Option {
+	return func(c *clientConfig) {
+		c.breakerThreshold = failureThreshold
+		c.breakerCooldown = cooldown
+	}
+}
+
+// WithRateLimitCallback registers fn to be called before each retry backoff,
+// such as after a 429 response, with the wait about to be taken and the
+// 1-based number of the retry it precedes. Use it to emit metrics. It has no
+// effect unless retries are enabled with WithMaxRetries.
+func WithRateLimitCallback(fn func(wait time.Duration, attempt int)) Option {
+	return func(c *clientConfig) { c.onBackoff = fn }
+}
+
+// WithBatchConcurrency sets how many requests batch operations such as
+// SubscriptionService.CancelBatch run concurrently. The default is 4; values
+// below 1 are treated as 1.
+func WithBatchConcurrency(n int) Option {
+	return func(c *clientConfig) { c.batchConcurrency = max(n, 1) }
+}
+
+// WithBeforeRequest registers a hook invoked for every request after the SDK
+// has set its standard headers and immediately before it is sent. The hook
+// may add or modify headers, for example to attach short-lived credentials.
+// Returning an error aborts the request with an APIConnectionError.
+func WithBeforeRequest(hook func(*http.Request) error) Option {
+	return func(c *clientConfig) { c.beforeRequest = hook }
+}
+
+// WithRequestIDGenerator sets a function whose result is sent as the
+// X-Request-Id header of every outbound request, for correlating SDK traffic
+// with your own logs. It is called once per HTTP request, so retries and
+// failovers each receive a fresh ID. No header is sent when unset.
+func WithRequestIDGenerator(generate func() string) Option {
+	return func(c *clientConfig) { c.requestID = generate }
+}
+
+// WithRequestCoalescing makes concurrent identical GET requests, with the
+// same URL and API key, share a single network call and its response. Each
+// caller waits under its own context: a caller that is canceled or times out
+// returns its context's error, while the shared call continues for the
+// others. The shared call keeps the deadline of the request that started
+// it, and each of its attempts is bounded by the client timeout. Requests
+// are not coalesced when the client timeout is disabled with WithTimeout(0).
+func WithRequestCoalescing() Option {
+	return func(c *clientConfig) { c.coalesce = true }
+}
+
+// WithStrictResponses makes the client reject responses that lack fields
+// every valid resource carries, such as a subscription's id or status,
+// returning an APIError that names the missing fields instead of a
+// zero-valued struct. By default responses are accepted as-is.
+func WithStrictResponses() Option {
+	return func(c *clientConfig) { c.strict = true }
+}
+
+// WithLogger sets a Logger for SDK diagnostics, such as a warning for each
+// retried request. Nothing is logged by default.
+func WithLogger(l Logger) Option {
+	return func(c *clientConfig) { c.logger = l }
+}
+
+// WithRequestDump writes a dump of every outgoing request to w for
+// debugging: the method and URL, the headers, and the body. The API key and
+// any Authorization header are redacted, as are body fields named with
+// WithRedactedFields. Bodies are dumped as JSON even when
+// WithRequestCompression gzips them on the wire. Dumps are off by default.
+func WithRequestDump(w io.Writer) Option {
+	return func(c *clientConfig) { c.requestDump = w }
+}
+
+// WithAPIKeyInQuery sends the API key as the query parameter paramName
+// instead of the X-API-Key header, for gateways that expect it there. An
+// empty paramName means "api_key". The key is redacted from request dumps
+// and connection errors.
+func WithAPIKeyInQuery(paramName string) Option {
+	return func(c *clientConfig) {
+		if paramName == "" {
+			paramName = "api_key"
+		}
+		c.apiKeyQueryParam = paramName
+	}
+}
+
+// WithKeyRefresher sets a function that supplies a fresh API key when a
+// request is rejected with HTTP 401, for deployments that rotate keys. The
+// client switches to the new key and retries the request once. If refresh
+// fails or returns an empty key, or the retry is also rejected, the
+// AuthenticationError is returned.
+func WithKeyRefresher(refresh func(ctx context.Context) (string, error)) Option {
+	return func(c *clientConfig) { c.keyRefresher = refresh }
+}
+
+// WithRequestCompression gzips JSON request bodies larger than 1 KiB on POST
+// and PATCH requests and marks them with Content-Encoding: gzip. Smaller
+// bodies are sent uncompressed.
+func WithRequestCompression() Option {
+	return func(c *clientConfig) { c.compress = true }
+}
+
+// WithRedactedFields masks the values of the given JSON keys, at any depth,
+// as "***" in request dumps, for sensitive values such as email addresses
+// in metadata. The API key is always redacted.
+func WithRedactedFields(keys ...string) Option {
+	return func(c *clientConfig) { c.redactFields = append(c.redactFields, keys...) }
+}
+
+// WithDefaultMetadata sets metadata merged into every create and plan change
+// request, and into updates that set metadata, such as a tenant identifier.
+// Metadata passed on an individual call takes precedence on key conflicts.
+// Read-only requests are unaffected.
+func WithDefaultMetadata(metadata map[string]string) Option {
+	return func(c *clientConfig) { c.defaultMetadata = metadata }
+}
+
+// WithDefaultHeaders sends headers, such as a gateway's X-Tenant-Id, with
+// every request. It may be given more than once. Headers set on an
+// individual call take precedence. NewClient returns an InvalidRequestError
+// if headers include one the SDK manages for authentication or the request
+// body: X-API-Key, Authorization, Content-Type, or Content-Encoding.
+func WithDefaultHeaders(headers map[string]string) Option {
+	return func(c *clientConfig) {
+		if c.defaultHeaders == nil {
+			c.defaultHeaders = make(http.Header)
+		}
+		for k, v := range headers {
+			c.defaultHeaders.Set(k, v)
+		}
+	}
+}
+
+// protectedHeaders lists headers that WithDefaultHeaders may not set.
+var protectedHeaders = []string{"X-API-Key", "Authorization", "Content-Type", "Content-Encoding"}
+
+// WithConnectionPool tunes the connection pool of the SDK's default
+// transport: the maximum idle connections overall and per host, and how long
+// an idle connection is kept. Zero values keep the defaults of
+// http.DefaultTransport. It has no effect when WithHTTPClient is used.
+func WithConnectionPool(maxIdle, maxIdlePerHost int, idleTimeout time.Duration) Option {
+	return func(c *clientConfig) {
+		c.transportOptions = append(c.transportOptions, func(t *http.Transport) {
+			if maxIdle > 0 {
+				t.MaxIdleConns = maxIdle
+			}
+			if maxIdlePerHost > 0 {
+				t.MaxIdleConnsPerHost = maxIdlePerHost
+			}
+			if idleTimeout > 0 {
+				t.IdleConnTimeout = idleTimeout
+			}
+		})
+	}
+}
+
+// WithDisableKeepAlives turns off connection reuse in the SDK's default
+// transport, so no idle connections linger between requests. This suits
+// short-lived environments such as serverless functions. It has no effect
+// when WithHTTPClient is used.
+func WithDisableKeepAlives() Option {
+	return func(c *clientConfig) {
+		c.transportOptions = append(c.transportOptions, func(t *http.Transport) {
+			t.DisableKeepAlives = true
+		})
+	}
+}
+
+// WithClientCertificate presents cert to servers that require mutual TLS.
+// It may be given more than once to offer several certificates. It has no
+// effect when WithHTTPClient is used.
+func WithClientCertificate(cert tls.Certificate) Option {
+	return func(c *clientConfig) {
+		c.transportOptions = append(c.transportOptions, func(t *http.Transport) {
+			tlsConfig(t).Certificates = append(tlsConfig(t).Certificates, cert)
+		})
+	}
+}
+
+// WithRootCAs sets the certificate authorities used to verify the server,
+// replacing the system pool, for gateways with private certificates. It has
+// no effect when WithHTTPClient is used.
+func WithRootCAs(pool *x509.CertPool) Option {
+	return func(c *clientConfig) {
+		c.transportOptions = append(c.transportOptions, func(t *http.Transport) {
+			tlsConfig(t).RootCAs = pool
+		})
+	}
+}
+
+// WithInsecureSkipVerify disables verification of the server's TLS
+// certificate, for local development against self-signed endpoints.
+//
+// WARNING: this makes the connection vulnerable to interception. Never use
+// it in production; prefer WithRootCAs for private certificates. It has no
+// effect when WithHTTPClient is used.
+func WithInsecureSkipVerify() Option {
+	return func(c *clientConfig) {
+		c.transportOptions = append(c.transportOptions, func(t *http.Transport) {
+			tlsConfig(t).InsecureSkipVerify = true
+		})
+	}
+}
+
+// WithProxy routes requests through the HTTP or HTTPS proxy at proxyURL,
+// such as "http://proxy.internal:3128", instead of any proxy configured in
+// the environment. NewClient returns an InvalidRequestError if proxyURL is
+// not an absolute http or https URL. It has no effect when WithHTTPClient is
+// used.
+func WithProxy(proxyURL string) Option {
+	return func(c *clientConfig) { c.proxyURL = proxyURL }
+}
+
+// tlsConfig returns t's TLS configuration, creating it if needed.
+func tlsConfig(t *http.Transport) *tls.Config {
+	if t.TLSClientConfig == nil {
+		t.TLSClientConfig = &tls.Config{}
+	}
+	return t.TLSClientConfig
+}
+
 // WithHTTPClient sets a custom net/http client.
 func WithHTTPClient(client *http.Client) Option {
 	return func(c *clientConfig) { c.httpClient = client }
 }
 
 // NewClient creates a new Paylio SDK client.
-// Returns an AuthenticationError if apiKey is empty.
+// Returns an AuthenticationError if apiKey is empty, or an InvalidRequestError
+// if the base URL is not an absolute http or https URL.
 func NewClient(apiKey string, opts ...Option) (*Client, error) {
 	if apiKey == "" {
 		return nil, NewAuthenticationError(ErrorParams{
@@ -47,22 +414,194 @@ func NewClient(apiKey string, opts ...Option) (*Client, error) {
 	}
 
 	cfg := &clientConfig{
-		baseURL:    DefaultBaseURL,
-		timeout:    DefaultTimeout,
-		httpClient: &http.Client{},
+		baseURL:          DefaultBaseURL,
+		timeout:          DefaultTimeout,
+		maxRetryDelay:    defaultMaxRetryDelay,
+		maxPageSize:      MaxPageSize,
+		clock:            realClock{},
+		batchConcurrency: defaultBatchConcurrency,
 	}
 	for _, opt := range opts {
 		opt(cfg)
 	}
+	if cfg.environment != "" {
+		envURL, ok := environmentBaseURLs[cfg.environment]
+		if !ok {
+			return nil, NewInvalidRequestError(ErrorParams{
+				Message: fmt.Sprintf("Unknown environment %q: must be %q, %q, or %q", cfg.environment, EnvironmentProduction, EnvironmentSandbox, EnvironmentLocal),
+			})
+		}
+		if !cfg.baseURLSet {
+			cfg.baseURL = envURL
+		}
+	}
+	if err := validateBaseURL(cfg.baseURL); err != nil {
+		return nil, err
+	}
+	if cfg.failoverBaseURL != "" {
+		if err := validateBaseURL(cfg.failoverBaseURL); err != nil {
+			return nil, err
+		}
+	}
+	if cfg.maxPageSize < 1 {
+		return nil, NewInvalidRequestError(ErrorParams{
+			Message: fmt.Sprintf("Invalid max page size %d: must be at least 1", cfg.maxPageSize),
+		})
+	}
+	for _, k := range protectedHeaders {
+		if _, ok := cfg.defaultHeaders[http.CanonicalHeaderKey(k)]; ok {
+			return nil, NewInvalidRequestError(ErrorParams{
+				Message: fmt.Sprintf("WithDefaultHeaders cannot set the %s header", k),
+			})
+		}
+	}
+	if cfg.proxyURL != "" {
+		proxy, err := url.Parse(cfg.proxyURL)
+		if err != nil || (proxy.Scheme != "http" && proxy.Scheme != "https") || proxy.Host == "" {
+			return nil, NewInvalidRequestError(ErrorParams{
+				Message: fmt.Sprintf("Invalid proxy URL %q: must be an absolute http or https URL", cfg.proxyURL),
+			})
+		}
+		cfg.transportOptions = append(cfg.transportOptions, func(t *http.Transport) {
+			t.Proxy = http.ProxyURL(proxy)
+		})
+	}
 
-	hc := newHTTPClient(apiKey, cfg.baseURL, cfg.timeout, cfg.httpClient)
-	return &Client{
+	httpClient := cfg.httpClient
+	var transportFactory func() *http.Transport
+	if httpClient == nil {
+		transportFactory = func() *http.Transport {
+			t := newDefaultTransport()
+			for _, configure := range cfg.transportOptions {
+				configure(t)
+			}
+			return t
+		}
+		httpClient = &http.Client{Transport: transportFactory()}
+	}
+
+	baseURL, failoverBaseURL := cfg.baseURL, cfg.failoverBaseURL
+	if cfg.pathPrefix != "" {
+		baseURL = replacePath(baseURL, cfg.pathPrefix)
+		if failoverBaseURL != "" {
+			failoverBaseURL = replacePath(failoverBaseURL, cfg.pathPrefix)
+		}
+	}
+	hc := newHTTPClient(apiKey, strings.TrimRight(baseURL, "/"), cfg.timeout, httpClient)
+	hc.newTransport = transportFactory
+	hc.failoverBaseURL = strings.TrimRight(failoverBaseURL, "/")
+	hc.maxRetries = cfg.maxRetries
+	hc.maxRetryDelay = cfg.maxRetryDelay
+	hc.maxPageSize = cfg.maxPageSize
+	hc.clock = cfg.clock
+	hc.beforeRequest = cfg.beforeRequest
+	hc.defaultMetadata = cfg.defaultMetadata
+	hc.defaultHeaders = cfg.defaultHeaders
+	hc.requestID = cfg.requestID
+	hc.dump = cfg.requestDump
+	hc.onBackoff = cfg.onBackoff
+	hc.logger = cfg.logger
+	hc.strict = cfg.strict
+	hc.coalesce = cfg.coalesce
+	hc.apiKeyQueryParam = cfg.apiKeyQueryParam
+	hc.keyRefresher = cfg.keyRefresher
+	hc.compress = cfg.compress
+	hc.retryPolicy = cfg.retryPolicy
+	if len(cfg.retryableCodes) > 0 {
+		hc.retryableCodes = make(map[string]bool, len(cfg.retryableCodes))
+		for _, code := range cfg.retryableCodes {
+			hc.retryableCodes[code] = true
+		}
+	}
+	if cfg.breakerThreshold > 0 {
+		hc.breaker = newCircuitBreaker(cfg.breakerThreshold, cfg.breakerCooldown)
+	}
+	if len(cfg.redactFields) > 0 {
+		hc.redactFields = make(map[string]bool, len(cfg.redactFields))
+		for _, k := range cfg.redactFields {
+			hc.redactFields[k] = true
+		}
+	}
+	client := newClient(hc)
+	client.Subscription.batchConcurrency = cfg.batchConcurrency
+	return client, nil
+}
+
+// testingAPIKey is the placeholder API key sent by clients from
+// NewClientForTesting.
+const testingAPIKey = "sk_test_placeholder"
+
+// NewClientForTesting creates a client that sends requests to baseURL using
+// httpClient, for hermetic tests against a fake server such as one from
+// net/http/httptest or paylotest. No API key is required; the placeholder
+// key "sk_test_placeholder" is sent instead. Neither baseURL nor any other
+// setting is validated. A nil httpClient uses http.DefaultClient.
+func NewClientForTesting(baseURL string, httpClient *http.Client) *Client {
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	return newClient(newHTTPClient(testingAPIKey, baseURL, DefaultTimeout, httpClient))
+}
+
+// newClient wires the services to hc.
+func newClient(hc *httpClient) *Client {
+	c := &Client{
 		Subscription: newSubscriptionService(hc),
+		Refunds:      newRefundService(hc),
+		Invoices:     newInvoiceService(hc),
+		Disputes:     newDisputeService(hc),
+		Usage:        newUsageService(hc),
 		hc:           hc,
-	}, nil
+	}
+	c.Subscription.refunds = c.Refunds
+	return c
+}
+
+// replacePath returns rawURL, which has been validated by validateBaseURL,
+// with its path replaced by prefix.
+func replacePath(rawURL, prefix string) string {
+	u, _ := url.Parse(rawURL)
+	u.Path = "/" + strings.Trim(prefix, "/")
+	u.RawPath = ""
+	return u.String()
+}
+
+// validateBaseURL checks that rawURL parses as an absolute http or https URL.
+func validateBaseURL(rawURL string) error {
+	u, err := url.Parse(rawURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return NewInvalidRequestError(ErrorParams{
+			Message: fmt.Sprintf("Invalid base URL %q: must be an absolute http or https URL", rawURL),
+		})
+	}
+	return nil
+}
+
+// ResolveURL returns the URL a request for path with the given query
+// params is sent to, without sending it, for debugging and logging. It uses
+// the primary base URL and never includes the API key, even with
+// WithAPIKeyInQuery.
+func (c *Client) ResolveURL(path string, params map[string]string) (string, error) {
+	return buildURL(c.hc.baseURL, path, params, nil)
 }
 
-// Close releases resources held by the client.
+// Close is a soft close: it releases idle connections and makes subsequent
+// service calls return ErrClientClosed until Reset is called. Close is safe
+// to call multiple times and from multiple goroutines.
 func (c *Client) Close() {
 	c.hc.close()
 }
+
+// Reset reopens a closed client. When the client manages its own transport
+// (no WithHTTPClient), the transport is replaced with a fresh one so no
+// connection state survives from before Close. Reset must not be called
+// concurrently with in-flight requests.
+func (c *Client) Reset() {
+	c.hc.reset()
+}
+
+// newDefaultTransport returns a private copy of http.DefaultTransport so that
+// closing or reconfiguring it does not affect other users of the default.
+func newDefaultTransport() *http.Transport {
+	return http.DefaultTransport.(*http.Transport).Clone()
+}
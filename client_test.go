@@ -1,8 +1,25 @@
 package paylio
 
 import (
+	"compress/gzip"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
 	"errors"
+	"fmt"
+	"io"
+	"math/big"
 	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 )
@@ -102,3 +119,1049 @@ func TestClientCloseMultipleTimes(t *testing.T) {
 	client.Close()
 	client.Close() // second call should not panic
 }
+
+func TestNewClientBaseURLValidation(t *testing.T) {
+	tests := []struct {
+		name    string
+		baseURL string
+		wantErr bool
+	}{
+		{"missing scheme", "api.paylio.pro/v1", true},
+		{"ftp scheme", "ftp://api.paylio.pro/v1", true},
+		{"unparseable", "https://api paylio.pro/%zz", true},
+		{"valid https", "https://api.paylio.pro/v1", false},
+		{"valid https trailing slash", "https://api.paylio.pro/v1/", false},
+		{"valid http", "http://localhost:8080", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client, err := NewClient("sk_test", WithBaseURL(tt.baseURL))
+			if !tt.wantErr {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				if client == nil {
+					t.Fatal("client is nil")
+				}
+				return
+			}
+			var invErr *InvalidRequestError
+			if !errors.As(err, &invErr) {
+				t.Fatalf("expected *InvalidRequestError, got %T: %v", err, err)
+			}
+			if client != nil {
+				t.Error("client should be nil on error")
+			}
+		})
+	}
+}
+
+func TestNewClientWithFailoverBaseURL(t *testing.T) {
+	client, err := NewClient("sk_test", WithFailoverBaseURL("https://eu.api.paylio.pro/flying/v1/"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if client.hc.failoverBaseURL != "https://eu.api.paylio.pro/flying/v1" {
+		t.Errorf("failoverBaseURL = %q", client.hc.failoverBaseURL)
+	}
+}
+
+func TestWithPathPrefix(t *testing.T) {
+	var paths []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		paths = append(paths, r.URL.Path)
+		w.WriteHeader(200)
+		if strings.HasSuffix(r.URL.Path, "/subscriptions") {
+			_, _ = w.Write([]byte(`{"items":[],"total":0,"page":1,"page_size":20,"total_pages":0}`))
+			return
+		}
+		_, _ = w.Write([]byte(`{"id":"sub_1"}`))
+	}))
+	defer srv.Close()
+
+	tests := []struct {
+		baseURL string
+		prefix  string
+		want    string
+	}{
+		{srv.URL, "/paylio/v1", "/paylio/v1"},
+		{srv.URL + "/", "paylio/v1/", "/paylio/v1"},
+		{srv.URL + "/flying/v1", "/paylio/v1", "/paylio/v1"},
+		{srv.URL + "/flying/v1/", "/", ""},
+	}
+	for _, tt := range tests {
+		paths = nil
+		client, err := NewClient("sk_test", WithBaseURL(tt.baseURL), WithPathPrefix(tt.prefix))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := client.Subscription.Retrieve(context.Background(), "user_1"); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := client.Subscription.List(context.Background(), "user_1", nil); err != nil {
+			t.Fatal(err)
+		}
+		want := []string{tt.want + "/subscription/user_1", tt.want + "/users/user_1/subscriptions"}
+		if !reflect.DeepEqual(paths, want) {
+			t.Errorf("base %q, prefix %q: paths = %q, want %q", tt.baseURL, tt.prefix, paths, want)
+		}
+	}
+
+	client, err := NewClient("sk_test", WithPathPrefix("/paylio/v1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := client.ResolveURL("/subscription/user_1", nil); got != "https://api.paylio.pro/paylio/v1/subscription/user_1" {
+		t.Errorf("default base URL: ResolveURL = %q", got)
+	}
+
+	client, err = NewClient("sk_test", WithBaseURL("https://gw.example.com"),
+		WithFailoverBaseURL("https://gw-backup.example.com/flying/v1/"), WithPathPrefix("paylio/v1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if client.hc.failoverBaseURL != "https://gw-backup.example.com/paylio/v1" {
+		t.Errorf("failoverBaseURL = %q", client.hc.failoverBaseURL)
+	}
+	if got, _ := client.ResolveURL("/subscription/user_1", nil); got != "https://gw.example.com/paylio/v1/subscription/user_1" {
+		t.Errorf("ResolveURL = %q", got)
+	}
+}
+
+func TestNewClientInvalidFailoverBaseURL(t *testing.T) {
+	_, err := NewClient("sk_test", WithFailoverBaseURL("eu.api.paylio.pro"))
+	var invErr *InvalidRequestError
+	if !errors.As(err, &invErr) {
+		t.Fatalf("expected *InvalidRequestError, got %T: %v", err, err)
+	}
+}
+
+func TestNewClientRefundServiceNotNil(t *testing.T) {
+	client, err := NewClient("sk_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if client.Refunds == nil {
+		t.Error("Refunds service is nil")
+	}
+}
+
+func TestNewClientInvoiceServiceNotNil(t *testing.T) {
+	client, err := NewClient("sk_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if client.Invoices == nil {
+		t.Error("Invoices service is nil")
+	}
+}
+
+func TestClientRequestAfterCloseReturnsErrClientClosed(t *testing.T) {
+	client, err := NewClient("sk_test", WithBaseURL("http://127.0.0.1:1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	client.Close()
+
+	_, err = client.Subscription.Retrieve(context.Background(), "user_1")
+	if !errors.Is(err, ErrClientClosed) {
+		t.Errorf("err = %v, want ErrClientClosed", err)
+	}
+}
+
+func TestClientCloseConcurrent(t *testing.T) {
+	client, err := NewClient("sk_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			client.Close()
+		}()
+	}
+	wg.Wait()
+	if !client.hc.closed.Load() {
+		t.Error("client should be marked closed")
+	}
+}
+
+func TestNewClientRetryOptions(t *testing.T) {
+	client, err := NewClient("sk_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if client.hc.maxRetries != 0 {
+		t.Errorf("default maxRetries = %d, want 0", client.hc.maxRetries)
+	}
+	if client.hc.maxRetryDelay != 8*time.Second {
+		t.Errorf("default maxRetryDelay = %v", client.hc.maxRetryDelay)
+	}
+
+	client, err = NewClient("sk_test", WithMaxRetries(3), WithMaxRetryDelay(2*time.Second))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if client.hc.maxRetries != 3 {
+		t.Errorf("maxRetries = %d", client.hc.maxRetries)
+	}
+	if client.hc.maxRetryDelay != 2*time.Second {
+		t.Errorf("maxRetryDelay = %v", client.hc.maxRetryDelay)
+	}
+}
+
+func TestNewClientDisputeServiceNotNil(t *testing.T) {
+	client, err := NewClient("sk_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if client.Disputes == nil {
+		t.Error("Disputes service is nil")
+	}
+}
+
+func TestNewClientUsageServiceNotNil(t *testing.T) {
+	client, err := NewClient("sk_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if client.Usage == nil {
+		t.Error("Usage service is nil")
+	}
+}
+
+func TestClientResetAfterClose(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(200)
+		_, _ = w.Write([]byte(`{"id":"sub_1","status":"active"}`))
+	}))
+	defer srv.Close()
+
+	client, err := NewClient("sk_test", WithBaseURL(srv.URL))
+	if err != nil {
+		t.Fatal(err)
+	}
+	before := client.hc.client.Transport
+
+	client.Close()
+	if _, err := client.Subscription.Retrieve(context.Background(), "user_1"); !errors.Is(err, ErrClientClosed) {
+		t.Fatalf("err after Close = %v, want ErrClientClosed", err)
+	}
+
+	client.Reset()
+	sub, err := client.Subscription.Retrieve(context.Background(), "user_1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sub.ID != "sub_1" {
+		t.Errorf("ID = %q", sub.ID)
+	}
+	if client.hc.client.Transport == before {
+		t.Error("Reset should install a fresh transport")
+	}
+}
+
+func TestClientResetKeepsCustomHTTPClient(t *testing.T) {
+	custom := &http.Client{Transport: &http.Transport{}}
+	client, err := NewClient("sk_test", WithHTTPClient(custom))
+	if err != nil {
+		t.Fatal(err)
+	}
+	transport := custom.Transport
+
+	client.Close()
+	client.Reset()
+	if client.hc.client != custom || custom.Transport != transport {
+		t.Error("Reset must not modify a caller-supplied http.Client")
+	}
+	if client.hc.closed.Load() {
+		t.Error("client should be reopened")
+	}
+}
+
+func TestNewClientOwnsDefaultTransport(t *testing.T) {
+	client, err := NewClient("sk_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if client.hc.client.Transport == http.DefaultTransport {
+		t.Error("client should not share http.DefaultTransport")
+	}
+}
+
+func TestWithConnectionPool(t *testing.T) {
+	client, err := NewClient("sk_test", WithConnectionPool(200, 50, 2*time.Minute))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertPool := func(tr *http.Transport) {
+		t.Helper()
+		if tr.MaxIdleConns != 200 || tr.MaxIdleConnsPerHost != 50 || tr.IdleConnTimeout != 2*time.Minute {
+			t.Errorf("pool = (%d, %d, %v), want (200, 50, 2m0s)", tr.MaxIdleConns, tr.MaxIdleConnsPerHost, tr.IdleConnTimeout)
+		}
+	}
+	assertPool(client.hc.client.Transport.(*http.Transport))
+
+	client.Close()
+	client.Reset()
+	assertPool(client.hc.client.Transport.(*http.Transport))
+}
+
+func TestWithConnectionPoolZeroKeepsDefaults(t *testing.T) {
+	client, err := NewClient("sk_test", WithConnectionPool(0, 0, 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tr := client.hc.client.Transport.(*http.Transport)
+	def := http.DefaultTransport.(*http.Transport)
+	if tr.MaxIdleConns != def.MaxIdleConns || tr.MaxIdleConnsPerHost != def.MaxIdleConnsPerHost || tr.IdleConnTimeout != def.IdleConnTimeout {
+		t.Errorf("pool = (%d, %d, %v), want http.DefaultTransport's", tr.MaxIdleConns, tr.MaxIdleConnsPerHost, tr.IdleConnTimeout)
+	}
+}
+
+func TestWithDisableKeepAlives(t *testing.T) {
+	client, err := NewClient("sk_test", WithDisableKeepAlives())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !client.hc.client.Transport.(*http.Transport).DisableKeepAlives {
+		t.Error("DisableKeepAlives = false, want true")
+	}
+
+	client, err = NewClient("sk_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if client.hc.client.Transport.(*http.Transport).DisableKeepAlives {
+		t.Error("keep-alives should be enabled by default")
+	}
+}
+
+func TestWithConnectionPoolIgnoredForCustomClient(t *testing.T) {
+	transport := &http.Transport{}
+	client, err := NewClient("sk_test", WithHTTPClient(&http.Client{Transport: transport}), WithConnectionPool(200, 50, time.Minute))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if client.hc.client.Transport != transport || transport.MaxIdleConns != 0 {
+		t.Error("custom http.Client transport must not be modified")
+	}
+}
+
+func TestNewClientWithBatchConcurrency(t *testing.T) {
+	client, err := NewClient("sk_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if client.Subscription.batchConcurrency != 4 {
+		t.Errorf("default batchConcurrency = %d", client.Subscription.batchConcurrency)
+	}
+	client, err = NewClient("sk_test", WithBatchConcurrency(8))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if client.Subscription.batchConcurrency != 8 {
+		t.Errorf("batchConcurrency = %d", client.Subscription.batchConcurrency)
+	}
+	client, err = NewClient("sk_test", WithBatchConcurrency(0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if client.Subscription.batchConcurrency != 1 {
+		t.Errorf("batchConcurrency = %d, want 1", client.Subscription.batchConcurrency)
+	}
+}
+
+func TestNewClientTimeoutDefaultAndDisabled(t *testing.T) {
+	client, err := NewClient("sk_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if client.hc.timeout != DefaultTimeout {
+		t.Errorf("default timeout = %v", client.hc.timeout)
+	}
+	client, err = NewClient("sk_test", WithTimeout(0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if client.hc.timeout != 0 {
+		t.Errorf("timeout = %v, want disabled", client.hc.timeout)
+	}
+}
+
+func TestWithBeforeRequestSetsHeader(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if got := r.Header.Get("X-Signed-Token"); got != "tok_123" {
+			t.Errorf("X-Signed-Token = %q", got)
+		}
+		if got := r.Header.Get("X-API-Key"); got != "sk_test" {
+			t.Errorf("X-API-Key = %q", got)
+		}
+		w.WriteHeader(200)
+		_, _ = w.Write([]byte(`{"id":"sub_1"}`))
+	}))
+	defer srv.Close()
+
+	var sawKey string
+	client, err := NewClient("sk_test", WithBaseURL(srv.URL), WithBeforeRequest(func(r *http.Request) error {
+		sawKey = r.Header.Get("X-API-Key")
+		r.Header.Set("X-Signed-Token", "tok_123")
+		return nil
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.Subscription.Retrieve(context.Background(), "user_1"); err != nil {
+		t.Fatal(err)
+	}
+	if sawKey != "sk_test" {
+		t.Errorf("hook ran before standard headers were set: X-API-Key = %q", sawKey)
+	}
+}
+
+func TestWithBeforeRequestAborts(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		t.Error("request should not be sent")
+	}))
+	defer srv.Close()
+
+	client, err := NewClient("sk_test", WithBaseURL(srv.URL), WithBeforeRequest(func(*http.Request) error {
+		return errors.New("token service unavailable")
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = client.Subscription.Retrieve(context.Background(), "user_1")
+	var connErr *APIConnectionError
+	if !errors.As(err, &connErr) {
+		t.Fatalf("expected *APIConnectionError, got %T: %v", err, err)
+	}
+	if connErr.Message != "before-request hook failed: token service unavailable" {
+		t.Errorf("Message = %q", connErr.Message)
+	}
+}
+
+func TestWithBeforeRequestErrorIsNotRetriedOrCounted(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		t.Error("request should not be sent")
+	}))
+	defer srv.Close()
+
+	var calls int
+	client, err := NewClient("sk_test",
+		WithBaseURL(srv.URL),
+		WithFailoverBaseURL(srv.URL),
+		WithMaxRetries(3),
+		WithCircuitBreaker(1, time.Minute),
+		withClock(newFakeClock()),
+		WithBeforeRequest(func(*http.Request) error {
+			calls++
+			return errors.New("token service unavailable")
+		}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 2; i++ {
+		_, err = client.Subscription.Retrieve(context.Background(), "user_1")
+		if errors.Is(err, ErrCircuitOpen) {
+			t.Fatalf("call %d: a local hook failure opened the circuit", i+1)
+		}
+	}
+	if calls != 2 {
+		t.Errorf("hook calls = %d, want 2 (no retries or failover)", calls)
+	}
+}
+
+func TestWithRequestIDGenerator(t *testing.T) {
+	var seen []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		seen = append(seen, r.Header.Get("X-Request-Id"))
+		w.WriteHeader(200)
+		_, _ = w.Write([]byte(`{"id":"sub_1"}`))
+	}))
+	defer srv.Close()
+
+	calls := 0
+	client, err := NewClient("sk_test", WithBaseURL(srv.URL), WithRequestIDGenerator(func() string {
+		calls++
+		return fmt.Sprintf("req_%d", calls)
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 2; i++ {
+		if _, err := client.Subscription.Retrieve(context.Background(), "user_1"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if calls != 2 {
+		t.Errorf("generator called %d times, want 2", calls)
+	}
+	if len(seen) != 2 || seen[0] != "req_1" || seen[1] != "req_2" {
+		t.Errorf("X-Request-Id values = %q", seen)
+	}
+}
+
+func TestNoRequestIDHeaderByDefault(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, ok := r.Header["X-Request-Id"]; ok {
+			t.Errorf("unexpected X-Request-Id header %q", r.Header.Get("X-Request-Id"))
+		}
+		w.WriteHeader(200)
+		_, _ = w.Write([]byte(`{"id":"sub_1"}`))
+	}))
+	defer srv.Close()
+
+	client, err := NewClient("sk_test", WithBaseURL(srv.URL))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.Subscription.Retrieve(context.Background(), "user_1"); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestWithDefaultHeaders(t *testing.T) {
+	var tenants, keys []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		tenants = append(tenants, r.Header.Get("X-Tenant-Id"))
+		keys = append(keys, r.Header.Get("X-API-Key"))
+		w.WriteHeader(200)
+		_, _ = w.Write([]byte(`{"id":"sub_1"}`))
+	}))
+	defer srv.Close()
+
+	client, err := NewClient("sk_test", WithBaseURL(srv.URL),
+		WithDefaultHeaders(map[string]string{"x-tenant-id": "t_1"}),
+		WithDefaultHeaders(map[string]string{"X-Gateway": "edge"}),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 2; i++ {
+		if _, err := client.Subscription.Retrieve(context.Background(), "user_1"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	_, _ = client.Subscription.Retrieve(context.Background(), "user_1", WithIfNoneMatch(`"v1"`))
+	if strings.Join(tenants, ",") != "t_1,t_1,t_1" || strings.Join(keys, ",") != "sk_test,sk_test,sk_test" {
+		t.Errorf("X-Tenant-Id = %q, X-API-Key = %q", tenants, keys)
+	}
+
+	for _, k := range []string{"X-API-Key", "x-api-key", "Authorization", "Content-Type", "Content-Encoding"} {
+		_, err := NewClient("sk_test", WithDefaultHeaders(map[string]string{k: "evil"}))
+		var invalid *InvalidRequestError
+		if !errors.As(err, &invalid) {
+			t.Errorf("WithDefaultHeaders(%s): expected *InvalidRequestError, got %T", k, err)
+		}
+	}
+}
+
+func TestWithDefaultMetadataMergesIntoCreate(t *testing.T) {
+	var gotMetadata map[string]any
+	var getBodies []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if r.Method == "GET" {
+			getBodies = append(getBodies, string(body))
+			if len(r.URL.Query()) != 0 {
+				t.Errorf("GET query = %v, want none", r.URL.Query())
+			}
+		} else {
+			var parsed map[string]any
+			if err := json.Unmarshal(body, &parsed); err != nil {
+				t.Fatal(err)
+			}
+			gotMetadata, _ = parsed["metadata"].(map[string]any)
+		}
+		w.WriteHeader(200)
+		_, _ = w.Write([]byte(`{"id":"sub_1"}`))
+	}))
+	defer srv.Close()
+
+	client, err := NewClient("sk_test", WithBaseURL(srv.URL), WithDefaultMetadata(map[string]string{
+		"tenant_id": "t_default",
+		"source":    "sdk",
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = client.Subscription.Create(context.Background(), &CreateSubscriptionParams{
+		UserID:   "user_1",
+		PlanSlug: "pro",
+		Metadata: map[string]string{"tenant_id": "t_override", "campaign": "spring"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]any{"tenant_id": "t_override", "source": "sdk", "campaign": "spring"}
+	if len(gotMetadata) != len(want) {
+		t.Fatalf("metadata = %v, want %v", gotMetadata, want)
+	}
+	for k, v := range want {
+		if gotMetadata[k] != v {
+			t.Errorf("metadata[%s] = %v, want %v", k, gotMetadata[k], v)
+		}
+	}
+
+	if _, err := client.Subscription.Retrieve(context.Background(), "user_1"); err != nil {
+		t.Fatal(err)
+	}
+	if len(getBodies) != 1 || getBodies[0] != "" {
+		t.Errorf("GET bodies = %q, want a single empty body", getBodies)
+	}
+}
+
+func TestMergeMetadata(t *testing.T) {
+	hc := newHTTPClient("sk_test", "http://localhost", time.Second, &http.Client{})
+	if got := hc.mergeMetadata(nil); got != nil {
+		t.Errorf("mergeMetadata(nil) = %v, want nil", got)
+	}
+	got := hc.mergeMetadata(map[string]string{"a": "1"})
+	if len(got) != 1 || got["a"] != "1" {
+		t.Errorf("mergeMetadata = %v", got)
+	}
+}
+
+func TestNewClientForTesting(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/subscription/user_1" {
+			t.Errorf("Path = %q", r.URL.Path)
+		}
+		if got := r.Header.Values("X-API-Key"); !reflect.DeepEqual(got, []string{"sk_test_placeholder"}) {
+			t.Errorf("X-API-Key = %q, want the placeholder key", got)
+		}
+		w.WriteHeader(200)
+		_, _ = w.Write([]byte(`{"id":"sub_1","status":"active"}`))
+	}))
+	defer srv.Close()
+
+	client := NewClientForTesting(srv.URL, srv.Client())
+	sub, err := client.Subscription.Retrieve(context.Background(), "user_1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sub.ID != "sub_1" {
+		t.Errorf("ID = %q", sub.ID)
+	}
+	if client.Refunds == nil || client.Invoices == nil || client.Disputes == nil || client.Usage == nil {
+		t.Error("services not initialized")
+	}
+}
+
+func TestNewClientForTestingNilHTTPClient(t *testing.T) {
+	client := NewClientForTesting("http://localhost", nil)
+	if client.hc.client != http.DefaultClient {
+		t.Error("expected http.DefaultClient")
+	}
+}
+
+// newTestClientCertificate returns a self-signed certificate usable for TLS
+// client authentication.
+func newTestClientCertificate(t *testing.T) (tls.Certificate, *x509.Certificate) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "paylio-test-client"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	leaf, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key, Leaf: leaf}, leaf
+}
+
+func TestWithClientCertificateMutualTLS(t *testing.T) {
+	cert, leaf := newTestClientCertificate(t)
+	clientCAs := x509.NewCertPool()
+	clientCAs.AddCert(leaf)
+
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(200)
+		_, _ = w.Write([]byte(`{"id":"sub_1"}`))
+	}))
+	srv.TLS = &tls.Config{ClientAuth: tls.RequireAndVerifyClientCert, ClientCAs: clientCAs}
+	srv.StartTLS()
+	defer srv.Close()
+
+	rootCAs := x509.NewCertPool()
+	rootCAs.AddCert(srv.Certificate())
+
+	client, err := NewClient("sk_test", WithBaseURL(srv.URL), WithRootCAs(rootCAs),
+		WithClientCertificate(cert), WithConnectionPool(10, 5, time.Minute))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.Subscription.Retrieve(context.Background(), "user_1"); err != nil {
+		t.Fatalf("with client certificate: %v", err)
+	}
+	if tr := client.hc.client.Transport.(*http.Transport); tr.MaxIdleConnsPerHost != 5 {
+		t.Errorf("MaxIdleConnsPerHost = %d, want 5", tr.MaxIdleConnsPerHost)
+	}
+
+	client, err = NewClient("sk_test", WithBaseURL(srv.URL), WithRootCAs(rootCAs))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = client.Subscription.Retrieve(context.Background(), "user_1")
+	var connErr *APIConnectionError
+	if !errors.As(err, &connErr) {
+		t.Fatalf("without client certificate: expected *APIConnectionError, got %T: %v", err, err)
+	}
+}
+
+func TestTLSConfigCreatesConfig(t *testing.T) {
+	tr := &http.Transport{}
+	cfg := tlsConfig(tr)
+	if cfg == nil || tr.TLSClientConfig != cfg {
+		t.Fatal("expected TLS config to be created on the transport")
+	}
+	if tlsConfig(tr) != cfg {
+		t.Error("expected existing TLS config to be reused")
+	}
+}
+
+func TestWithEnvironment(t *testing.T) {
+	tests := []struct {
+		env  string
+		want string
+	}{
+		{EnvironmentProduction, DefaultBaseURL},
+		{EnvironmentSandbox, "https://sandbox.api.paylio.pro/flying/v1"},
+		{EnvironmentLocal, "http://localhost:8000/flying/v1"},
+	}
+	for _, tt := range tests {
+		client, err := NewClient("sk_test", WithEnvironment(tt.env))
+		if err != nil {
+			t.Fatalf("%s: %v", tt.env, err)
+		}
+		if client.hc.baseURL != tt.want {
+			t.Errorf("%s: baseURL = %q, want %q", tt.env, client.hc.baseURL, tt.want)
+		}
+	}
+}
+
+func TestWithEnvironmentUnknown(t *testing.T) {
+	_, err := NewClient("sk_test", WithEnvironment("staging"))
+	var invalidErr *InvalidRequestError
+	if !errors.As(err, &invalidErr) {
+		t.Fatalf("expected *InvalidRequestError, got %T: %v", err, err)
+	}
+}
+
+func TestWithBaseURLOverridesEnvironment(t *testing.T) {
+	for _, opts := range [][]Option{
+		{WithEnvironment(EnvironmentSandbox), WithBaseURL("https://custom.example.com/v1")},
+		{WithBaseURL("https://custom.example.com/v1"), WithEnvironment(EnvironmentSandbox)},
+	} {
+		client, err := NewClient("sk_test", opts...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if client.hc.baseURL != "https://custom.example.com/v1" {
+			t.Errorf("baseURL = %q", client.hc.baseURL)
+		}
+	}
+}
+
+func TestWithAPIKeyInQuery(t *testing.T) {
+	var queries []url.Values
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if got := r.Header.Get("X-API-Key"); got != "" {
+			t.Errorf("X-API-Key = %q, want no header", got)
+		}
+		queries = append(queries, r.URL.Query())
+		w.WriteHeader(200)
+		_, _ = w.Write([]byte(`{"id":"sub_1","items":[],"total":0,"page":1,"page_size":10,"has_more":false}`))
+	}))
+	defer srv.Close()
+
+	client, err := NewClient("sk_test", WithBaseURL(srv.URL), WithAPIKeyInQuery("key"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.Subscription.Retrieve(context.Background(), "user_1"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.Subscription.List(context.Background(), "user_1", &ListOptions{Page: 2}, WithAPIKey("sk_other")); err != nil {
+		t.Fatal(err)
+	}
+	if got := queries[0].Get("key"); got != "sk_test" {
+		t.Errorf("key = %q, want sk_test", got)
+	}
+	if got := queries[1].Get("key"); got != "sk_other" || queries[1].Get("page") != "2" {
+		t.Errorf("list query = %v", queries[1])
+	}
+
+	client, _ = NewClient("sk_test", WithBaseURL(srv.URL), WithAPIKeyInQuery(""))
+	if _, err := client.Subscription.Retrieve(context.Background(), "user_1"); err != nil {
+		t.Fatal(err)
+	}
+	if got := queries[2].Get("api_key"); got != "sk_test" {
+		t.Errorf("api_key = %q, want sk_test", got)
+	}
+}
+
+func TestWithKeyRefresherRetriesWithFreshKey(t *testing.T) {
+	var keys []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		keys = append(keys, r.Header.Get("X-API-Key"))
+		if r.Header.Get("X-API-Key") != "sk_fresh" {
+			w.WriteHeader(401)
+			_, _ = w.Write([]byte(`{"error":{"code":"invalid_api_key","message":"Expired key"}}`))
+			return
+		}
+		w.WriteHeader(200)
+		_, _ = w.Write([]byte(`{"id":"sub_1"}`))
+	}))
+	defer srv.Close()
+
+	refreshes := 0
+	client, err := NewClient("sk_stale", WithBaseURL(srv.URL), WithKeyRefresher(func(context.Context) (string, error) {
+		refreshes++
+		return "sk_fresh", nil
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 2; i++ {
+		if _, err := client.Subscription.Retrieve(context.Background(), "user_1"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if refreshes != 1 {
+		t.Errorf("refreshes = %d, want 1", refreshes)
+	}
+	want := []string{"sk_stale", "sk_fresh", "sk_fresh"}
+	if fmt.Sprint(keys) != fmt.Sprint(want) {
+		t.Errorf("keys sent = %q, want %q", keys, want)
+	}
+}
+
+func TestWithKeyRefresherGivesUp(t *testing.T) {
+	hits := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		hits++
+		w.WriteHeader(401)
+		_, _ = w.Write([]byte(`{"error":{"code":"invalid_api_key","message":"Invalid key"}}`))
+	}))
+	defer srv.Close()
+
+	tests := []struct {
+		name     string
+		refresh  func(context.Context) (string, error)
+		reqOpts  []RequestOption
+		wantHits int
+	}{
+		{"retry still rejected", func(context.Context) (string, error) { return "sk_also_bad", nil }, nil, 2},
+		{"refresher fails", func(context.Context) (string, error) { return "", errors.New("vault down") }, nil, 1},
+		{"empty key", func(context.Context) (string, error) { return " ", nil }, nil, 1},
+		{"per-call key", func(context.Context) (string, error) { return "sk_fresh", nil }, []RequestOption{WithAPIKey("sk_tenant")}, 1},
+	}
+	for _, tt := range tests {
+		hits = 0
+		client, err := NewClient("sk_stale", WithBaseURL(srv.URL), WithKeyRefresher(tt.refresh))
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = client.Subscription.Retrieve(context.Background(), "user_1", tt.reqOpts...)
+		var authErr *AuthenticationError
+		if !errors.As(err, &authErr) {
+			t.Errorf("%s: expected *AuthenticationError, got %T", tt.name, err)
+		}
+		if hits != tt.wantHits {
+			t.Errorf("%s: hits = %d, want %d", tt.name, hits, tt.wantHits)
+		}
+	}
+}
+
+func TestWithRequestCompression(t *testing.T) {
+	type received struct {
+		encoding string
+		body     map[string]any
+	}
+	var got []received
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body io.Reader = r.Body
+		if r.Header.Get("Content-Encoding") == "gzip" {
+			zr, err := gzip.NewReader(r.Body)
+			if err != nil {
+				t.Errorf("gzip body: %v", err)
+				return
+			}
+			body = zr
+		}
+		var decoded map[string]any
+		if err := json.NewDecoder(body).Decode(&decoded); err != nil {
+			t.Errorf("decode body: %v", err)
+		}
+		got = append(got, received{r.Header.Get("Content-Encoding"), decoded})
+		w.WriteHeader(200)
+		_, _ = w.Write([]byte(`{"id":"sub_1"}`))
+	}))
+	defer srv.Close()
+
+	client, err := NewClient("sk_test", WithBaseURL(srv.URL), WithRequestCompression())
+	if err != nil {
+		t.Fatal(err)
+	}
+	large := map[string]string{"notes": strings.Repeat("x", 2048)}
+	if _, err := client.Subscription.Create(context.Background(), &CreateSubscriptionParams{UserID: "user_1", PlanSlug: "pro", Metadata: large}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.Subscription.Create(context.Background(), &CreateSubscriptionParams{UserID: "user_1", PlanSlug: "pro"}); err != nil {
+		t.Fatal(err)
+	}
+	if got[0].encoding != "gzip" {
+		t.Errorf("large body Content-Encoding = %q, want gzip", got[0].encoding)
+	}
+	if md, _ := got[0].body["metadata"].(map[string]any); md["notes"] != large["notes"] {
+		t.Errorf("large body not decompressed intact: %v", got[0].body)
+	}
+	if got[1].encoding != "" || got[1].body["plan_slug"] != "pro" {
+		t.Errorf("small body = %+v, want plain JSON", got[1])
+	}
+}
+
+func TestWithInsecureSkipVerify(t *testing.T) {
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(200)
+		_, _ = w.Write([]byte(`{"id":"sub_1"}`))
+	}))
+	defer srv.Close()
+
+	client, err := NewClient("sk_test", WithBaseURL(srv.URL))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = client.Subscription.Retrieve(context.Background(), "user_1")
+	var connErr *APIConnectionError
+	if !errors.As(err, &connErr) {
+		t.Fatalf("without option: expected *APIConnectionError, got %T: %v", err, err)
+	}
+
+	client, err = NewClient("sk_test", WithBaseURL(srv.URL), WithInsecureSkipVerify())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.Subscription.Retrieve(context.Background(), "user_1"); err != nil {
+		t.Fatalf("with option: %v", err)
+	}
+
+	custom := &http.Client{}
+	client, err = NewClient("sk_test", WithBaseURL(srv.URL), WithHTTPClient(custom), WithInsecureSkipVerify())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if custom.Transport != nil {
+		t.Error("WithInsecureSkipVerify should not modify a custom client")
+	}
+	if _, err := client.Subscription.Retrieve(context.Background(), "user_1"); !errors.As(err, &connErr) {
+		t.Errorf("custom client: expected *APIConnectionError, got %T", err)
+	}
+}
+
+func TestWithProxy(t *testing.T) {
+	var proxied []string
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		proxied = append(proxied, r.Method+" "+r.URL.String())
+		w.WriteHeader(200)
+		_, _ = w.Write([]byte(`{"id":"sub_1"}`))
+	}))
+	defer proxy.Close()
+
+	client, err := NewClient("sk_test", WithBaseURL("http://api.paylio.invalid/v1"), WithProxy(proxy.URL))
+	if err != nil {
+		t.Fatal(err)
+	}
+	sub, err := client.Subscription.Retrieve(context.Background(), "user_1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sub.ID != "sub_1" {
+		t.Errorf("ID = %q", sub.ID)
+	}
+	if len(proxied) != 1 || proxied[0] != "GET http://api.paylio.invalid/v1/subscription/user_1" {
+		t.Errorf("proxied = %q", proxied)
+	}
+
+	for _, bad := range []string{"proxy.internal:3128", "ftp://proxy.internal", "http://", "http://%zz"} {
+		_, err := NewClient("sk_test", WithProxy(bad))
+		var invalid *InvalidRequestError
+		if !errors.As(err, &invalid) {
+			t.Errorf("WithProxy(%q): expected *InvalidRequestError, got %T", bad, err)
+		}
+	}
+}
+
+func TestWithMaxPageSize(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(200)
+		_, _ = w.Write([]byte(`{"items":[],"total":0,"page":1,"page_size":20,"total_pages":0}`))
+	}))
+	defer srv.Close()
+
+	client, err := NewClient("sk_test", WithBaseURL(srv.URL), WithMaxPageSize(50))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.Subscription.List(context.Background(), "user_1", &ListOptions{PageSize: 50}); err != nil {
+		t.Errorf("page size at cap: %v", err)
+	}
+	_, err = client.Subscription.List(context.Background(), "user_1", &ListOptions{PageSize: 51})
+	if err == nil || err.Error() != "page_size must be at most 50, got 51" {
+		t.Errorf("page size over cap: err = %v", err)
+	}
+
+	var invalid *InvalidRequestError
+	if _, err := NewClient("sk_test", WithMaxPageSize(0)); !errors.As(err, &invalid) {
+		t.Errorf("WithMaxPageSize(0): expected *InvalidRequestError, got %T", err)
+	}
+}
+
+func TestResolveURL(t *testing.T) {
+	tests := []struct {
+		baseURL string
+		path    string
+		params  map[string]string
+		want    string
+	}{
+		{"https://api.example.com/v1", "/subscription/user_1", nil, "https://api.example.com/v1/subscription/user_1"},
+		{"https://api.example.com/v1/", "/subscription/user_1", nil, "https://api.example.com/v1/subscription/user_1"},
+		{"https://api.example.com/v1//", "/users/user_1/subscriptions", map[string]string{"page": "2", "page_size": "10"},
+			"https://api.example.com/v1/users/user_1/subscriptions?page=2&page_size=10"},
+	}
+	for _, tt := range tests {
+		client, err := NewClient("sk_test", WithBaseURL(tt.baseURL), WithAPIKeyInQuery("api_key"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := client.ResolveURL(tt.path, tt.params)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != tt.want {
+			t.Errorf("ResolveURL(%q, %v) with base %q = %q, want %q", tt.path, tt.params, tt.baseURL, got, tt.want)
+		}
+	}
+
+	client, _ := NewClient("sk_test")
+	if _, err := client.ResolveURL("/bad path%zz", map[string]string{"a": "b"}); err == nil {
+		t.Error("expected an error for an unparsable path")
+	}
+}
@@ -0,0 +1,23 @@
+package paylio
+
+import "time"
+
+// clock abstracts the passage of time so retry and rate-limit backoff can be
+// driven deterministically in tests.
+type clock interface {
+	Now() time.Time
+	After(d time.Duration) <-chan time.Time
+}
+
+// realClock is the clock used in production; it defers to the time package.
+type realClock struct{}
+
+func (realClock) Now() time.Time { return time.Now() }
+
+func (realClock) After(d time.Duration) <-chan time.Time { return time.After(d) }
+
+// withClock replaces the client's clock. It is a test hook and intentionally
+// unexported.
+func withClock(c clock) Option {
+	return func(cfg *clientConfig) { cfg.clock = c }
+}
@@ -0,0 +1,78 @@
+package paylio
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeClock is a clock whose After fires immediately, advancing Now by the
+// requested duration and recording it so tests can assert backoff timing.
+type fakeClock struct {
+	mu     sync.Mutex
+	now    time.Time
+	sleeps []time.Duration
+}
+
+func newFakeClock() *fakeClock {
+	return &fakeClock{now: time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)}
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) After(d time.Duration) <-chan time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+	c.sleeps = append(c.sleeps, d)
+	ch := make(chan time.Time, 1)
+	ch <- c.now
+	return ch
+}
+
+func (c *fakeClock) Sleeps() []time.Duration {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]time.Duration(nil), c.sleeps...)
+}
+
+func TestRealClockNow(t *testing.T) {
+	before := time.Now()
+	got := realClock{}.Now()
+	if got.Before(before) {
+		t.Errorf("Now() = %v, before %v", got, before)
+	}
+}
+
+func TestRealClockAfter(t *testing.T) {
+	select {
+	case <-realClock{}.After(time.Millisecond):
+	case <-time.After(time.Second):
+		t.Fatal("After did not fire")
+	}
+}
+
+func TestNewClientDefaultsToRealClock(t *testing.T) {
+	client, err := NewClient("sk_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := client.hc.clock.(realClock); !ok {
+		t.Errorf("clock = %T, want realClock", client.hc.clock)
+	}
+}
+
+func TestNewClientWithClock(t *testing.T) {
+	fc := newFakeClock()
+	client, err := NewClient("sk_test", withClock(fc))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if client.hc.clock != fc {
+		t.Errorf("clock = %T, want injected fake", client.hc.clock)
+	}
+}
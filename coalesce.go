@@ -0,0 +1,97 @@
+package paylio
+
+import (
+	"context"
+	"net/http"
+	"sync"
+)
+
+// flightGroup merges concurrent calls with the same key into one execution
+// whose result every caller shares.
+type flightGroup struct {
+	mu    sync.Mutex
+	calls map[string]*flight
+}
+
+// flight is an in-progress or completed call in a flightGroup.
+type flight struct {
+	// done is closed once data and err are set.
+	done chan struct{}
+	data map[string]any
+	err  error
+	// dups counts callers that joined the flight after it started.
+	dups int
+}
+
+// do runs fn once for all concurrent callers with the same key. fn runs on
+// a context detached from ctx, so the caller that started the call giving up
+// does not fail it for the others, but it keeps that caller's deadline, if
+// any, which together with the client timeout bounds the call. Every caller,
+// including the one that started the call, stops waiting when its own ctx is
+// done.
+func (g *flightGroup) do(ctx context.Context, key string, fn func(context.Context) (map[string]any, error)) (map[string]any, error) {
+	g.mu.Lock()
+	if g.calls == nil {
+		g.calls = make(map[string]*flight)
+	}
+	f, ok := g.calls[key]
+	if ok {
+		f.dups++
+	} else {
+		f = &flight{done: make(chan struct{})}
+		g.calls[key] = f
+		callCtx, cancel := context.WithoutCancel(ctx), context.CancelFunc(func() {})
+		if deadline, ok := ctx.Deadline(); ok {
+			callCtx, cancel = context.WithDeadline(callCtx, deadline)
+		}
+		go g.run(callCtx, cancel, key, f, fn)
+	}
+	g.mu.Unlock()
+
+	select {
+	case <-f.done:
+		return f.data, f.err
+	case <-ctx.Done():
+		return nil, newConnectionError(ctx.Err())
+	}
+}
+
+// run executes fn for f, releasing ctx with cancel, and removes f from the
+// group once it completes.
+func (g *flightGroup) run(ctx context.Context, cancel context.CancelFunc, key string, f *flight, fn func(context.Context) (map[string]any, error)) {
+	defer cancel()
+	f.data, f.err = fn(ctx)
+	g.mu.Lock()
+	delete(g.calls, key)
+	g.mu.Unlock()
+	close(f.done)
+}
+
+// coalesceKey returns the key under which a request may share an in-flight
+// identical request: the full URL plus the API key. Only plain GETs
+// coalesce; requests that stream their body, capture response headers, or
+// send extra headers are always made individually. Nothing coalesces while
+// the client timeout is disabled, since a shared call could then outlive
+// every caller.
+func (hc *httpClient) coalesceKey(method, path string, opts *requestOptions) (string, bool) {
+	if !hc.coalesce || hc.timeout <= 0 || method != http.MethodGet {
+		return "", false
+	}
+	apiKey := hc.currentAPIKey()
+	var params map[string]string
+	var expand []string
+	if opts != nil {
+		if opts.Decode != nil || opts.ResponseHeader != nil || len(opts.Header) > 0 {
+			return "", false
+		}
+		if opts.APIKey != nil {
+			apiKey = *opts.APIKey
+		}
+		params, expand = opts.Params, opts.Expand
+	}
+	u, err := buildURL(hc.baseURL, path, params, expand)
+	if err != nil {
+		return "", false
+	}
+	return apiKey + " " + u, true
+}
@@ -0,0 +1,286 @@
+package paylio
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// waitForDups blocks until the flight for key has n joined callers.
+func waitForDups(t *testing.T, g *flightGroup, key string, n int) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		g.mu.Lock()
+		f, ok := g.calls[key]
+		dups := 0
+		if ok {
+			dups = f.dups
+		}
+		g.mu.Unlock()
+		if dups == n {
+			return
+		}
+		time.Sleep(time.Millisecond)
+	}
+	t.Fatalf("timed out waiting for %d callers to join the flight", n)
+}
+
+func TestWithRequestCoalescingSharesOneCall(t *testing.T) {
+	var hits atomic.Int32
+	release := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		hits.Add(1)
+		<-release
+		w.WriteHeader(200)
+		_, _ = w.Write([]byte(`{"id":"sub_1","status":"active"}`))
+	}))
+	defer srv.Close()
+
+	client, err := NewClient("sk_test", WithBaseURL(srv.URL), WithRequestCoalescing())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	const n = 20
+	var wg sync.WaitGroup
+	subs := make([]*Subscription, n)
+	errs := make([]error, n)
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			subs[i], errs[i] = client.Subscription.Retrieve(context.Background(), "user_1")
+		}(i)
+	}
+	waitForDups(t, &client.hc.flights, "sk_test "+srv.URL+"/subscription/user_1", n-1)
+	close(release)
+	wg.Wait()
+
+	if got := hits.Load(); got != 1 {
+		t.Errorf("server hits = %d, want 1", got)
+	}
+	for i := 0; i < n; i++ {
+		if errs[i] != nil {
+			t.Fatalf("caller %d: %v", i, errs[i])
+		}
+		if subs[i].ID != "sub_1" {
+			t.Errorf("caller %d: ID = %q", i, subs[i].ID)
+		}
+	}
+	if subs[0] == subs[1] {
+		t.Error("callers should receive independent structs")
+	}
+}
+
+func TestRequestCoalescingOffByDefault(t *testing.T) {
+	hc := newHTTPClient("sk_test", "http://localhost", time.Second, &http.Client{})
+	if _, ok := hc.coalesceKey("GET", "/subscription/user_1", nil); ok {
+		t.Error("coalescing should be off by default")
+	}
+}
+
+func TestCoalesceKey(t *testing.T) {
+	hc := newHTTPClient("sk_test", "http://localhost", time.Second, &http.Client{})
+	hc.coalesce = true
+	other := "sk_other"
+	var header http.Header
+
+	tests := []struct {
+		name   string
+		method string
+		opts   *requestOptions
+		want   string
+		ok     bool
+	}{
+		{"plain GET", "GET", nil, "sk_test http://localhost/x", true},
+		{"params", "GET", &requestOptions{Params: map[string]string{"page": "2"}}, "sk_test http://localhost/x?page=2", true},
+		{"API key override", "GET", &requestOptions{APIKey: &other}, "sk_other http://localhost/x", true},
+		{"POST", "POST", nil, "", false},
+		{"streaming decode", "GET", &requestOptions{Decode: func(io.Reader) error { return nil }}, "", false},
+		{"response header", "GET", &requestOptions{ResponseHeader: &header}, "", false},
+		{"extra header", "GET", &requestOptions{Header: http.Header{"If-None-Match": {"v1"}}}, "", false},
+	}
+	for _, tt := range tests {
+		key, ok := hc.coalesceKey(tt.method, "/x", tt.opts)
+		if key != tt.want || ok != tt.ok {
+			t.Errorf("%s: coalesceKey = %q, %v; want %q, %v", tt.name, key, ok, tt.want, tt.ok)
+		}
+	}
+
+	hc.timeout = 0
+	if _, ok := hc.coalesceKey("GET", "/x", nil); ok {
+		t.Error("requests should not coalesce without a client timeout")
+	}
+
+	hc.timeout = time.Second
+	hc.baseURL = "http://bad host"
+	if _, ok := hc.coalesceKey("GET", "/x", &requestOptions{Params: map[string]string{"a": "b"}}); ok {
+		t.Error("unparseable URL should not coalesce")
+	}
+}
+
+func TestRequestCoalescingHonorsEachCallersContext(t *testing.T) {
+	var hits atomic.Int32
+	release := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		hits.Add(1)
+		<-release
+		w.WriteHeader(200)
+		_, _ = w.Write([]byte(`{"id":"sub_1","status":"active"}`))
+	}))
+	defer srv.Close()
+
+	client, err := NewClient("sk_test", WithBaseURL(srv.URL), WithRequestCoalescing())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The caller that starts the flight gives up; the one that joined it
+	// still gets the shared result.
+	leaderCtx, cancelLeader := context.WithCancel(context.Background())
+	leaderErr := make(chan error, 1)
+	go func() {
+		_, err := client.Subscription.Retrieve(leaderCtx, "user_1")
+		leaderErr <- err
+	}()
+	key := "sk_test " + srv.URL + "/subscription/user_1"
+	waitForFlight(t, &client.hc.flights, key)
+
+	var sub *Subscription
+	var joinErr error
+	joined := make(chan struct{})
+	go func() {
+		defer close(joined)
+		sub, joinErr = client.Subscription.Retrieve(context.Background(), "user_1")
+	}()
+	waitForDups(t, &client.hc.flights, key, 1)
+
+	cancelLeader()
+	if err := <-leaderErr; !errors.Is(err, context.Canceled) {
+		t.Errorf("canceled caller: err = %v, want context.Canceled", err)
+	}
+
+	// A joined caller whose deadline passes stops waiting too.
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, err := client.Subscription.Retrieve(ctx, "user_1"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("timed-out caller: err = %v, want context.DeadlineExceeded", err)
+	}
+
+	close(release)
+	<-joined
+	if joinErr != nil {
+		t.Fatalf("joined caller: %v", joinErr)
+	}
+	if sub.ID != "sub_1" {
+		t.Errorf("joined caller: ID = %q", sub.ID)
+	}
+	if got := hits.Load(); got != 1 {
+		t.Errorf("server hits = %d, want 1", got)
+	}
+}
+
+// waitForFlight blocks until a flight for key is in progress.
+func waitForFlight(t *testing.T, g *flightGroup, key string) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		g.mu.Lock()
+		_, ok := g.calls[key]
+		g.mu.Unlock()
+		if ok {
+			return
+		}
+		time.Sleep(time.Millisecond)
+	}
+	t.Fatal("timed out waiting for the flight to start")
+}
+
+func TestRequestCoalescingSharedCallKeepsStarterDeadline(t *testing.T) {
+	release := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {
+		<-release
+	}))
+	defer srv.Close()
+	// Release the hanging handlers before the server waits for them.
+	defer close(release)
+
+	client, err := NewClient("sk_test", WithBaseURL(srv.URL), WithRequestCoalescing(), WithTimeout(time.Minute))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The server never answers. The shared call gives up at the deadline of
+	// the caller that started it, releasing a joined caller without one.
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	starterErr := make(chan error, 1)
+	go func() {
+		_, err := client.Subscription.Retrieve(ctx, "user_1")
+		starterErr <- err
+	}()
+	key := "sk_test " + srv.URL + "/subscription/user_1"
+	waitForFlight(t, &client.hc.flights, key)
+
+	joinErr := make(chan error, 1)
+	go func() {
+		_, err := client.Subscription.Retrieve(context.Background(), "user_1")
+		joinErr <- err
+	}()
+	waitForDups(t, &client.hc.flights, key, 1)
+
+	if err := <-starterErr; !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("starter: err = %v, want context.DeadlineExceeded", err)
+	}
+	select {
+	case err := <-joinErr:
+		if !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("joined caller: err = %v, want context.DeadlineExceeded", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("joined caller still waiting on a shared call past the starter's deadline")
+	}
+}
+
+func TestRequestCoalescingDisabledWithoutClientTimeout(t *testing.T) {
+	var hits atomic.Int32
+	release := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {
+		hits.Add(1)
+		<-release
+	}))
+	defer srv.Close()
+	// Release the hanging handlers before the server waits for them.
+	defer close(release)
+
+	client, err := NewClient("sk_test", WithBaseURL(srv.URL), WithRequestCoalescing(), WithTimeout(0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var wg sync.WaitGroup
+	for i := 0; i < 2; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+			defer cancel()
+			if _, err := client.Subscription.Retrieve(ctx, "user_1"); !errors.Is(err, context.DeadlineExceeded) {
+				t.Errorf("err = %v, want context.DeadlineExceeded", err)
+			}
+		}()
+	}
+	wg.Wait()
+	if n := hits.Load(); n != 2 {
+		t.Errorf("server hits = %d, want 2 individual requests", n)
+	}
+	if len(client.hc.flights.calls) != 0 {
+		t.Error("no flight should be left running")
+	}
+}
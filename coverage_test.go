@@ -7,6 +7,7 @@ import (
 	"io"
 	"math"
 	"net/http"
+	"strings"
 	"testing"
 	"time"
 )
@@ -131,3 +132,95 @@ func TestCancelAPIErrorPropagation(t *testing.T) {
 		t.Fatal("expected error")
 	}
 }
+
+// truncatedReader returns its data and then fails with io.ErrUnexpectedEOF,
+// as a response body does when the connection drops mid-transfer.
+type truncatedReader struct{ data []byte }
+
+func (r *truncatedReader) Read(p []byte) (int, error) {
+	if len(r.data) == 0 {
+		return 0, io.ErrUnexpectedEOF
+	}
+	n := copy(p, r.data)
+	r.data = r.data[n:]
+	return n, nil
+}
+
+func TestHandleResponseTruncatedRead(t *testing.T) {
+	hc := newHTTPClient("sk_test", "http://localhost", 10*time.Second, &http.Client{})
+	resp := &http.Response{
+		StatusCode: 200,
+		Header:     http.Header{},
+		Body:       io.NopCloser(&truncatedReader{data: []byte(`{"id": "sub_`)}),
+	}
+	_, err := hc.handleResponse(resp)
+	var connErr *APIConnectionError
+	if !errors.As(err, &connErr) {
+		t.Fatalf("expected *APIConnectionError, got %T: %v", err, err)
+	}
+	if !strings.Contains(connErr.Message, "Incomplete response body") {
+		t.Errorf("Message = %q", connErr.Message)
+	}
+}
+
+func TestHandleResponsePartialJSONThenEOF(t *testing.T) {
+	hc := newHTTPClient("sk_test", "http://localhost", 10*time.Second, &http.Client{})
+	resp := &http.Response{
+		StatusCode: 200,
+		Header:     http.Header{},
+		Body:       io.NopCloser(strings.NewReader(`{"id": "sub_1", "status": "act`)),
+	}
+	_, err := hc.handleResponse(resp)
+	var connErr *APIConnectionError
+	if !errors.As(err, &connErr) {
+		t.Fatalf("expected *APIConnectionError, got %T: %v", err, err)
+	}
+	if !strings.Contains(connErr.Message, "Incomplete response body") {
+		t.Errorf("Message = %q", connErr.Message)
+	}
+}
+
+// roundTripFunc adapts a function to an http.RoundTripper.
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(r *http.Request) (*http.Response, error) { return f(r) }
+
+func TestRequestListTruncatedBody(t *testing.T) {
+	const body = `{"items": [{"id": "inv_1"}`
+	// The body is cut short either by the connection or by the server
+	// ending a well-framed response mid-document.
+	for _, r := range []io.Reader{&truncatedReader{data: []byte(body)}, strings.NewReader(body)} {
+		hc := newHTTPClient("sk_test", "http://localhost", 10*time.Second, &http.Client{
+			Transport: roundTripFunc(func(*http.Request) (*http.Response, error) {
+				return &http.Response{
+					StatusCode: 200,
+					Header:     http.Header{},
+					Body:       io.NopCloser(r),
+				}, nil
+			}),
+		})
+		_, err := requestList[Invoice](context.Background(), hc, "/invoices", nil, nil)
+		var connErr *APIConnectionError
+		if !errors.As(err, &connErr) {
+			t.Fatalf("expected *APIConnectionError, got %T: %v", err, err)
+		}
+	}
+}
+
+func TestIsTruncatedJSON(t *testing.T) {
+	tests := []struct {
+		body string
+		want bool
+	}{
+		{`{"id": "sub`, true},
+		{`[1, 2`, true},
+		{``, false},
+		{`not json`, false},
+		{`{"id": "sub_1"}`, false},
+	}
+	for _, tt := range tests {
+		if got := isTruncatedJSON([]byte(tt.body)); got != tt.want {
+			t.Errorf("isTruncatedJSON(%q) = %v, want %v", tt.body, got, tt.want)
+		}
+	}
+}
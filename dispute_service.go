@@ -0,0 +1,74 @@
+package paylio
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"sort"
+	"strings"
+)
+
+// DisputeService provides methods for interacting with disputes.
+type DisputeService struct {
+	http *httpClient
+}
+
+func newDisputeService(hc *httpClient) *DisputeService {
+	return &DisputeService{http: hc}
+}
+
+// SubmitEvidence uploads evidence for a dispute as multipart/form-data. Each
+// entry in files is sent as a file part named by its key; fields are sent as
+// plain form values.
+func (s *DisputeService) SubmitEvidence(ctx context.Context, disputeID string, files map[string]io.Reader, fields map[string]string, opts ...RequestOption) (*Dispute, error) {
+	if strings.TrimSpace(disputeID) == "" {
+		return nil, errors.New("disputeID is required")
+	}
+	if len(files) == 0 && len(fields) == 0 {
+		return nil, errors.New("evidence files or fields are required")
+	}
+	body, contentType, err := encodeMultipart(files, fields)
+	if err != nil {
+		return nil, err
+	}
+	data, err := s.http.request(ctx, "POST", fmt.Sprintf("/disputes/%s/evidence", disputeID), applyRequestOptions(&requestOptions{
+		RawBody:     body,
+		ContentType: contentType,
+	}, opts))
+	if err != nil {
+		return nil, err
+	}
+	return decodeResource[Dispute](s.http, data)
+}
+
+// encodeMultipart builds a multipart/form-data body, writing parts in key
+// order so the output is deterministic. Writes to the in-memory buffer cannot
+// fail, so only reading a file can produce an error.
+func encodeMultipart(files map[string]io.Reader, fields map[string]string) ([]byte, string, error) {
+	var buf bytes.Buffer
+	mw := multipart.NewWriter(&buf)
+
+	for _, name := range sortedKeys(fields) {
+		_ = mw.WriteField(name, fields[name])
+	}
+	for _, name := range sortedKeys(files) {
+		part, _ := mw.CreateFormFile(name, name)
+		if _, err := io.Copy(part, files[name]); err != nil {
+			return nil, "", fmt.Errorf("failed to read file %q: %w", name, err)
+		}
+	}
+	_ = mw.Close()
+	return buf.Bytes(), mw.FormDataContentType(), nil
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,116 @@
+package paylio
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newTestDisputeService(handler http.HandlerFunc) (*DisputeService, *httptest.Server) {
+	srv := httptest.NewServer(handler)
+	hc := newHTTPClient("sk_test", srv.URL, 10*time.Second, srv.Client())
+	return newDisputeService(hc), srv
+}
+
+func TestSubmitEvidenceSendsMultipart(t *testing.T) {
+	svc, srv := newTestDisputeService(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "POST" {
+			t.Errorf("Method = %q", r.Method)
+		}
+		if r.URL.Path != "/disputes/dp_1/evidence" {
+			t.Errorf("Path = %q", r.URL.Path)
+		}
+		if ct := r.Header.Get("Content-Type"); !strings.HasPrefix(ct, "multipart/form-data; boundary=") {
+			t.Errorf("Content-Type = %q", ct)
+		}
+		if got := r.Header.Get("X-API-Key"); got != "sk_test" {
+			t.Errorf("X-API-Key = %q", got)
+		}
+		if err := r.ParseMultipartForm(1 << 20); err != nil {
+			t.Fatal(err)
+		}
+		if got := r.FormValue("explanation"); got != "customer used the service" {
+			t.Errorf("explanation = %q", got)
+		}
+		f, hdr, err := r.FormFile("receipt")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer f.Close()
+		content, _ := io.ReadAll(f)
+		if string(content) != "%PDF-receipt" {
+			t.Errorf("receipt content = %q", content)
+		}
+		if hdr.Filename != "receipt" {
+			t.Errorf("receipt filename = %q", hdr.Filename)
+		}
+		if _, _, err := r.FormFile("logs"); err != nil {
+			t.Errorf("logs part missing: %v", err)
+		}
+		w.WriteHeader(200)
+		_, _ = w.Write([]byte(`{"id":"dp_1","status":"under_review","amount":9.99,"currency":"usd"}`))
+	})
+	defer srv.Close()
+
+	dispute, err := svc.SubmitEvidence(context.Background(), "dp_1",
+		map[string]io.Reader{
+			"receipt": strings.NewReader("%PDF-receipt"),
+			"logs":    strings.NewReader("login at 10:00"),
+		},
+		map[string]string{"explanation": "customer used the service"},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if dispute.ID != "dp_1" || dispute.Status != "under_review" {
+		t.Errorf("dispute = %+v", dispute)
+	}
+}
+
+func TestSubmitEvidenceValidation(t *testing.T) {
+	svc, srv := newTestDisputeService(func(w http.ResponseWriter, _ *http.Request) {
+		t.Error("request should not be sent")
+	})
+	defer srv.Close()
+
+	if _, err := svc.SubmitEvidence(context.Background(), "", nil, map[string]string{"a": "b"}); err == nil || err.Error() != "disputeID is required" {
+		t.Errorf("err = %v", err)
+	}
+	if _, err := svc.SubmitEvidence(context.Background(), "dp_1", nil, nil); err == nil || err.Error() != "evidence files or fields are required" {
+		t.Errorf("err = %v", err)
+	}
+}
+
+func TestSubmitEvidenceFileReadError(t *testing.T) {
+	svc, srv := newTestDisputeService(func(w http.ResponseWriter, _ *http.Request) {
+		t.Error("request should not be sent")
+	})
+	defer srv.Close()
+
+	_, err := svc.SubmitEvidence(context.Background(), "dp_1", map[string]io.Reader{"receipt": errReader{}}, nil)
+	if err == nil || !strings.Contains(err.Error(), `failed to read file "receipt"`) {
+		t.Errorf("err = %v", err)
+	}
+}
+
+func TestSubmitEvidenceAPIErrorPropagation(t *testing.T) {
+	svc, srv := newTestDisputeService(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(400)
+		_, _ = w.Write([]byte(`{"error":{"code":"invalid_param","message":"file too large","param":"receipt"}}`))
+	})
+	defer srv.Close()
+
+	_, err := svc.SubmitEvidence(context.Background(), "dp_1", nil, map[string]string{"note": "x"})
+	var invErr *InvalidRequestError
+	if !errors.As(err, &invErr) {
+		t.Fatalf("expected *InvalidRequestError, got %T: %v", err, err)
+	}
+	if invErr.Param != "receipt" {
+		t.Errorf("Param = %q", invErr.Param)
+	}
+}
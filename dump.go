@@ -0,0 +1,100 @@
+package paylio
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+)
+
+// redactedHeaders lists request headers whose values are replaced in dumps.
+var redactedHeaders = []string{"X-API-Key", "Authorization"}
+
+// dumpRequest writes a sanitized copy of req to hc.dump, if set: the request
+// line, headers with credentials redacted, and payload, the body before any
+// compression, with hc.redactFields masked. The dump is written in a single
+// call so concurrent requests do not interleave.
+func (hc *httpClient) dumpRequest(req *http.Request, payload []byte) {
+	if hc.dump == nil {
+		return
+	}
+	var buf bytes.Buffer
+	buf.WriteString(req.Method + " " + hc.redactURL(req.URL) + "\r\n")
+	header := req.Header.Clone()
+	for _, k := range redactedHeaders {
+		if header.Get(k) != "" {
+			header.Set(k, "[REDACTED]")
+		}
+	}
+	// Writes to a bytes.Buffer cannot fail.
+	_ = header.Write(&buf)
+	buf.WriteString("\r\n")
+	if payload != nil {
+		buf.Write(hc.redactBody(payload))
+		buf.WriteString("\r\n")
+	}
+
+	hc.dumpMu.Lock()
+	defer hc.dumpMu.Unlock()
+	_, _ = hc.dump.Write(buf.Bytes())
+}
+
+// redactBody returns a JSON body with the values of hc.redactFields keys, at
+// any depth, replaced by "***". Other bodies are returned unchanged.
+func (hc *httpClient) redactBody(b []byte) []byte {
+	if len(hc.redactFields) == 0 {
+		return b
+	}
+	var v any
+	if unmarshalJSON(b, &v) != nil {
+		return b
+	}
+	// v was decoded from JSON, so it marshals.
+	redacted, _ := json.Marshal(hc.redactValue(v))
+	return redacted
+}
+
+func (hc *httpClient) redactValue(v any) any {
+	switch v := v.(type) {
+	case map[string]any:
+		for k, elem := range v {
+			if hc.redactFields[k] {
+				v[k] = "***"
+			} else {
+				v[k] = hc.redactValue(elem)
+			}
+		}
+	case []any:
+		for i, elem := range v {
+			v[i] = hc.redactValue(elem)
+		}
+	}
+	return v
+}
+
+// redactURL returns u as a string with the API key query parameter, if the
+// client sends one, replaced.
+func (hc *httpClient) redactURL(u *url.URL) string {
+	if hc.apiKeyQueryParam == "" || !u.Query().Has(hc.apiKeyQueryParam) {
+		return u.String()
+	}
+	redacted := *u
+	q := u.Query()
+	q.Set(hc.apiKeyQueryParam, "[REDACTED]")
+	redacted.RawQuery = q.Encode()
+	return redacted.String()
+}
+
+// redactURLError removes the API key from the URL that http.Client.Do
+// includes in its errors, so it does not leak into error messages.
+func (hc *httpClient) redactURLError(err error) error {
+	var urlErr *url.Error
+	if hc.apiKeyQueryParam == "" || !errors.As(err, &urlErr) {
+		return err
+	}
+	// urlErr.URL was produced by URL.String in net/http, so it parses.
+	u, _ := url.Parse(urlErr.URL)
+	urlErr.URL = hc.redactURL(u)
+	return err
+}
@@ -0,0 +1,215 @@
+package paylio
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestWithRequestDumpRedactsAPIKey(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if got := r.Header.Get("X-API-Key"); got != "sk_secret_key" {
+			t.Errorf("X-API-Key sent = %q, want the real key", got)
+		}
+		w.WriteHeader(200)
+		_, _ = w.Write([]byte(`{"id":"re_1"}`))
+	}))
+	defer srv.Close()
+
+	var dump bytes.Buffer
+	client, err := NewClient("sk_secret_key", WithBaseURL(srv.URL), WithRequestDump(&dump),
+		WithBeforeRequest(func(r *http.Request) error {
+			r.Header.Set("Authorization", "Bearer tok_secret")
+			return nil
+		}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = client.Refunds.Create(context.Background(), &CreateRefundParams{SubscriptionID: "sub_1", Amount: 5})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got := dump.String()
+	if !strings.HasPrefix(got, "POST "+srv.URL+"/refunds\r\n") {
+		t.Errorf("dump does not start with the request line:\n%s", got)
+	}
+	if strings.Contains(got, "sk_secret_key") || strings.Contains(got, "tok_secret") {
+		t.Errorf("dump leaks credentials:\n%s", got)
+	}
+	if !strings.Contains(got, "X-Api-Key: [REDACTED]\r\n") || !strings.Contains(got, "Authorization: [REDACTED]\r\n") {
+		t.Errorf("dump missing redacted headers:\n%s", got)
+	}
+	if !strings.Contains(got, `{"amount":5,"subscription_id":"sub_1"}`) {
+		t.Errorf("dump missing body:\n%s", got)
+	}
+}
+
+func TestWithRequestDumpWithoutBody(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(200)
+		_, _ = w.Write([]byte(`{"id":"sub_1"}`))
+	}))
+	defer srv.Close()
+
+	var dump bytes.Buffer
+	client, err := NewClient("sk_test", WithBaseURL(srv.URL), WithRequestDump(&dump))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.Subscription.Retrieve(context.Background(), "user_1"); err != nil {
+		t.Fatal(err)
+	}
+	got := dump.String()
+	if !strings.HasPrefix(got, "GET "+srv.URL+"/subscription/user_1\r\n") {
+		t.Errorf("unexpected dump:\n%s", got)
+	}
+	if !strings.HasSuffix(got, "\r\n\r\n") {
+		t.Errorf("dump should end after the headers:\n%q", got)
+	}
+}
+
+func TestRequestDumpOffByDefault(t *testing.T) {
+	hc := newHTTPClient("sk_test", "http://localhost", 0, &http.Client{})
+	req, _ := http.NewRequest("GET", "http://localhost/x", nil)
+	hc.dumpRequest(req, nil) // must not panic without a writer
+}
+
+func TestWithRequestDumpRedactsAPIKeyInQuery(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(200)
+		_, _ = w.Write([]byte(`{"id":"sub_1"}`))
+	}))
+	defer srv.Close()
+
+	var dump bytes.Buffer
+	client, err := NewClient("sk_secret_key", WithBaseURL(srv.URL), WithRequestDump(&dump), WithAPIKeyInQuery("api_key"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.Subscription.Retrieve(context.Background(), "user_1"); err != nil {
+		t.Fatal(err)
+	}
+	got := dump.String()
+	if strings.Contains(got, "sk_secret_key") {
+		t.Errorf("dump leaks the API key:\n%s", got)
+	}
+	if !strings.HasPrefix(got, "GET "+srv.URL+"/subscription/user_1?api_key=%5BREDACTED%5D\r\n") {
+		t.Errorf("dump request line not redacted:\n%s", got)
+	}
+}
+
+func TestAPIKeyInQueryRedactedFromConnectionErrors(t *testing.T) {
+	hc := &http.Client{Transport: roundTripFunc(func(*http.Request) (*http.Response, error) {
+		return nil, errors.New("connection refused")
+	})}
+	client, err := NewClient("sk_secret_key", WithBaseURL("https://api.example.com"), WithHTTPClient(hc), WithAPIKeyInQuery("api_key"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = client.Subscription.Retrieve(context.Background(), "user_1")
+	var connErr *APIConnectionError
+	if !errors.As(err, &connErr) {
+		t.Fatalf("expected *APIConnectionError, got %T", err)
+	}
+	if strings.Contains(err.Error(), "sk_secret_key") || !strings.Contains(err.Error(), "REDACTED") {
+		t.Errorf("error = %q", err)
+	}
+
+	client, _ = NewClient("sk_secret_key", WithBaseURL("https://api.example.com"), WithHTTPClient(hc))
+	_, err = client.Subscription.Retrieve(context.Background(), "user_1")
+	if strings.Contains(err.Error(), "REDACTED") {
+		t.Errorf("error = %q, want the URL untouched", err)
+	}
+}
+
+func TestWithRedactedFieldsMasksDumpedBody(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if !strings.Contains(string(body), "ada@example.com") {
+			t.Errorf("server should receive the real value, got %s", body)
+		}
+		w.WriteHeader(200)
+		_, _ = w.Write([]byte(`{"id":"sub_1"}`))
+	}))
+	defer srv.Close()
+
+	var dump bytes.Buffer
+	client, err := NewClient("sk_test", WithBaseURL(srv.URL), WithRequestDump(&dump), WithRedactedFields("email", "name"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = client.Subscription.Create(context.Background(), &CreateSubscriptionParams{
+		UserID:   "user_1",
+		PlanSlug: "pro",
+		Metadata: map[string]string{"email": "ada@example.com", "tier": "gold"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := dump.String()
+	if strings.Contains(got, "ada@example.com") {
+		t.Errorf("dump leaks a redacted field:\n%s", got)
+	}
+	if !strings.Contains(got, `"metadata":{"email":"***","tier":"gold"}`) {
+		t.Errorf("dump missing masked body:\n%s", got)
+	}
+}
+
+func TestWithRedactedFieldsMasksCompressedDumpedBody(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if got := r.Header.Get("Content-Encoding"); got != "gzip" {
+			t.Errorf("Content-Encoding = %q, want gzip", got)
+		}
+		w.WriteHeader(200)
+		_, _ = w.Write([]byte(`{"id":"sub_1"}`))
+	}))
+	defer srv.Close()
+
+	var dump bytes.Buffer
+	client, err := NewClient("sk_test", WithBaseURL(srv.URL), WithRequestDump(&dump),
+		WithRequestCompression(), WithRedactedFields("email"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = client.Subscription.Create(context.Background(), &CreateSubscriptionParams{
+		UserID:   "user_1",
+		PlanSlug: "pro",
+		Metadata: map[string]string{"email": "ada@example.com", "notes": strings.Repeat("x", compressionThreshold)},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := dump.String()
+	if !strings.Contains(got, "Content-Encoding: gzip\r\n") {
+		t.Errorf("dump missing Content-Encoding header:\n%s", got)
+	}
+	if strings.Contains(got, "ada@example.com") {
+		t.Errorf("dump leaks a redacted field:\n%s", got)
+	}
+	if !strings.Contains(got, `"email":"***"`) {
+		t.Errorf("dump missing masked JSON body:\n%s", got)
+	}
+}
+
+func TestRedactBody(t *testing.T) {
+	hc := newHTTPClient("sk_test", "http://localhost", DefaultTimeout, http.DefaultClient)
+	if got := string(hc.redactBody([]byte(`{"email":"a@b.c"}`))); got != `{"email":"a@b.c"}` {
+		t.Errorf("without redacted fields: %s", got)
+	}
+	hc.redactFields = map[string]bool{"email": true}
+	tests := []struct{ in, want string }{
+		{`{"users":[{"email":"a@b.c","id":1}],"email":{"nested":true}}`, `{"email":"***","users":[{"email":"***","id":1}]}`},
+		{`--boundary\r\nemail: a@b.c`, `--boundary\r\nemail: a@b.c`},
+	}
+	for _, tt := range tests {
+		if got := string(hc.redactBody([]byte(tt.in))); got != tt.want {
+			t.Errorf("redactBody(%s) = %s, want %s", tt.in, got, tt.want)
+		}
+	}
+}
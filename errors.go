@@ -1,5 +1,32 @@
 package paylio
 
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrClientClosed is returned by service methods called after Client.Close.
+var ErrClientClosed = errors.New("client is closed")
+
+// ErrNotModified is returned when the server responds 304 Not Modified to a
+// conditional request made with WithIfNoneMatch. The caller's cached copy is
+// still current.
+var ErrNotModified = errors.New("not modified")
+
+// ErrAlreadyCanceled is the cause of the error returned by
+// SubscriptionService.Cancel when the subscription was already canceled.
+// Check for it with errors.Is.
+var ErrAlreadyCanceled = errors.New("subscription already canceled")
+
+// ErrRefundFailed is returned, wrapped together with the refund's own error,
+// by SubscriptionService.CancelAndRefund when the cancel succeeded but the
+// refund did not. Check for it with errors.Is.
+var ErrRefundFailed = errors.New("refund failed")
+
 // ErrorParams holds the parameters for constructing a PaylioError.
 type ErrorParams struct {
 	Message    string
@@ -8,6 +35,7 @@ type ErrorParams struct {
 	JSONBody   map[string]any
 	Headers    map[string]string
 	Code       string
+	Param      string
 }
 
 // PaylioError is the base error type for all Paylio SDK errors.
@@ -18,18 +46,91 @@ type PaylioError struct {
 	JSONBody   map[string]any
 	Headers    map[string]string
 	Code       string
+	// Param names the request parameter the API rejected, if any.
+	Param string
+
+	// cause is the underlying error of a connection failure, or a sentinel
+	// such as ErrAlreadyCanceled, if any.
+	cause error
+	// kind is the name of the SDK error type wrapping this error, such as
+	// "InvalidRequestError", or empty for a bare PaylioError.
+	kind string
+	// unsent marks an error the client raised before sending the request.
+	unsent bool
+	// requestID is the X-Request-Id header sent with the failed request, if
+	// any.
+	requestID string
 }
 
 func (e *PaylioError) Error() string { return e.Message }
 
-func newPaylioError(p ErrorParams) *PaylioError {
+// MarshalJSON encodes the error for structured logs as its message, the
+// name of its concrete SDK error type, HTTP status, code, and the request ID
+// from the X-Request-Id response header. The response body and headers are
+// left out.
+func (e *PaylioError) MarshalJSON() ([]byte, error) {
+	kind := e.kind
+	if kind == "" {
+		kind = "PaylioError"
+	}
+	var requestID string
+	for k, v := range e.Headers {
+		if strings.EqualFold(k, "X-Request-Id") {
+			requestID = v
+		}
+	}
+	return json.Marshal(struct {
+		Message    string `json:"message"`
+		Type       string `json:"type"`
+		HTTPStatus int    `json:"http_status,omitempty"`
+		Code       string `json:"code,omitempty"`
+		RequestID  string `json:"request_id,omitempty"`
+	}{e.Message, kind, e.HTTPStatus, e.Code, requestID})
+}
+
+// Unwrap returns the underlying cause of a connection failure, such as a
+// *net.OpError, a sentinel such as ErrAlreadyCanceled, or nil.
+func (e *PaylioError) Unwrap() error { return e.cause }
+
+// ErrorCode is a machine-readable error code returned by the API. Codes the
+// SDK does not define constants for are passed through unchanged.
+type ErrorCode string
+
+// Known API error codes.
+const (
+	CodeInvalidParam      ErrorCode = "invalid_param"
+	CodePlanNotFound      ErrorCode = "plan_not_found"
+	CodeAlreadySubscribed ErrorCode = "already_subscribed"
+	CodeAlreadyCanceled   ErrorCode = "already_canceled"
+	CodeMaintenance       ErrorCode = "maintenance"
+	CodeNoUpcomingInvoice ErrorCode = "no_upcoming_invoice"
+)
+
+// ErrorCode returns the error's Code as a typed ErrorCode for use in switch
+// statements against the Code constants.
+func (e *PaylioError) ErrorCode() ErrorCode { return ErrorCode(e.Code) }
+
+// AsPaylioError returns the PaylioError carried by err, whatever its concrete
+// SDK error type, for access to the common fields such as HTTPStatus and
+// Code. It reports false if err is not an SDK error.
+func AsPaylioError(err error) (*PaylioError, bool) {
+	var pe *PaylioError
+	if errors.As(err, &pe) {
+		return pe, true
+	}
+	return nil, false
+}
+
+func newPaylioError(p ErrorParams, kind string) *PaylioError {
 	return &PaylioError{
+		kind:       kind,
 		Message:    p.Message,
 		HTTPStatus: p.HTTPStatus,
 		HTTPBody:   p.HTTPBody,
 		JSONBody:   p.JSONBody,
 		Headers:    p.Headers,
 		Code:       p.Code,
+		Param:      p.Param,
 	}
 }
 
@@ -41,7 +142,7 @@ func (e *APIError) Unwrap() error { return e.PaylioError }
 
 // NewAPIError creates an APIError from the given params.
 func NewAPIError(p ErrorParams) *APIError {
-	return &APIError{newPaylioError(p)}
+	return &APIError{newPaylioError(p, "APIError")}
 }
 
 // AuthenticationError indicates an invalid or missing API key (HTTP 401).
@@ -52,7 +153,7 @@ func (e *AuthenticationError) Unwrap() error { return e.PaylioError }
 
 // NewAuthenticationError creates an AuthenticationError from the given params.
 func NewAuthenticationError(p ErrorParams) *AuthenticationError {
-	return &AuthenticationError{newPaylioError(p)}
+	return &AuthenticationError{newPaylioError(p, "AuthenticationError")}
 }
 
 // InvalidRequestError indicates bad request parameters (HTTP 400).
@@ -63,7 +164,7 @@ func (e *InvalidRequestError) Unwrap() error { return e.PaylioError }
 
 // NewInvalidRequestError creates an InvalidRequestError from the given params.
 func NewInvalidRequestError(p ErrorParams) *InvalidRequestError {
-	return &InvalidRequestError{newPaylioError(p)}
+	return &InvalidRequestError{newPaylioError(p, "InvalidRequestError")}
 }
 
 // NotFoundError indicates a resource was not found (HTTP 404).
@@ -74,7 +175,19 @@ func (e *NotFoundError) Unwrap() error { return e.PaylioError }
 
 // NewNotFoundError creates a NotFoundError from the given params.
 func NewNotFoundError(p ErrorParams) *NotFoundError {
-	return &NotFoundError{newPaylioError(p)}
+	return &NotFoundError{newPaylioError(p, "NotFoundError")}
+}
+
+// ConflictError indicates the request conflicts with the current state of a
+// resource, such as a duplicate create (HTTP 409).
+type ConflictError struct{ *PaylioError }
+
+// Unwrap returns the underlying PaylioError.
+func (e *ConflictError) Unwrap() error { return e.PaylioError }
+
+// NewConflictError creates a ConflictError from the given params.
+func NewConflictError(p ErrorParams) *ConflictError {
+	return &ConflictError{newPaylioError(p, "ConflictError")}
 }
 
 // RateLimitError indicates rate limit exceeded (HTTP 429).
@@ -83,9 +196,46 @@ type RateLimitError struct{ *PaylioError }
 // Unwrap returns the underlying PaylioError.
 func (e *RateLimitError) Unwrap() error { return e.PaylioError }
 
+// RetryAfter returns how long the server asked the client to wait before
+// retrying, parsed from the Retry-After header as either delay-seconds or an
+// HTTP date. It reports false when the header is absent or malformed.
+func (e *RateLimitError) RetryAfter() (time.Duration, bool) {
+	return e.retryAfter(time.Now())
+}
+
+// retryAfter is RetryAfter with HTTP dates measured from now.
+func (e *RateLimitError) retryAfter(now time.Time) (time.Duration, bool) {
+	return parseRetryAfter(e.Headers["Retry-After"], now)
+}
+
 // NewRateLimitError creates a RateLimitError from the given params.
 func NewRateLimitError(p ErrorParams) *RateLimitError {
-	return &RateLimitError{newPaylioError(p)}
+	return &RateLimitError{newPaylioError(p, "RateLimitError")}
+}
+
+// MaintenanceError indicates the API is down for planned maintenance: a 503
+// response with the maintenance error code. Unlike a generic 5xx APIError,
+// the outage is expected to end, usually by the time RetryAfter reports.
+type MaintenanceError struct{ *PaylioError }
+
+// Unwrap returns the underlying PaylioError.
+func (e *MaintenanceError) Unwrap() error { return e.PaylioError }
+
+// RetryAfter returns how long the server expects the maintenance to last,
+// parsed from the Retry-After header as either delay-seconds or an HTTP
+// date. It reports false when the header is absent or malformed.
+func (e *MaintenanceError) RetryAfter() (time.Duration, bool) {
+	return e.retryAfter(time.Now())
+}
+
+// retryAfter is RetryAfter with HTTP dates measured from now.
+func (e *MaintenanceError) retryAfter(now time.Time) (time.Duration, bool) {
+	return parseRetryAfter(e.Headers["Retry-After"], now)
+}
+
+// NewMaintenanceError creates a MaintenanceError from the given params.
+func NewMaintenanceError(p ErrorParams) *MaintenanceError {
+	return &MaintenanceError{newPaylioError(p, "MaintenanceError")}
 }
 
 // APIConnectionError indicates a network failure or timeout.
@@ -96,7 +246,29 @@ func (e *APIConnectionError) Unwrap() error { return e.PaylioError }
 
 // NewAPIConnectionError creates an APIConnectionError from the given params.
 func NewAPIConnectionError(p ErrorParams) *APIConnectionError {
-	return &APIConnectionError{newPaylioError(p)}
+	return &APIConnectionError{newPaylioError(p, "APIConnectionError")}
+}
+
+// parseRetryAfter parses a Retry-After header value, measuring an HTTP date
+// from now.
+func parseRetryAfter(v string, now time.Time) (time.Duration, bool) {
+	if v == "" {
+		return 0, false
+	}
+	if secs, err := strconv.Atoi(v); err == nil {
+		if secs < 0 {
+			return 0, false
+		}
+		return time.Duration(secs) * time.Second, true
+	}
+	t, err := http.ParseTime(v)
+	if err != nil {
+		return 0, false
+	}
+	if d := t.Sub(now); d > 0 {
+		return d, true
+	}
+	return 0, true
 }
 
 // errorClassForStatus returns the appropriate error for the given HTTP status.
@@ -108,8 +280,15 @@ func errorClassForStatus(status int, p ErrorParams) error {
 		return NewInvalidRequestError(p)
 	case 404:
 		return NewNotFoundError(p)
+	case 409:
+		return NewConflictError(p)
 	case 429:
 		return NewRateLimitError(p)
+	case 503:
+		if ErrorCode(p.Code) == CodeMaintenance {
+			return NewMaintenanceError(p)
+		}
+		return NewAPIError(p)
 	default:
 		return NewAPIError(p)
 	}
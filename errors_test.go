@@ -1,8 +1,15 @@
 package paylio
 
 import (
+	"encoding/json"
 	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"reflect"
+	"strings"
 	"testing"
+	"time"
 )
 
 func TestPaylioErrorImplementsError(t *testing.T) {
@@ -67,6 +74,42 @@ func TestPaylioErrorReturnsMessage(t *testing.T) {
 	}
 }
 
+func TestPaylioErrorMarshalJSON(t *testing.T) {
+	err := NewInvalidRequestError(ErrorParams{
+		Message:    "Invalid plan",
+		HTTPStatus: 400,
+		HTTPBody:   `{"error":{"code":"invalid_param","message":"Invalid plan","card":"4242"}}`,
+		Headers:    map[string]string{"X-Request-Id": "req_123"},
+		Code:       "invalid_param",
+	})
+	data, mErr := json.Marshal(err)
+	if mErr != nil {
+		t.Fatal(mErr)
+	}
+	if strings.Contains(string(data), "4242") {
+		t.Errorf("JSON leaks the HTTP body: %s", data)
+	}
+	var got map[string]any
+	if err := json.Unmarshal(data, &got); err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]any{
+		"message":     "Invalid plan",
+		"type":        "InvalidRequestError",
+		"http_status": float64(400),
+		"code":        "invalid_param",
+		"request_id":  "req_123",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("JSON = %v, want %v", got, want)
+	}
+
+	data, _ = json.Marshal(&PaylioError{Message: "boom"})
+	if string(data) != `{"message":"boom","type":"PaylioError"}` {
+		t.Errorf("bare PaylioError JSON = %s", data)
+	}
+}
+
 func TestNewErrorConstructors(t *testing.T) {
 	params := ErrorParams{
 		Message:    "test error",
@@ -75,6 +118,7 @@ func TestNewErrorConstructors(t *testing.T) {
 		JSONBody:   map[string]any{"k": "v"},
 		Headers:    map[string]string{"h": "v"},
 		Code:       "err_code",
+		Param:      "page",
 	}
 
 	tests := []struct {
@@ -85,6 +129,7 @@ func TestNewErrorConstructors(t *testing.T) {
 		{"AuthenticationError", func(p ErrorParams) error { return NewAuthenticationError(p) }},
 		{"InvalidRequestError", func(p ErrorParams) error { return NewInvalidRequestError(p) }},
 		{"NotFoundError", func(p ErrorParams) error { return NewNotFoundError(p) }},
+		{"ConflictError", func(p ErrorParams) error { return NewConflictError(p) }},
 		{"RateLimitError", func(p ErrorParams) error { return NewRateLimitError(p) }},
 		{"APIConnectionError", func(p ErrorParams) error { return NewAPIConnectionError(p) }},
 	}
@@ -115,6 +160,9 @@ func TestNewErrorConstructors(t *testing.T) {
 			if pe.Code != "err_code" {
 				t.Errorf("PaylioError.Code = %q", pe.Code)
 			}
+			if pe.Param != "page" {
+				t.Errorf("PaylioError.Param = %q", pe.Param)
+			}
 		})
 	}
 }
@@ -142,6 +190,11 @@ func TestErrorsAsSpecificTypes(t *testing.T) {
 		t.Error("errors.As(*NotFoundError) failed")
 	}
 
+	var conflictErr *ConflictError
+	if !errors.As(NewConflictError(params), &conflictErr) {
+		t.Error("errors.As(*ConflictError) failed")
+	}
+
 	var rateLimitErr *RateLimitError
 	if !errors.As(NewRateLimitError(params), &rateLimitErr) {
 		t.Error("errors.As(*RateLimitError) failed")
@@ -153,6 +206,39 @@ func TestErrorsAsSpecificTypes(t *testing.T) {
 	}
 }
 
+func TestAsPaylioError(t *testing.T) {
+	tests := []struct {
+		name string
+		err  interface {
+			error
+			Unwrap() error
+		}
+	}{
+		{"APIError", NewAPIError(ErrorParams{Message: "api", HTTPStatus: 500})},
+		{"AuthenticationError", NewAuthenticationError(ErrorParams{Message: "auth", HTTPStatus: 401})},
+		{"InvalidRequestError", NewInvalidRequestError(ErrorParams{Message: "invalid", HTTPStatus: 400})},
+		{"NotFoundError", NewNotFoundError(ErrorParams{Message: "missing", HTTPStatus: 404})},
+		{"ConflictError", NewConflictError(ErrorParams{Message: "conflict", HTTPStatus: 409})},
+		{"RateLimitError", NewRateLimitError(ErrorParams{Message: "slow", HTTPStatus: 429})},
+		{"APIConnectionError", NewAPIConnectionError(ErrorParams{Message: "conn"})},
+	}
+	for _, tt := range tests {
+		wrapped := fmt.Errorf("wrapped: %w", tt.err)
+		pe, ok := AsPaylioError(wrapped)
+		if !ok {
+			t.Errorf("%s: AsPaylioError reported false", tt.name)
+			continue
+		}
+		if pe != tt.err.Unwrap() {
+			t.Errorf("%s: got %p, want the embedded PaylioError", tt.name, pe)
+		}
+	}
+
+	if pe, ok := AsPaylioError(errors.New("plain")); ok || pe != nil {
+		t.Errorf("AsPaylioError(plain) = %v, %v; want nil, false", pe, ok)
+	}
+}
+
 func TestErrorClassForStatus(t *testing.T) {
 	tests := []struct {
 		status   int
@@ -161,6 +247,7 @@ func TestErrorClassForStatus(t *testing.T) {
 		{401, "*paylio.AuthenticationError"},
 		{400, "*paylio.InvalidRequestError"},
 		{404, "*paylio.NotFoundError"},
+		{409, "*paylio.ConflictError"},
 		{429, "*paylio.RateLimitError"},
 		{500, "*paylio.APIError"},
 		{502, "*paylio.APIError"},
@@ -192,3 +279,102 @@ func TestErrorClassForStatus(t *testing.T) {
 		})
 	}
 }
+
+func TestRateLimitErrorRetryAfter(t *testing.T) {
+	tests := []struct {
+		name   string
+		header string
+		want   time.Duration
+		wantOK bool
+	}{
+		{"seconds", "2", 2 * time.Second, true},
+		{"zero", "0", 0, true},
+		{"past date", "Wed, 21 Oct 2015 07:28:00 GMT", 0, true},
+		{"absent", "", 0, false},
+		{"negative", "-5", 0, false},
+		{"garbage", "soon", 0, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := NewRateLimitError(ErrorParams{Headers: map[string]string{"Retry-After": tt.header}})
+			got, ok := e.RetryAfter()
+			if got != tt.want || ok != tt.wantOK {
+				t.Errorf("RetryAfter() = %v, %v; want %v, %v", got, ok, tt.want, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestMaintenanceError(t *testing.T) {
+	hc := newHTTPClient("sk_test", "http://localhost", 10*time.Second, &http.Client{})
+	resp := &http.Response{
+		StatusCode: 503,
+		Header:     http.Header{"Retry-After": {"120"}},
+		Body:       io.NopCloser(strings.NewReader(`{"error":{"code":"maintenance","message":"Scheduled maintenance"}}`)),
+	}
+	_, err := hc.handleResponse(resp)
+	var me *MaintenanceError
+	if !errors.As(err, &me) {
+		t.Fatalf("expected *MaintenanceError, got %T: %v", err, err)
+	}
+	if d, ok := me.RetryAfter(); !ok || d != 2*time.Minute {
+		t.Errorf("RetryAfter() = %v, %v, want 2m", d, ok)
+	}
+	if me.Message != "Scheduled maintenance" || me.HTTPStatus != 503 || !shouldFailover(err) {
+		t.Errorf("maintenance error = %+v", me.PaylioError)
+	}
+
+	resp = &http.Response{
+		StatusCode: 503,
+		Header:     http.Header{},
+		Body:       io.NopCloser(strings.NewReader(`{"error":{"code":"overloaded","message":"Try again"}}`)),
+	}
+	_, err = hc.handleResponse(resp)
+	var apiErr *APIError
+	if errors.As(err, &me) || !errors.As(err, &apiErr) {
+		t.Errorf("generic 503: got %T, want *APIError", err)
+	}
+}
+
+func TestRateLimitErrorRetryAfterFutureDate(t *testing.T) {
+	future := time.Now().Add(time.Hour).UTC().Format(http.TimeFormat)
+	e := NewRateLimitError(ErrorParams{Headers: map[string]string{"Retry-After": future}})
+	got, ok := e.RetryAfter()
+	if !ok || got <= 58*time.Minute || got > time.Hour {
+		t.Errorf("RetryAfter() = %v, %v; want about 1h", got, ok)
+	}
+}
+
+func TestPaylioErrorErrorCode(t *testing.T) {
+	tests := []struct {
+		code string
+		want ErrorCode
+	}{
+		{"invalid_param", CodeInvalidParam},
+		{"plan_not_found", CodePlanNotFound},
+		{"already_subscribed", CodeAlreadySubscribed},
+		{"brand_new_code", ErrorCode("brand_new_code")},
+		{"", ErrorCode("")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.code, func(t *testing.T) {
+			err := NewInvalidRequestError(ErrorParams{Code: tt.code})
+			if got := err.ErrorCode(); got != tt.want {
+				t.Errorf("ErrorCode() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestErrorCodeSwitch(t *testing.T) {
+	var err error = NewConflictError(ErrorParams{Code: "already_subscribed"})
+	var pe *PaylioError
+	if !errors.As(err, &pe) {
+		t.Fatal("errors.As(*PaylioError) failed")
+	}
+	switch pe.ErrorCode() {
+	case CodeAlreadySubscribed:
+	default:
+		t.Errorf("ErrorCode() = %q", pe.ErrorCode())
+	}
+}
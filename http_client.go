@@ -2,13 +2,18 @@ package paylio
 
 import (
 	"bytes"
+	"compress/gzip"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
 	"net/url"
+	"os"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 )
 
@@ -21,82 +26,475 @@ const (
 )
 
 type httpClient struct {
-	apiKey  string
-	baseURL string
-	timeout time.Duration
-	client  *http.Client
+	// apiKey is replaced by keyRefresher, so it is guarded by apiKeyMu.
+	apiKeyMu        sync.RWMutex
+	apiKey          string
+	keyRefresher    func(context.Context) (string, error)
+	baseURL         string
+	failoverBaseURL string
+	timeout         time.Duration
+	maxRetries      int
+	maxRetryDelay   time.Duration
+	maxPageSize     int
+	client          *http.Client
+	clock           clock
+	closed          atomic.Bool
+	// defaultMetadata is merged into the metadata of create, plan change,
+	// and metadata update requests.
+	defaultMetadata map[string]string
+	// defaultHeaders are sent with every request.
+	defaultHeaders http.Header
+	// requestID, when set, generates the X-Request-Id header of each request.
+	requestID func() string
+	// lastRateLimit is the most recently reported rate limit.
+	rateLimitMu   sync.Mutex
+	lastRateLimit *RateLimit
+	// coalesce shares one in-flight response among identical concurrent
+	// GETs; see coalesceKey.
+	coalesce bool
+	flights  flightGroup
+	// strict rejects resources missing required fields; see decodeResource.
+	strict bool
+	// logger, when set, receives diagnostic messages.
+	logger Logger
+	// retryPolicy, when set, computes retry backoff in place of
+	// backoffDelay.
+	retryPolicy RetryPolicy
+	// redactFields names JSON keys whose values are masked in dumps.
+	redactFields map[string]bool
+	// retryableCodes are error codes retried regardless of HTTP status.
+	retryableCodes map[string]bool
+	// breaker, when set, short-circuits requests while the API is failing.
+	breaker *circuitBreaker
+	// compress gzips large JSON request bodies; see compressionThreshold.
+	compress bool
+	// apiKeyQueryParam, when set, names the query parameter carrying the API
+	// key in place of the X-API-Key header.
+	apiKeyQueryParam string
+	// onBackoff, when set, is called before each retry backoff sleep.
+	onBackoff func(wait time.Duration, attempt int)
+	// dump, when set, receives a sanitized copy of each outgoing request.
+	dump   io.Writer
+	dumpMu sync.Mutex
+	// beforeRequest, when set, may modify or veto each outgoing request.
+	beforeRequest func(*http.Request) error
+	// newTransport rebuilds the transport on reset. It is nil when the
+	// caller supplied their own http.Client, which is never modified.
+	newTransport func() *http.Transport
 }
 
 type requestOptions struct {
 	Params   map[string]string
 	JSONBody map[string]any
+	// Expand lists related objects to embed in the response, sent as
+	// repeated expand[] query parameters.
+	Expand []string
+	// RawBody, when set, is sent as-is instead of JSONBody with the given
+	// ContentType.
+	RawBody     []byte
+	ContentType string
+	// APIKey, when non-nil, replaces the client's API key for this request.
+	APIKey *string
+	// Header holds additional headers sent with the request.
+	Header http.Header
+	// ResponseHeader, when non-nil, receives the headers of a response.
+	ResponseHeader *http.Header
+	// Decode, when set, consumes a successful response body directly
+	// instead of buffering it into a map. It may be called once per
+	// attempt. Error responses are always handled by handleResponse.
+	Decode func(io.Reader) error
 }
 
 func newHTTPClient(apiKey, baseURL string, timeout time.Duration, client *http.Client) *httpClient {
 	return &httpClient{
-		apiKey:  apiKey,
-		baseURL: strings.TrimRight(baseURL, "/"),
-		timeout: timeout,
-		client:  client,
+		apiKey:        apiKey,
+		baseURL:       strings.TrimRight(baseURL, "/"),
+		timeout:       timeout,
+		maxRetryDelay: defaultMaxRetryDelay,
+		maxPageSize:   MaxPageSize,
+		client:        client,
+		clock:         realClock{},
 	}
 }
 
+// orBackground returns ctx, or context.Background if ctx is nil, so that
+// callers without a context of their own may pass nil.
+func orBackground(ctx context.Context) context.Context {
+	if ctx == nil {
+		return context.Background()
+	}
+	return ctx
+}
+
 func (hc *httpClient) request(ctx context.Context, method, path string, opts *requestOptions) (map[string]any, error) {
-	fullURL := hc.baseURL + path
+	if hc.closed.Load() {
+		return nil, ErrClientClosed
+	}
+	ctx = orBackground(ctx)
+	if key, ok := hc.coalesceKey(method, path, opts); ok {
+		return hc.flights.do(ctx, key, func(ctx context.Context) (map[string]any, error) {
+			return hc.requestWithRefresh(ctx, method, path, opts)
+		})
+	}
+	return hc.requestWithRefresh(ctx, method, path, opts)
+}
 
-	if opts != nil && opts.Params != nil {
-		u, err := url.Parse(fullURL)
-		if err != nil {
-			return nil, NewAPIConnectionError(ErrorParams{Message: fmt.Sprintf("failed to parse URL: %v", err)})
+// currentAPIKey returns the client's API key.
+func (hc *httpClient) currentAPIKey() string {
+	hc.apiKeyMu.RLock()
+	defer hc.apiKeyMu.RUnlock()
+	return hc.apiKey
+}
+
+// requestWithRefresh performs a request and, if it is rejected with a 401
+// and a key refresher is configured, fetches a fresh API key and retries the
+// request once with it. Requests made with a per-call API key are not
+// refreshed.
+func (hc *httpClient) requestWithRefresh(ctx context.Context, method, path string, opts *requestOptions) (map[string]any, error) {
+	data, err := hc.requestWithRetries(ctx, method, path, opts)
+	var authErr *AuthenticationError
+	if hc.keyRefresher == nil || !errors.As(err, &authErr) || (opts != nil && opts.APIKey != nil) {
+		return data, err
+	}
+	key, refreshErr := hc.keyRefresher(ctx)
+	if refreshErr != nil || strings.TrimSpace(key) == "" {
+		return nil, err
+	}
+	hc.apiKeyMu.Lock()
+	hc.apiKey = key
+	hc.apiKeyMu.Unlock()
+	return hc.requestWithRetries(ctx, method, path, opts)
+}
+
+// requestWithRetries performs a request, retrying it as configured. A retry
+// whose backoff would outlast ctx's deadline is abandoned before it is
+// logged or reported to the backoff callback.
+func (hc *httpClient) requestWithRetries(ctx context.Context, method, path string, opts *requestOptions) (map[string]any, error) {
+	for attempt := 1; ; attempt++ {
+		data, err := hc.guardedAttempt(ctx, method, path, opts)
+		if err == nil || attempt > hc.maxRetries || !hc.shouldRetry(method, opts, err) {
+			return data, err
+		}
+		wait, ok := hc.retryDelay(attempt, err)
+		if !ok || hc.outlastsDeadline(ctx, wait) {
+			return data, err
+		}
+		hc.logRetry(method, path, sentRequestID(err), attempt, err, wait)
+		if hc.onBackoff != nil {
+			hc.onBackoff(wait, attempt)
+		}
+		if hc.sleep(ctx, wait) != nil {
+			return nil, err
+		}
+	}
+}
+
+// guardedAttempt performs one attempt through the circuit breaker, if one
+// is configured, failing fast while it is open.
+func (hc *httpClient) guardedAttempt(ctx context.Context, method, path string, opts *requestOptions) (map[string]any, error) {
+	if hc.breaker == nil {
+		return hc.attempt(ctx, method, path, opts)
+	}
+	ticket, ok := hc.breaker.allow(hc.clock.Now())
+	if !ok {
+		return nil, newCircuitOpenError()
+	}
+	data, err := hc.attempt(ctx, method, path, opts)
+	hc.breaker.record(hc.clock.Now(), ticket, err)
+	return data, err
+}
+
+// attempt performs one request against the primary base URL, failing over
+// to the secondary base URL when configured and appropriate.
+func (hc *httpClient) attempt(ctx context.Context, method, path string, opts *requestOptions) (map[string]any, error) {
+	data, err := hc.requestTo(ctx, hc.baseURL, method, path, opts)
+	if err != nil && hc.failoverBaseURL != "" && ctx.Err() == nil && isIdempotent(method) && shouldFailover(err) {
+		return hc.requestTo(ctx, hc.failoverBaseURL, method, path, opts)
+	}
+	return data, err
+}
+
+// requestTo performs a single request against the given base URL.
+func (hc *httpClient) requestTo(ctx context.Context, baseURL, method, path string, opts *requestOptions) (_ map[string]any, err error) {
+	callerCtx := ctx
+	if hc.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, hc.timeout)
+		defer cancel()
+	}
+
+	req, payload, err := hc.newRequest(ctx, baseURL, method, path, opts)
+	if err != nil {
+		if pe, ok := AsPaylioError(err); ok {
+			pe.unsent = true
+		}
+		return nil, err
+	}
+	defer func() {
+		if pe, ok := AsPaylioError(err); ok {
+			pe.requestID = req.Header.Get("X-Request-Id")
+		}
+	}()
+	hc.dumpRequest(req, payload)
+
+	resp, err := hc.client.Do(req)
+	if err != nil {
+		if ctx.Err() == context.DeadlineExceeded {
+			connErr := NewAPIConnectionError(ErrorParams{Message: "Request timed out"})
+			// Only the caller's own deadline, which may span many requests
+			// such as the pages of an iterator, matches
+			// context.DeadlineExceeded; the client timeout is reported as
+			// a transient os.ErrDeadlineExceeded so it is retried.
+			connErr.cause = os.ErrDeadlineExceeded
+			if callerCtx.Err() == context.DeadlineExceeded {
+				connErr.cause = context.DeadlineExceeded
+			}
+			return nil, connErr
+		}
+		return nil, newConnectionError(hc.redactURLError(err))
+	}
+	defer resp.Body.Close()
+
+	hc.recordRateLimit(resp.Header)
+	if opts != nil && opts.ResponseHeader != nil {
+		*opts.ResponseHeader = resp.Header
+	}
+	if opts != nil && opts.Decode != nil && resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		if err := opts.Decode(resp.Body); err != nil {
+			if errors.Is(err, io.ErrUnexpectedEOF) {
+				return nil, newIncompleteBodyError()
+			}
+			return nil, NewAPIError(ErrorParams{
+				Message:    "Invalid JSON in response body",
+				HTTPStatus: resp.StatusCode,
+			})
+		}
+		return nil, nil
+	}
+	if method == http.MethodHead && resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		// HEAD responses carry no body to decode.
+		return map[string]any{}, nil
+	}
+	return hc.handleResponse(resp)
+}
+
+// mergeMetadata combines the client's default metadata with per-call
+// metadata, which wins on key conflicts. It returns nil when both are empty.
+func (hc *httpClient) mergeMetadata(metadata map[string]string) map[string]string {
+	if len(hc.defaultMetadata) == 0 && len(metadata) == 0 {
+		return nil
+	}
+	merged := make(map[string]string, len(hc.defaultMetadata)+len(metadata))
+	for k, v := range hc.defaultMetadata {
+		merged[k] = v
+	}
+	for k, v := range metadata {
+		merged[k] = v
+	}
+	return merged
+}
+
+// requestList validates listOpts and fetches the page of a paginated list
+// they select, retaining the response headers on the result. The body is
+// decoded with the configured JSON unmarshaler; see SetJSONUnmarshaler.
+func requestList[T any](ctx context.Context, hc *httpClient, path string, listOpts *ListOptions, reqOpts []RequestOption) (*PaginatedList[T], error) {
+	if err := listOpts.validate(hc.maxPageSize); err != nil {
+		return nil, err
+	}
+	var list PaginatedList[T]
+	var header http.Header
+	opts := applyRequestOptions(&requestOptions{
+		Params: listOpts.params(),
+		Expand: listOpts.expand(),
+		Decode: func(r io.Reader) error {
+			b, err := io.ReadAll(r)
+			if err != nil {
+				return err
+			}
+			list = PaginatedList[T]{}
+			if err := jsonUnmarshal(b, &list); err != nil {
+				if isTruncatedJSON(b) {
+					return io.ErrUnexpectedEOF
+				}
+				return err
+			}
+			return nil
+		},
+	}, reqOpts)
+	callerHeader := opts.ResponseHeader
+	opts.ResponseHeader = &header
+	if _, err := hc.request(ctx, "GET", path, opts); err != nil {
+		return nil, err
+	}
+	if callerHeader != nil {
+		*callerHeader = header
+	}
+	list.header = header
+	return &list, nil
+}
+
+// stream opens a long-lived request and returns the response for the caller
+// to consume and close. No per-request timeout is applied; ctx alone governs
+// the stream's lifetime. Non-2xx responses are converted to typed errors.
+func (hc *httpClient) stream(ctx context.Context, method, path string, opts *requestOptions) (*http.Response, error) {
+	if hc.closed.Load() {
+		return nil, ErrClientClosed
+	}
+	ctx = orBackground(ctx)
+	req, payload, err := hc.newRequest(ctx, hc.baseURL, method, path, opts)
+	if err != nil {
+		return nil, err
+	}
+	hc.dumpRequest(req, payload)
+
+	resp, err := hc.client.Do(req)
+	if err != nil {
+		return nil, newConnectionError(hc.redactURLError(err))
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		defer resp.Body.Close()
+		_, err := hc.handleResponse(resp)
+		return nil, err
+	}
+	return resp, nil
+}
+
+// compressionThreshold is the JSON body size, in bytes, above which bodies
+// are gzipped when request compression is enabled.
+const compressionThreshold = 1024
+
+// gzipBytes returns b gzip-compressed.
+func gzipBytes(b []byte) []byte {
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	// Writes to a bytes.Buffer cannot fail.
+	_, _ = zw.Write(b)
+	_ = zw.Close()
+	return buf.Bytes()
+}
+
+// buildURL joins baseURL and path and adds params, and each expand field as
+// an expand[] parameter, to the query string.
+func buildURL(baseURL, path string, params map[string]string, expand []string) (string, error) {
+	fullURL := baseURL + path
+	if params == nil && len(expand) == 0 {
+		return fullURL, nil
+	}
+	u, err := url.Parse(fullURL)
+	if err != nil {
+		return "", NewAPIConnectionError(ErrorParams{Message: fmt.Sprintf("failed to parse URL: %v", err)})
+	}
+	q := u.Query()
+	for k, v := range params {
+		q.Set(k, v)
+	}
+	for _, field := range expand {
+		q.Add("expand[]", field)
+	}
+	u.RawQuery = q.Encode()
+	return u.String(), nil
+}
+
+// newRequest builds an authenticated request for baseURL + path, encoding
+// any query parameters and JSON body from opts. It also returns the body as
+// it was before any compression, for dumpRequest.
+func (hc *httpClient) newRequest(ctx context.Context, baseURL, method, path string, opts *requestOptions) (*http.Request, []byte, error) {
+	apiKey := hc.currentAPIKey()
+	if opts != nil && opts.APIKey != nil {
+		if strings.TrimSpace(*opts.APIKey) == "" {
+			return nil, nil, NewAuthenticationError(ErrorParams{Message: "The API key passed to WithAPIKey is empty"})
 		}
-		q := u.Query()
-		for k, v := range opts.Params {
-			q.Set(k, v)
+		apiKey = *opts.APIKey
+	}
+
+	var params map[string]string
+	var expand []string
+	if opts != nil {
+		params, expand = opts.Params, opts.Expand
+	}
+	if hc.apiKeyQueryParam != "" {
+		withKey := make(map[string]string, len(params)+1)
+		for k, v := range params {
+			withKey[k] = v
 		}
-		u.RawQuery = q.Encode()
-		fullURL = u.String()
+		withKey[hc.apiKeyQueryParam] = apiKey
+		params = withKey
+	}
+	fullURL, err := buildURL(baseURL, path, params, expand)
+	if err != nil {
+		return nil, nil, err
 	}
 
 	var body io.Reader
-	if opts != nil && opts.JSONBody != nil {
-		b, err := json.Marshal(opts.JSONBody)
+	var payload []byte
+	contentType := "application/json"
+	compressed := false
+	if opts != nil && opts.RawBody != nil {
+		payload = opts.RawBody
+		body = bytes.NewReader(payload)
+		contentType = opts.ContentType
+	} else if opts != nil && opts.JSONBody != nil {
+		b, err := jsonMarshal(opts.JSONBody)
 		if err != nil {
-			return nil, NewAPIConnectionError(ErrorParams{Message: fmt.Sprintf("failed to marshal body: %v", err)})
+			return nil, nil, NewAPIConnectionError(ErrorParams{Message: fmt.Sprintf("failed to marshal body: %v", err)})
+		}
+		payload = b
+		if hc.compress && (method == http.MethodPost || method == http.MethodPatch) && len(b) > compressionThreshold {
+			b = gzipBytes(b)
+			compressed = true
 		}
 		body = bytes.NewReader(b)
 	}
 
-	ctx, cancel := context.WithTimeout(ctx, hc.timeout)
-	defer cancel()
-
 	req, err := http.NewRequestWithContext(ctx, method, fullURL, body)
 	if err != nil {
-		return nil, NewAPIConnectionError(ErrorParams{Message: fmt.Sprintf("failed to create request: %v", err)})
+		return nil, nil, NewAPIConnectionError(ErrorParams{Message: fmt.Sprintf("failed to create request: %v", err)})
+	}
+	if compressed {
+		req.Header.Set("Content-Encoding", "gzip")
 	}
 
-	req.Header.Set("X-API-Key", hc.apiKey)
-	req.Header.Set("Content-Type", "application/json")
+	if hc.apiKeyQueryParam == "" {
+		req.Header.Set("X-API-Key", apiKey)
+	}
+	req.Header.Set("Content-Type", contentType)
 	req.Header.Set("Accept", "application/json")
-	req.Header.Set("User-Agent", "paylio-go/"+Version)
+	req.Header.Set("User-Agent", UserAgent())
 	req.Header.Set("X-SDK-Source", "go")
+	if hc.requestID != nil {
+		req.Header.Set("X-Request-Id", hc.requestID())
+	}
+	for k, v := range hc.defaultHeaders {
+		req.Header[k] = v
+	}
+	if opts != nil {
+		for k, v := range opts.Header {
+			req.Header[k] = v
+		}
+	}
 
-	resp, err := hc.client.Do(req)
-	if err != nil {
-		if ctx.Err() == context.DeadlineExceeded {
-			return nil, NewAPIConnectionError(ErrorParams{Message: "Request timed out"})
+	if hc.beforeRequest != nil {
+		if err := hc.beforeRequest(req); err != nil {
+			return nil, nil, NewAPIConnectionError(ErrorParams{Message: fmt.Sprintf("before-request hook failed: %v", err)})
 		}
-		return nil, NewAPIConnectionError(ErrorParams{Message: fmt.Sprintf("Connection error: %v", err)})
 	}
-	defer resp.Body.Close()
 
-	return hc.handleResponse(resp)
+	return req, payload, nil
 }
 
 func (hc *httpClient) handleResponse(resp *http.Response) (map[string]any, error) {
 	httpStatus := resp.StatusCode
+	if httpStatus == http.StatusNotModified {
+		return nil, ErrNotModified
+	}
 	bodyBytes, err := io.ReadAll(resp.Body)
+	if errors.Is(err, io.ErrUnexpectedEOF) {
+		return nil, newIncompleteBodyError()
+	}
 	if err != nil {
-		return nil, NewAPIConnectionError(ErrorParams{Message: fmt.Sprintf("failed to read response body: %v", err)})
+		connErr := NewAPIConnectionError(ErrorParams{Message: fmt.Sprintf("failed to read response body: %v", err)})
+		connErr.cause = err
+		return nil, connErr
 	}
 	httpBody := string(bodyBytes)
 
@@ -108,11 +506,14 @@ func (hc *httpClient) handleResponse(resp *http.Response) (map[string]any, error
 	}
 
 	var jsonBody map[string]any
-	if err := json.Unmarshal(bodyBytes, &jsonBody); err != nil {
+	if err := unmarshalJSON(bodyBytes, &jsonBody); err != nil {
 		jsonBody = nil
 	}
 
 	if httpStatus >= 200 && httpStatus < 300 {
+		if jsonBody == nil && isTruncatedJSON(bodyBytes) {
+			return nil, newIncompleteBodyError()
+		}
 		if jsonBody == nil {
 			return nil, NewAPIError(ErrorParams{
 				Message:    "Invalid JSON in response body",
@@ -125,6 +526,7 @@ func (hc *httpClient) handleResponse(resp *http.Response) (map[string]any, error
 
 	errorCode := ""
 	errorMessage := httpBody
+	errorParam := ""
 
 	if jsonBody != nil {
 		if errField, ok := jsonBody["error"]; ok {
@@ -136,12 +538,18 @@ func (hc *httpClient) handleResponse(resp *http.Response) (map[string]any, error
 				if msg, ok := e["message"].(string); ok {
 					errorMessage = msg
 				}
+				if param, ok := e["param"].(string); ok {
+					errorParam = param
+				}
 			case string:
 				errorMessage = e
 			}
 		} else if detail, ok := jsonBody["detail"].(string); ok {
 			errorMessage = detail
 		}
+		if param, ok := jsonBody["param"].(string); ok && errorParam == "" {
+			errorParam = param
+		}
 	}
 
 	params := ErrorParams{
@@ -151,11 +559,94 @@ func (hc *httpClient) handleResponse(resp *http.Response) (map[string]any, error
 		JSONBody:   jsonBody,
 		Headers:    headers,
 		Code:       errorCode,
+		Param:      errorParam,
 	}
 
 	return nil, errorClassForStatus(httpStatus, params)
 }
 
+// newConnectionError wraps a transport failure from http.Client.Do, keeping
+// it as the error's cause for retry classification and errors.As.
+func newConnectionError(err error) *APIConnectionError {
+	connErr := NewAPIConnectionError(ErrorParams{Message: fmt.Sprintf("Connection error: %v", err)})
+	connErr.cause = err
+	return connErr
+}
+
+// newIncompleteBodyError reports a response whose body ended before it was
+// complete, typically because the connection dropped mid-transfer.
+func newIncompleteBodyError() error {
+	connErr := NewAPIConnectionError(ErrorParams{Message: "Incomplete response body: connection closed before the full response was received"})
+	connErr.cause = io.ErrUnexpectedEOF
+	return connErr
+}
+
+// isUnsent reports whether err was raised by the client before the request
+// was sent, such as a failed before-request hook or an empty WithAPIKey.
+func isUnsent(err error) bool {
+	pe, ok := AsPaylioError(err)
+	return ok && pe.unsent
+}
+
+// isNetworkFailure reports whether err is a connection error from a failed
+// exchange with the API. Connection errors without a cause are raised by the
+// client itself before anything is sent, such as a failed before-request
+// hook or an unencodable body, and say nothing about the API.
+func isNetworkFailure(err error) bool {
+	var connErr *APIConnectionError
+	return errors.As(err, &connErr) && connErr.cause != nil && !errors.Is(connErr.cause, ErrCircuitOpen)
+}
+
+// sentRequestID returns the X-Request-Id header sent with the request that
+// failed with err, or "" if there was none.
+func sentRequestID(err error) string {
+	if pe, ok := AsPaylioError(err); ok {
+		return pe.requestID
+	}
+	return ""
+}
+
+// isTruncatedJSON reports whether b is the beginning of a JSON value that
+// ends prematurely, as opposed to being empty or malformed.
+func isTruncatedJSON(b []byte) bool {
+	var v any
+	return json.NewDecoder(bytes.NewReader(b)).Decode(&v) == io.ErrUnexpectedEOF
+}
+
+// isIdempotent reports whether requests with the given method can be safely
+// repeated without side effects beyond the first.
+func isIdempotent(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodPut, http.MethodDelete:
+		return true
+	default:
+		return false
+	}
+}
+
+// shouldFailover reports whether err indicates the primary region is
+// unavailable: a network failure or a 503 Service Unavailable.
+func shouldFailover(err error) bool {
+	if isNetworkFailure(err) {
+		return true
+	}
+	pe, ok := AsPaylioError(err)
+	return ok && pe.HTTPStatus == http.StatusServiceUnavailable
+}
+
+// close marks the client closed and releases idle connections. Only the first
+// call has any effect, so it is safe to call repeatedly and concurrently.
 func (hc *httpClient) close() {
-	hc.client.CloseIdleConnections()
+	if hc.closed.CompareAndSwap(false, true) {
+		hc.client.CloseIdleConnections()
+	}
+}
+
+// reset reopens a closed client, installing a fresh transport when the client
+// owns it.
+func (hc *httpClient) reset() {
+	if hc.newTransport != nil {
+		hc.client.Transport = hc.newTransport()
+	}
+	hc.closed.Store(false)
 }
@@ -4,9 +4,11 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"io"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 	"time"
 )
@@ -93,6 +95,7 @@ func TestHTTPClientErrorStatusMapping(t *testing.T) {
 		{401, func(e error) bool { var v *AuthenticationError; return errors.As(e, &v) }, "401->AuthenticationError"},
 		{400, func(e error) bool { var v *InvalidRequestError; return errors.As(e, &v) }, "400->InvalidRequestError"},
 		{404, func(e error) bool { var v *NotFoundError; return errors.As(e, &v) }, "404->NotFoundError"},
+		{409, func(e error) bool { var v *ConflictError; return errors.As(e, &v) }, "409->ConflictError"},
 		{429, func(e error) bool { var v *RateLimitError; return errors.As(e, &v) }, "429->RateLimitError"},
 		{500, func(e error) bool { var v *APIError; return errors.As(e, &v) }, "500->APIError"},
 	}
@@ -256,6 +259,9 @@ func TestHTTPClientTimeout(t *testing.T) {
 	if !errors.As(err, &connErr) {
 		t.Fatalf("expected *APIConnectionError, got %T: %v", err, err)
 	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		t.Error("the client timeout should not match the caller's context.DeadlineExceeded")
+	}
 }
 
 func TestHTTPClientConnectionError(t *testing.T) {
@@ -400,3 +406,273 @@ func TestHTTPClientNoBodyOnGET(t *testing.T) {
 		t.Fatal(err)
 	}
 }
+
+func TestHTTPClientFailoverOnConnectionError(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/sub" {
+			t.Errorf("Path = %q", r.URL.Path)
+		}
+		w.WriteHeader(200)
+		_, _ = w.Write([]byte(`{"id":"sub_1"}`))
+	}))
+	defer srv.Close()
+
+	hc := newHTTPClient("sk_test", "http://127.0.0.1:1", 5*time.Second, &http.Client{})
+	hc.failoverBaseURL = srv.URL
+	data, err := hc.request(context.Background(), "GET", "/sub", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if data["id"] != "sub_1" {
+		t.Errorf("id = %v", data["id"])
+	}
+}
+
+func TestHTTPClientFailoverOn503(t *testing.T) {
+	primary := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(503)
+		_, _ = w.Write([]byte(`{"error":"unavailable"}`))
+	}))
+	defer primary.Close()
+	failover := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(200)
+		_, _ = w.Write([]byte(`{"ok":true}`))
+	}))
+	defer failover.Close()
+
+	hc := newHTTPClient("sk_test", primary.URL, 5*time.Second, &http.Client{})
+	hc.failoverBaseURL = failover.URL
+	data, err := hc.request(context.Background(), "GET", "/sub", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if data["ok"] != true {
+		t.Errorf("data = %v", data)
+	}
+}
+
+func TestHTTPClientNoFailoverForNonIdempotent(t *testing.T) {
+	hits := 0
+	failover := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		hits++
+		w.WriteHeader(200)
+		_, _ = w.Write([]byte(`{"ok":true}`))
+	}))
+	defer failover.Close()
+
+	hc := newHTTPClient("sk_test", "http://127.0.0.1:1", 5*time.Second, &http.Client{})
+	hc.failoverBaseURL = failover.URL
+	_, err := hc.request(context.Background(), "POST", "/cancel", &requestOptions{
+		JSONBody: map[string]any{"cancel_at_period_end": true},
+	})
+	var connErr *APIConnectionError
+	if !errors.As(err, &connErr) {
+		t.Fatalf("expected *APIConnectionError, got %T: %v", err, err)
+	}
+	if hits != 0 {
+		t.Errorf("failover hits = %d, want 0", hits)
+	}
+}
+
+func TestHTTPClientNoFailoverForOtherErrors(t *testing.T) {
+	primary := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(500)
+		_, _ = w.Write([]byte(`{"error":"boom"}`))
+	}))
+	defer primary.Close()
+	hits := 0
+	failover := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		hits++
+		w.WriteHeader(200)
+		_, _ = w.Write([]byte(`{"ok":true}`))
+	}))
+	defer failover.Close()
+
+	hc := newHTTPClient("sk_test", primary.URL, 5*time.Second, &http.Client{})
+	hc.failoverBaseURL = failover.URL
+	_, err := hc.request(context.Background(), "GET", "/sub", nil)
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) {
+		t.Fatalf("expected *APIError, got %T: %v", err, err)
+	}
+	if hits != 0 {
+		t.Errorf("failover hits = %d, want 0", hits)
+	}
+}
+
+func TestIsIdempotent(t *testing.T) {
+	for _, m := range []string{"GET", "HEAD", "OPTIONS", "PUT", "DELETE"} {
+		if !isIdempotent(m) {
+			t.Errorf("isIdempotent(%q) = false", m)
+		}
+	}
+	for _, m := range []string{"POST", "PATCH"} {
+		if isIdempotent(m) {
+			t.Errorf("isIdempotent(%q) = true", m)
+		}
+	}
+}
+
+func TestHTTPClientErrorParam(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		want string
+	}{
+		{"nested", `{"error": {"code": "invalid_param", "message": "bad", "param": "page_size"}}`, "page_size"},
+		{"top level", `{"error": "bad plan", "param": "plan_slug"}`, "plan_slug"},
+		{"nested wins", `{"error": {"message": "bad", "param": "page"}, "param": "other"}`, "page"},
+		{"absent", `{"error": {"code": "invalid_param", "message": "bad"}}`, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+				w.WriteHeader(400)
+				_, _ = w.Write([]byte(tt.body))
+			}))
+			defer srv.Close()
+
+			hc := newHTTPClient("sk_test", srv.URL, 10*time.Second, srv.Client())
+			_, err := hc.request(context.Background(), "GET", "/param", nil)
+
+			var invErr *InvalidRequestError
+			if !errors.As(err, &invErr) {
+				t.Fatalf("expected *InvalidRequestError, got %T: %v", err, err)
+			}
+			if invErr.Param != tt.want {
+				t.Errorf("Param = %q, want %q", invErr.Param, tt.want)
+			}
+		})
+	}
+}
+
+func TestHTTPClientZeroTimeoutDisablesInternalDeadline(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		time.Sleep(100 * time.Millisecond)
+		w.WriteHeader(200)
+		_, _ = w.Write([]byte(`{"ok":true}`))
+	}))
+	defer srv.Close()
+
+	hc := newHTTPClient("sk_test", srv.URL, 0, srv.Client())
+	if _, err := hc.request(context.Background(), "GET", "/slow", nil); err != nil {
+		t.Fatalf("request with timeout disabled failed: %v", err)
+	}
+}
+
+func TestHTTPClientZeroTimeoutHonorsCallerDeadline(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		time.Sleep(500 * time.Millisecond)
+		w.WriteHeader(200)
+		_, _ = w.Write([]byte(`{"ok":true}`))
+	}))
+	defer srv.Close()
+
+	hc := newHTTPClient("sk_test", srv.URL, 0, srv.Client())
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	_, err := hc.request(ctx, "GET", "/slow", nil)
+	var connErr *APIConnectionError
+	if !errors.As(err, &connErr) {
+		t.Fatalf("expected *APIConnectionError, got %T: %v", err, err)
+	}
+	if connErr.Message != "Request timed out" {
+		t.Errorf("Message = %q", connErr.Message)
+	}
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("err = %v, want it to match context.DeadlineExceeded", err)
+	}
+}
+
+// largeListBody returns a paginated list JSON body with n items.
+func largeListBody(n int) string {
+	var b strings.Builder
+	b.WriteString(`{"items":[`)
+	for i := 0; i < n; i++ {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		fmt.Fprintf(&b, `{"id":"inv_%d","amount":%d,"currency":"usd","status":"paid"}`, i, i)
+	}
+	fmt.Fprintf(&b, `],"total":%d,"page":1,"page_size":%d,"total_pages":1}`, n, n)
+	return b.String()
+}
+
+func TestRequestListStreamsLargeList(t *testing.T) {
+	const n = 50000
+	body := largeListBody(n)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("X-Rate-Limit-Remaining", "7")
+		w.WriteHeader(200)
+		_, _ = io.WriteString(w, body)
+	}))
+	defer srv.Close()
+
+	hc := newHTTPClient("sk_test", srv.URL, 10*time.Second, srv.Client())
+	list, err := requestList[Invoice](context.Background(), hc, "/invoices", nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list.Items) != n || list.Total != n {
+		t.Fatalf("len(Items) = %d, Total = %d, want %d", len(list.Items), list.Total, n)
+	}
+	if last := list.Items[n-1]; last.ID != fmt.Sprintf("inv_%d", n-1) || last.Amount != n-1 {
+		t.Errorf("last item = %+v", last)
+	}
+	if remaining, ok := list.RateLimitRemaining(); !ok || remaining != 7 {
+		t.Errorf("RateLimitRemaining() = %d, %v", remaining, ok)
+	}
+}
+
+func TestRequestListErrorStatusReturnsTypedError(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(404)
+		_, _ = w.Write([]byte(`{"error": {"code": "not_found", "message": "No such user"}}`))
+	}))
+	defer srv.Close()
+
+	hc := newHTTPClient("sk_test", srv.URL, 10*time.Second, srv.Client())
+	_, err := requestList[Invoice](context.Background(), hc, "/invoices", nil, nil)
+	var nf *NotFoundError
+	if !errors.As(err, &nf) {
+		t.Fatalf("expected NotFoundError, got %T", err)
+	}
+	if nf.Message != "No such user" {
+		t.Errorf("Message = %q", nf.Message)
+	}
+}
+
+func TestRequestListInvalidJSONReturnsAPIError(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(200)
+		_, _ = w.Write([]byte(`not json`))
+	}))
+	defer srv.Close()
+
+	hc := newHTTPClient("sk_test", srv.URL, 10*time.Second, srv.Client())
+	_, err := requestList[Invoice](context.Background(), hc, "/invoices", nil, nil)
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) {
+		t.Fatalf("expected APIError, got %T", err)
+	}
+	if apiErr.HTTPStatus != 200 {
+		t.Errorf("HTTPStatus = %d", apiErr.HTTPStatus)
+	}
+}
+
+func BenchmarkRequestList(b *testing.B) {
+	body := largeListBody(10000)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = io.WriteString(w, body)
+	}))
+	defer srv.Close()
+
+	hc := newHTTPClient("sk_test", srv.URL, 10*time.Second, srv.Client())
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := requestList[Invoice](context.Background(), hc, "/invoices", nil, nil); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,37 @@
+package paylio
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// InvoiceService provides methods for interacting with invoices.
+type InvoiceService struct {
+	http *httpClient
+}
+
+func newInvoiceService(hc *httpClient) *InvoiceService {
+	return &InvoiceService{http: hc}
+}
+
+// List fetches paginated invoices for a subscription.
+func (s *InvoiceService) List(ctx context.Context, subscriptionID string, opts *ListOptions, reqOpts ...RequestOption) (*PaginatedList[Invoice], error) {
+	if strings.TrimSpace(subscriptionID) == "" {
+		return nil, errors.New("subscriptionID is required")
+	}
+	return requestList[Invoice](ctx, s.http, fmt.Sprintf("/subscription/%s/invoices", subscriptionID), opts, reqOpts)
+}
+
+// Retrieve fetches an invoice by ID.
+func (s *InvoiceService) Retrieve(ctx context.Context, invoiceID string, opts ...RequestOption) (*Invoice, error) {
+	if strings.TrimSpace(invoiceID) == "" {
+		return nil, errors.New("invoiceID is required")
+	}
+	data, err := s.http.request(ctx, "GET", fmt.Sprintf("/invoices/%s", invoiceID), applyRequestOptions(nil, opts))
+	if err != nil {
+		return nil, err
+	}
+	return decodeResource[Invoice](s.http, data)
+}
@@ -0,0 +1,151 @@
+package paylio
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func newTestInvoiceService(handler http.HandlerFunc) (*InvoiceService, *httptest.Server) {
+	srv := httptest.NewServer(handler)
+	hc := newHTTPClient("sk_test", srv.URL, 10*time.Second, srv.Client())
+	return newInvoiceService(hc), srv
+}
+
+func TestInvoiceListReturnsPaginatedList(t *testing.T) {
+	svc, srv := newTestInvoiceService(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "GET" {
+			t.Errorf("Method = %q", r.Method)
+		}
+		if r.URL.Path != "/subscription/sub_1/invoices" {
+			t.Errorf("Path = %q", r.URL.Path)
+		}
+		if r.URL.Query().Get("page") != "2" {
+			t.Errorf("page = %q", r.URL.Query().Get("page"))
+		}
+		if r.URL.Query().Get("page_size") != "1" {
+			t.Errorf("page_size = %q", r.URL.Query().Get("page_size"))
+		}
+		w.WriteHeader(200)
+		_, _ = w.Write([]byte(`{"items":[{"id":"in_2","amount":9.99,"currency":"usd","status":"paid","period_start":"2025-02-01T00:00:00Z","period_end":"2025-03-01T00:00:00Z","paid_at":"2025-02-01T00:05:00Z"}],"total":3,"page":2,"page_size":1,"total_pages":3}`))
+	})
+	defer srv.Close()
+
+	list, err := svc.List(context.Background(), "sub_1", &ListOptions{Page: 2, PageSize: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list.Items) != 1 {
+		t.Fatalf("Items len = %d", len(list.Items))
+	}
+	inv := list.Items[0]
+	if inv.ID != "in_2" {
+		t.Errorf("ID = %q", inv.ID)
+	}
+	if inv.PaidAt == nil || *inv.PaidAt != "2025-02-01T00:05:00Z" {
+		t.Errorf("PaidAt = %v", inv.PaidAt)
+	}
+	if !list.HasMore() {
+		t.Error("HasMore should be true (page 2 of 3)")
+	}
+}
+
+func TestInvoiceListDefaultPagination(t *testing.T) {
+	svc, srv := newTestInvoiceService(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("page") != "1" {
+			t.Errorf("page = %q", r.URL.Query().Get("page"))
+		}
+		if r.URL.Query().Get("page_size") != "20" {
+			t.Errorf("page_size = %q", r.URL.Query().Get("page_size"))
+		}
+		w.WriteHeader(200)
+		_, _ = w.Write([]byte(`{"items":[],"total":0,"page":1,"page_size":20,"total_pages":0}`))
+	})
+	defer srv.Close()
+
+	list, err := svc.List(context.Background(), "sub_1", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if list.HasMore() {
+		t.Error("HasMore should be false for empty list")
+	}
+}
+
+func TestInvoiceListEmptySubscriptionIDReturnsError(t *testing.T) {
+	svc, srv := newTestInvoiceService(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(200)
+	})
+	defer srv.Close()
+
+	_, err := svc.List(context.Background(), " ", nil)
+	if err == nil {
+		t.Fatal("expected error for empty subscriptionID")
+	}
+}
+
+func TestInvoiceListAPIErrorPropagation(t *testing.T) {
+	hc := newHTTPClient("sk_test", "http://127.0.0.1:1", 5*time.Second, &http.Client{})
+	svc := newInvoiceService(hc)
+	_, err := svc.List(context.Background(), "sub_1", nil)
+	if err == nil {
+		t.Fatal("expected error")
+	}
+}
+
+func TestInvoiceRetrieve(t *testing.T) {
+	svc, srv := newTestInvoiceService(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/invoices/in_1" {
+			t.Errorf("Path = %q", r.URL.Path)
+		}
+		w.WriteHeader(200)
+		_, _ = w.Write([]byte(`{"id":"in_1","amount":19.5,"currency":"eur","status":"open","period_start":"2025-01-01T00:00:00Z","period_end":"2025-02-01T00:00:00Z","paid_at":null}`))
+	})
+	defer srv.Close()
+
+	inv, err := svc.Retrieve(context.Background(), "in_1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if inv.ID != "in_1" {
+		t.Errorf("ID = %q", inv.ID)
+	}
+	if inv.Amount != 19.5 {
+		t.Errorf("Amount = %v", inv.Amount)
+	}
+	if inv.Currency != "eur" {
+		t.Errorf("Currency = %q", inv.Currency)
+	}
+	if inv.Status != "open" {
+		t.Errorf("Status = %q", inv.Status)
+	}
+	if inv.PeriodStart != "2025-01-01T00:00:00Z" || inv.PeriodEnd != "2025-02-01T00:00:00Z" {
+		t.Errorf("Period = %q..%q", inv.PeriodStart, inv.PeriodEnd)
+	}
+	if inv.PaidAt != nil {
+		t.Errorf("PaidAt = %v, want nil", inv.PaidAt)
+	}
+}
+
+func TestInvoiceRetrieveEmptyIDReturnsError(t *testing.T) {
+	svc, srv := newTestInvoiceService(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(200)
+	})
+	defer srv.Close()
+
+	_, err := svc.Retrieve(context.Background(), "")
+	if err == nil {
+		t.Fatal("expected error for empty invoiceID")
+	}
+}
+
+func TestInvoiceRetrieveAPIErrorPropagation(t *testing.T) {
+	hc := newHTTPClient("sk_test", "http://127.0.0.1:1", 5*time.Second, &http.Client{})
+	svc := newInvoiceService(hc)
+	_, err := svc.Retrieve(context.Background(), "in_1")
+	if err == nil {
+		t.Fatal("expected error")
+	}
+}
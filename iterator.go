@@ -0,0 +1,65 @@
+package paylio
+
+// Iterator walks every item of a paginated list, fetching pages on demand.
+//
+//	it := client.Subscription.ListAutoPaging(ctx, "user_123", nil)
+//	for it.Next() {
+//		item := it.Value()
+//		// ...
+//	}
+//	if err := it.Err(); err != nil {
+//		// handle error
+//	}
+type Iterator[T any] struct {
+	fetchPage func(page int, cursor string) (*PaginatedList[T], error)
+	page      int
+	cursor    string
+	items     []T
+	index     int
+	current   T
+	hasMore   bool
+	cursored  bool
+	err       error
+}
+
+// newIterator returns an Iterator that calls fetchPage with page numbers
+// starting at 1 until a page reports no more results or an error occurs.
+// When a page carries a next cursor, the following fetch also receives it.
+// Once any page has carried a cursor, the cursor alone decides whether more
+// results remain and the page count is ignored.
+func newIterator[T any](fetchPage func(page int, cursor string) (*PaginatedList[T], error)) *Iterator[T] {
+	return &Iterator[T]{fetchPage: fetchPage, hasMore: true}
+}
+
+// Next advances to the next item, fetching the next page when the current
+// one is exhausted. It returns false when iteration is complete or an error
+// occurred; check Err to distinguish the two.
+func (it *Iterator[T]) Next() bool {
+	for it.index >= len(it.items) {
+		if it.err != nil || !it.hasMore {
+			return false
+		}
+		it.page++
+		list, err := it.fetchPage(it.page, it.cursor)
+		if err != nil {
+			it.err = err
+			return false
+		}
+		it.items, it.index, it.cursor = list.Items, 0, list.NextCursor
+		it.cursored = it.cursored || list.HasMoreCursor()
+		it.hasMore = list.HasMoreCursor() || !it.cursored && list.HasMore()
+	}
+	it.current = it.items[it.index]
+	it.index++
+	return true
+}
+
+// Value returns the current item. It is only valid after Next returns true.
+func (it *Iterator[T]) Value() T {
+	return it.current
+}
+
+// Err returns the error that stopped iteration, if any.
+func (it *Iterator[T]) Err() error {
+	return it.err
+}
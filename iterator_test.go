@@ -0,0 +1,135 @@
+package paylio
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+// pagedInts returns a fetcher serving items split into pages of pageSize.
+func pagedInts(items []int, pageSize int, calls *[]int) func(int, string) (*PaginatedList[int], error) {
+	totalPages := (len(items) + pageSize - 1) / pageSize
+	return func(page int, _ string) (*PaginatedList[int], error) {
+		*calls = append(*calls, page)
+		start := (page - 1) * pageSize
+		end := start + pageSize
+		if start > len(items) {
+			start = len(items)
+		}
+		if end > len(items) {
+			end = len(items)
+		}
+		return &PaginatedList[int]{
+			Items:      items[start:end],
+			Total:      len(items),
+			Page:       page,
+			PageSize:   pageSize,
+			TotalPages: totalPages,
+		}, nil
+	}
+}
+
+func drain[T any](it *Iterator[T]) []T {
+	var got []T
+	for it.Next() {
+		got = append(got, it.Value())
+	}
+	return got
+}
+
+func TestIteratorEmpty(t *testing.T) {
+	var calls []int
+	it := newIterator(pagedInts(nil, 10, &calls))
+	if got := drain(it); len(got) != 0 {
+		t.Errorf("items = %v, want none", got)
+	}
+	if it.Err() != nil {
+		t.Errorf("Err() = %v", it.Err())
+	}
+	if !reflect.DeepEqual(calls, []int{1}) {
+		t.Errorf("pages fetched = %v, want [1]", calls)
+	}
+}
+
+func TestIteratorSinglePage(t *testing.T) {
+	var calls []int
+	it := newIterator(pagedInts([]int{1, 2, 3}, 10, &calls))
+	if got := drain(it); !reflect.DeepEqual(got, []int{1, 2, 3}) {
+		t.Errorf("items = %v", got)
+	}
+	if !reflect.DeepEqual(calls, []int{1}) {
+		t.Errorf("pages fetched = %v, want [1]", calls)
+	}
+}
+
+func TestIteratorMultiPage(t *testing.T) {
+	var calls []int
+	it := newIterator(pagedInts([]int{1, 2, 3, 4, 5, 6, 7}, 3, &calls))
+	if got := drain(it); !reflect.DeepEqual(got, []int{1, 2, 3, 4, 5, 6, 7}) {
+		t.Errorf("items = %v", got)
+	}
+	if !reflect.DeepEqual(calls, []int{1, 2, 3}) {
+		t.Errorf("pages fetched = %v, want [1 2 3]", calls)
+	}
+	if it.Next() {
+		t.Error("Next should keep returning false after completion")
+	}
+}
+
+func TestIteratorStopsOnError(t *testing.T) {
+	boom := errors.New("boom")
+	var calls []int
+	fetch := pagedInts([]int{1, 2, 3, 4}, 2, &calls)
+	it := newIterator(func(page int, _ string) (*PaginatedList[int], error) {
+		if page == 2 {
+			return nil, boom
+		}
+		return fetch(page, "")
+	})
+	if got := drain(it); !reflect.DeepEqual(got, []int{1, 2}) {
+		t.Errorf("items = %v", got)
+	}
+	if !errors.Is(it.Err(), boom) {
+		t.Errorf("Err() = %v, want boom", it.Err())
+	}
+	if it.Next() {
+		t.Error("Next should return false after an error")
+	}
+}
+
+func TestIteratorFollowsCursors(t *testing.T) {
+	var cursors []string
+	pages := map[string]*PaginatedList[int]{
+		"":   {Items: []int{1, 2}, NextCursor: "c2"},
+		"c2": {Items: []int{3}, NextCursor: "c3", PrevCursor: "c1"},
+		"c3": {Items: []int{4}, PrevCursor: "c2"},
+	}
+	it := newIterator(func(_ int, cursor string) (*PaginatedList[int], error) {
+		cursors = append(cursors, cursor)
+		return pages[cursor], nil
+	})
+	if got := drain(it); !reflect.DeepEqual(got, []int{1, 2, 3, 4}) {
+		t.Errorf("items = %v", got)
+	}
+	if !reflect.DeepEqual(cursors, []string{"", "c2", "c3"}) {
+		t.Errorf("cursors = %q", cursors)
+	}
+}
+
+func TestIteratorStopsWhenCursorRunsOut(t *testing.T) {
+	var cursors []string
+	pages := map[string]*PaginatedList[int]{
+		"":   {Items: []int{1, 2}, NextCursor: "c2", Page: 1, TotalPages: 3},
+		"c2": {Items: []int{3}, Page: 2, TotalPages: 3},
+	}
+	it := newIterator(func(_ int, cursor string) (*PaginatedList[int], error) {
+		cursors = append(cursors, cursor)
+		return pages[cursor], nil
+	})
+	if got := drain(it); !reflect.DeepEqual(got, []int{1, 2, 3}) {
+		t.Errorf("items = %v", got)
+	}
+	if !reflect.DeepEqual(cursors, []string{"", "c2"}) {
+		t.Errorf("cursors = %q", cursors)
+	}
+}
@@ -0,0 +1,34 @@
+package paylio
+
+import "encoding/json"
+
+// jsonMarshal and jsonUnmarshal encode request bodies and decode resources.
+// They default to encoding/json, with numbers in interface values kept
+// exact; see unmarshalJSON.
+var (
+	jsonMarshal   = json.Marshal
+	jsonUnmarshal = unmarshalJSON
+)
+
+// SetJSONMarshaler replaces the function used to encode JSON request bodies
+// and resources, for example with a faster drop-in replacement for
+// encoding/json. Passing nil restores encoding/json. It affects every
+// client and must not be called concurrently with requests; call it during
+// program initialization.
+func SetJSONMarshaler(marshal func(v any) ([]byte, error)) {
+	if marshal == nil {
+		marshal = json.Marshal
+	}
+	jsonMarshal = marshal
+}
+
+// SetJSONUnmarshaler replaces the function used to decode resources from
+// responses. Passing nil restores the default, which is encoding/json with
+// numbers in interface values decoded as json.Number. The same restrictions
+// as SetJSONMarshaler apply.
+func SetJSONUnmarshaler(unmarshal func(data []byte, v any) error) {
+	if unmarshal == nil {
+		unmarshal = unmarshalJSON
+	}
+	jsonUnmarshal = unmarshal
+}
@@ -0,0 +1,87 @@
+package paylio
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"testing"
+)
+
+func TestSetJSONMarshalerAndUnmarshaler(t *testing.T) {
+	var marshals, unmarshals int
+	SetJSONMarshaler(func(v any) ([]byte, error) {
+		marshals++
+		return json.Marshal(v)
+	})
+	SetJSONUnmarshaler(func(data []byte, v any) error {
+		unmarshals++
+		return json.Unmarshal(data, v)
+	})
+	t.Cleanup(func() {
+		SetJSONMarshaler(nil)
+		SetJSONUnmarshaler(nil)
+	})
+
+	svc, srv := newTestService(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(200)
+		if r.Method == http.MethodGet {
+			_, _ = w.Write([]byte(`{"items":[{"id":"sh_1"}],"page":1,"total_pages":1}`))
+			return
+		}
+		body, _ := io.ReadAll(r.Body)
+		if string(body) != `{"plan_slug":"pro","user_id":"user_1"}` {
+			t.Errorf("body = %s", body)
+		}
+		_, _ = w.Write([]byte(`{"id":"sub_1","status":"active"}`))
+	})
+	defer srv.Close()
+
+	sub, err := svc.Create(context.Background(), &CreateSubscriptionParams{UserID: "user_1", PlanSlug: "pro"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sub.ID != "sub_1" {
+		t.Errorf("ID = %q", sub.ID)
+	}
+	// One marshal for the request body and one for the response round-trip.
+	if marshals != 2 || unmarshals != 1 {
+		t.Errorf("marshals = %d, unmarshals = %d; want 2, 1", marshals, unmarshals)
+	}
+
+	list, err := svc.List(context.Background(), "user_1", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list.Items) != 1 || list.Items[0].ID != "sh_1" {
+		t.Errorf("items = %+v", list.Items)
+	}
+	// List responses are decoded directly, without a marshal round-trip.
+	if marshals != 2 || unmarshals != 2 {
+		t.Errorf("after List: marshals = %d, unmarshals = %d; want 2, 2", marshals, unmarshals)
+	}
+}
+
+func TestCustomJSONMarshalerErrors(t *testing.T) {
+	boom := errors.New("boom")
+	t.Cleanup(func() { SetJSONMarshaler(nil) })
+	SetJSONMarshaler(func(any) ([]byte, error) { return nil, boom })
+
+	var out map[string]any
+	if err := decodeInto(map[string]any{"id": "sub_1"}, &out); !errors.Is(err, boom) {
+		t.Errorf("decodeInto err = %v, want boom", err)
+	}
+}
+
+func TestSetJSONFunctionsNilRestoresDefaults(t *testing.T) {
+	SetJSONMarshaler(nil)
+	SetJSONUnmarshaler(nil)
+	var out map[string]any
+	if err := decodeInto(map[string]any{"amount": json.Number("12345678901234567890")}, &out); err != nil {
+		t.Fatal(err)
+	}
+	if out["amount"] != json.Number("12345678901234567890") {
+		t.Errorf("amount = %#v, want exact json.Number", out["amount"])
+	}
+}
@@ -0,0 +1,31 @@
+package paylio
+
+import "time"
+
+// Logger receives diagnostic messages from the SDK as a message plus
+// alternating key-value pairs. *slog.Logger satisfies it.
+type Logger interface {
+	Debug(msg string, args ...any)
+	Info(msg string, args ...any)
+	Warn(msg string, args ...any)
+	Error(msg string, args ...any)
+}
+
+// logRetry records that a failed request is about to be retried after wait.
+// requestID is the X-Request-Id sent with the failed request, if any.
+func (hc *httpClient) logRetry(method, path, requestID string, attempt int, err error, wait time.Duration) {
+	if hc.logger == nil {
+		return
+	}
+	args := []any{"method", method, "path", path}
+	if requestID != "" {
+		args = append(args, "request_id", requestID)
+	}
+	args = append(args, "attempt", attempt)
+	if pe, ok := AsPaylioError(err); ok && pe.HTTPStatus != 0 {
+		args = append(args, "status", pe.HTTPStatus)
+	} else {
+		args = append(args, "error", err.Error())
+	}
+	hc.logger.Warn("retrying request", append(args, "backoff", wait)...)
+}
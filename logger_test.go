@@ -0,0 +1,107 @@
+package paylio
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// captureLogger records log entries as formatted lines.
+type captureLogger struct {
+	mu      sync.Mutex
+	entries []string
+}
+
+func (l *captureLogger) log(level, msg string, args []any) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.entries = append(l.entries, strings.TrimSpace(fmt.Sprintln(append([]any{level, msg}, args...)...)))
+}
+
+func (l *captureLogger) Debug(msg string, args ...any) { l.log("DEBUG", msg, args) }
+func (l *captureLogger) Info(msg string, args ...any)  { l.log("INFO", msg, args) }
+func (l *captureLogger) Warn(msg string, args ...any)  { l.log("WARN", msg, args) }
+func (l *captureLogger) Error(msg string, args ...any) { l.log("ERROR", msg, args) }
+
+func (l *captureLogger) Entries() []string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return append([]string(nil), l.entries...)
+}
+
+var _ Logger = (*slog.Logger)(nil)
+
+func TestRetriesAreLogged(t *testing.T) {
+	srv, _ := newSequenceServer(t,
+		sequenceResponse{status: 503},
+		sequenceResponse{status: 503},
+		sequenceResponse{status: 200, body: `{"id":"sub_1"}`},
+	)
+	defer srv.Close()
+
+	logger := &captureLogger{}
+	client, err := NewClient("sk_test", WithBaseURL(srv.URL), WithMaxRetries(3), withClock(newFakeClock()), WithLogger(logger))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.Subscription.Retrieve(context.Background(), "user_1"); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{
+		"WARN retrying request method GET path /subscription/user_1 attempt 1 status 503 backoff 500ms",
+		"WARN retrying request method GET path /subscription/user_1 attempt 2 status 503 backoff 1s",
+	}
+	got := logger.Entries()
+	if len(got) != len(want) {
+		t.Fatalf("entries = %q, want %q", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("entry %d = %q, want %q", i, got[i], want[i])
+		}
+	}
+}
+
+func TestRetryLogIncludesConnectionError(t *testing.T) {
+	logger := &captureLogger{}
+	hc := newHTTPClient("sk_test", "http://127.0.0.1:1", time.Second, &http.Client{})
+	hc.logger = logger
+	hc.logRetry("GET", "/sub", "", 1, NewAPIConnectionError(ErrorParams{Message: "Connection error: refused"}), time.Second)
+	got := logger.Entries()
+	if len(got) != 1 || got[0] != "WARN retrying request method GET path /sub attempt 1 error Connection error: refused backoff 1s" {
+		t.Errorf("entries = %q", got)
+	}
+}
+
+func TestRetryLogIncludesRequestID(t *testing.T) {
+	srv, _ := newSequenceServer(t,
+		sequenceResponse{status: 503},
+		sequenceResponse{status: 200, body: `{"id":"sub_1"}`},
+	)
+	defer srv.Close()
+
+	logger := &captureLogger{}
+	var n int
+	client, err := NewClient("sk_test", WithBaseURL(srv.URL), WithMaxRetries(3), withClock(newFakeClock()), WithLogger(logger),
+		WithRequestIDGenerator(func() string { n++; return fmt.Sprintf("req_%d", n) }))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.Subscription.Retrieve(context.Background(), "user_1"); err != nil {
+		t.Fatal(err)
+	}
+	got := logger.Entries()
+	want := "WARN retrying request method GET path /subscription/user_1 request_id req_1 attempt 1 status 503 backoff 500ms"
+	if len(got) != 1 || got[0] != want {
+		t.Errorf("entries = %q, want %q", got, want)
+	}
+	if id := sentRequestID(context.Canceled); id != "" {
+		t.Errorf("sentRequestID(context.Canceled) = %q", id)
+	}
+}
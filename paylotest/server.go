@@ -0,0 +1,199 @@
+// Package paylotest provides an in-memory fake of the Paylio subscription
+// API for testing code that uses the paylio client.
+//
+//	srv := paylotest.NewServer()
+//	defer srv.Close()
+//	client, _ := paylio.NewClient("sk_test", paylio.WithBaseURL(srv.URL))
+package paylotest
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/paylio-org/paylio-go"
+)
+
+// Preloaded fixtures served by NewServer.
+const (
+	// ActiveUserID has an active monthly subscription, ActiveSubscriptionID,
+	// and a canceled subscription in its history.
+	ActiveUserID         = "user_active"
+	ActiveSubscriptionID = "sub_active"
+
+	// TrialingUserID has a trialing subscription, TrialingSubscriptionID.
+	TrialingUserID         = "user_trialing"
+	TrialingSubscriptionID = "sub_trialing"
+)
+
+// server holds the fake API's state.
+type server struct {
+	mu      sync.Mutex
+	current map[string]*paylio.Subscription             // by user ID
+	history map[string][]paylio.SubscriptionHistoryItem // by user ID, newest first
+}
+
+// NewServer starts a fake Paylio API preloaded with the fixture
+// subscriptions above. It serves retrieving a user's subscription, listing
+// a user's subscription history, and canceling a subscription. Requests
+// without an API key are rejected with 401, and unknown users or
+// subscriptions yield 404. A cancel that does not set cancel_at_period_end
+// to false takes effect at the end of the period. The caller must Close the
+// server.
+func NewServer() *httptest.Server {
+	return httptest.NewServer(newServer().handler())
+}
+
+func newServer() *server {
+	pro := paylio.Plan{Slug: "pro", Name: "Pro", Interval: paylio.IntervalMonth, IntervalCount: 1, Amount: 9.99, Currency: "usd"}
+	basic := paylio.Plan{Slug: "basic", Name: "Basic", Interval: paylio.IntervalMonth, IntervalCount: 1, Amount: 4.99, Currency: "usd"}
+	trialEnd := time.Date(2030, 1, 15, 0, 0, 0, 0, time.UTC)
+	return &server{
+		current: map[string]*paylio.Subscription{
+			ActiveUserID: {
+				ID:                 ActiveSubscriptionID,
+				Object:             "subscription",
+				Status:             paylio.SubscriptionStatusActive,
+				UserID:             ActiveUserID,
+				Plan:               pro,
+				SubscriptionPeriod: paylio.Period{Start: "2025-01-01T00:00:00Z", End: "2025-02-01T00:00:00Z"},
+				Provider:           paylio.ProviderStripe,
+				Quantity:           1,
+				CreatedAt:          "2024-06-01T00:00:00Z",
+			},
+			TrialingUserID: {
+				ID:                 TrialingSubscriptionID,
+				Object:             "subscription",
+				Status:             paylio.SubscriptionStatusTrialing,
+				UserID:             TrialingUserID,
+				Plan:               basic,
+				SubscriptionPeriod: paylio.Period{Start: "2030-01-01T00:00:00Z", End: "2030-02-01T00:00:00Z"},
+				Provider:           paylio.ProviderStripe,
+				TrialEnd:           &trialEnd,
+				Quantity:           1,
+				CreatedAt:          "2030-01-01T00:00:00Z",
+			},
+		},
+		history: map[string][]paylio.SubscriptionHistoryItem{
+			ActiveUserID: {
+				historyItem(ActiveSubscriptionID, ActiveUserID, pro, "active", "2025-01-01T00:00:00Z", "2025-02-01T00:00:00Z"),
+				historyItem("sub_previous", ActiveUserID, basic, "canceled", "2024-06-01T00:00:00Z", "2024-07-01T00:00:00Z"),
+			},
+			TrialingUserID: {
+				historyItem(TrialingSubscriptionID, TrialingUserID, basic, "trialing", "2030-01-01T00:00:00Z", "2030-02-01T00:00:00Z"),
+			},
+		},
+	}
+}
+
+func historyItem(id, userID string, plan paylio.Plan, status, start, end string) paylio.SubscriptionHistoryItem {
+	return paylio.SubscriptionHistoryItem{
+		ID:                 id,
+		UserID:             userID,
+		PlanSlug:           plan.Slug,
+		PlanName:           plan.Name,
+		PlanAmount:         plan.Amount,
+		PlanCurrency:       plan.Currency,
+		PlanInterval:       string(plan.Interval),
+		Status:             status,
+		CurrentPeriodStart: start,
+		CurrentPeriodEnd:   end,
+		CreatedAt:          start,
+	}
+}
+
+func (s *server) handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /subscription/{userID}", s.retrieve)
+	mux.HandleFunc("GET /users/{userID}/subscriptions", s.list)
+	mux.HandleFunc("POST /subscription/{id}/cancel", s.cancel)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-API-Key") == "" && r.URL.Query().Get("api_key") == "" {
+			writeError(w, http.StatusUnauthorized, "invalid_api_key", "No API key provided")
+			return
+		}
+		mux.ServeHTTP(w, r)
+	})
+}
+
+func (s *server) retrieve(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	sub, ok := s.current[r.PathValue("userID")]
+	if !ok {
+		writeError(w, http.StatusNotFound, "not_found", "No subscription found for user")
+		return
+	}
+	writeJSON(w, http.StatusOK, sub)
+}
+
+func (s *server) list(w http.ResponseWriter, r *http.Request) {
+	page, pageSize := 1, paylio.DefaultPageSize
+	if v, err := strconv.Atoi(r.URL.Query().Get("page")); err == nil && v > 0 {
+		page = v
+	}
+	if v, err := strconv.Atoi(r.URL.Query().Get("page_size")); err == nil && v > 0 {
+		pageSize = v
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	items := s.history[r.PathValue("userID")]
+	start := min((page-1)*pageSize, len(items))
+	end := min(start+pageSize, len(items))
+	writeJSON(w, http.StatusOK, paylio.PaginatedList[paylio.SubscriptionHistoryItem]{
+		Items:      append([]paylio.SubscriptionHistoryItem{}, items[start:end]...),
+		Total:      len(items),
+		Page:       page,
+		PageSize:   pageSize,
+		TotalPages: (len(items) + pageSize - 1) / pageSize,
+	})
+}
+
+func (s *server) cancel(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		CancelAtPeriodEnd *bool `json:"cancel_at_period_end"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid_param", "Invalid JSON body")
+		return
+	}
+	atPeriodEnd := body.CancelAtPeriodEnd == nil || *body.CancelAtPeriodEnd
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	id := r.PathValue("id")
+	for _, sub := range s.current {
+		if sub.ID != id {
+			continue
+		}
+		if atPeriodEnd {
+			sub.CancelAtPeriodEnd = true
+		} else {
+			canceledAt := time.Now().UTC().Format(time.RFC3339)
+			sub.Status = paylio.SubscriptionStatusCanceled
+			sub.CanceledAt = &canceledAt
+		}
+		writeJSON(w, http.StatusOK, paylio.SubscriptionCancel{
+			ID:                id,
+			Object:            "subscription_cancel",
+			Success:           true,
+			CancelAtPeriodEnd: atPeriodEnd,
+		})
+		return
+	}
+	writeError(w, http.StatusNotFound, "not_found", "Subscription not found")
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, code, message string) {
+	writeJSON(w, status, map[string]any{"error": map[string]string{"code": code, "message": message}})
+}
@@ -0,0 +1,157 @@
+package paylotest
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/paylio-org/paylio-go"
+)
+
+func newClient(t *testing.T) *paylio.Client {
+	t.Helper()
+	srv := NewServer()
+	t.Cleanup(srv.Close)
+	client, err := paylio.NewClient("sk_test", paylio.WithBaseURL(srv.URL))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return client
+}
+
+func TestRetrieve(t *testing.T) {
+	client := newClient(t)
+	sub, err := client.Subscription.Retrieve(context.Background(), ActiveUserID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sub.ID != ActiveSubscriptionID || sub.Status != "active" || sub.Plan.Slug != "pro" {
+		t.Errorf("subscription = %+v", sub)
+	}
+
+	sub, err = client.Subscription.Retrieve(context.Background(), TrialingUserID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sub.Status != "trialing" || sub.TrialEnd == nil {
+		t.Errorf("subscription = %+v", sub)
+	}
+
+	_, err = client.Subscription.Retrieve(context.Background(), "user_unknown")
+	var nf *paylio.NotFoundError
+	if !errors.As(err, &nf) {
+		t.Errorf("expected *paylio.NotFoundError, got %T", err)
+	}
+}
+
+func TestList(t *testing.T) {
+	client := newClient(t)
+	list, err := client.Subscription.List(context.Background(), ActiveUserID, &paylio.ListOptions{Page: 1, PageSize: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list.Items) != 1 || list.Items[0].ID != ActiveSubscriptionID || !list.HasMore() {
+		t.Errorf("page 1 = %+v", list)
+	}
+
+	var ids []string
+	it := client.Subscription.ListAutoPaging(context.Background(), ActiveUserID, &paylio.ListOptions{PageSize: 1})
+	for it.Next() {
+		ids = append(ids, it.Value().ID)
+	}
+	if err := it.Err(); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(ids, ",") != ActiveSubscriptionID+",sub_previous" {
+		t.Errorf("ids = %v", ids)
+	}
+
+	list, err = client.Subscription.List(context.Background(), "user_unknown", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list.Items) != 0 || list.Total != 0 {
+		t.Errorf("unknown user list = %+v", list)
+	}
+}
+
+func TestCancel(t *testing.T) {
+	client := newClient(t)
+	res, err := client.Subscription.Cancel(context.Background(), ActiveSubscriptionID, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !res.Success || !res.CancelAtPeriodEnd {
+		t.Errorf("cancel = %+v", res)
+	}
+	sub, _ := client.Subscription.Retrieve(context.Background(), ActiveUserID)
+	if sub.Status != "active" || !sub.CancelAtPeriodEnd {
+		t.Errorf("after period-end cancel: %+v", sub)
+	}
+
+	res, err = client.Subscription.Cancel(context.Background(), TrialingSubscriptionID, &paylio.CancelOptions{CancelNow: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.CancelAtPeriodEnd {
+		t.Errorf("cancel = %+v", res)
+	}
+	sub, _ = client.Subscription.Retrieve(context.Background(), TrialingUserID)
+	if sub.Status != "canceled" || sub.CanceledAt == nil {
+		t.Errorf("after immediate cancel: %+v", sub)
+	}
+
+	_, err = client.Subscription.Cancel(context.Background(), "sub_unknown", nil)
+	var nf *paylio.NotFoundError
+	if !errors.As(err, &nf) {
+		t.Errorf("expected *paylio.NotFoundError, got %T", err)
+	}
+}
+
+func TestNewClientForTesting(t *testing.T) {
+	srv := NewServer()
+	defer srv.Close()
+	client := paylio.NewClientForTesting(srv.URL, srv.Client())
+	sub, err := client.Subscription.Retrieve(context.Background(), ActiveUserID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sub.ID != ActiveSubscriptionID {
+		t.Errorf("ID = %q, want %q", sub.ID, ActiveSubscriptionID)
+	}
+}
+
+func TestCancelInvalidBody(t *testing.T) {
+	srv := NewServer()
+	defer srv.Close()
+	req, _ := http.NewRequest("POST", srv.URL+"/subscription/"+ActiveSubscriptionID+"/cancel", strings.NewReader("{"))
+	req.Header.Set("X-API-Key", "sk_test")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("status = %d, want 400", resp.StatusCode)
+	}
+}
+
+func TestMissingAPIKey(t *testing.T) {
+	srv := NewServer()
+	defer srv.Close()
+	resp, err := http.Get(srv.URL + "/subscription/" + ActiveUserID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("status = %d, want 401", resp.StatusCode)
+	}
+
+	client, _ := paylio.NewClient("sk_test", paylio.WithBaseURL(srv.URL), paylio.WithAPIKeyInQuery("api_key"))
+	if _, err := client.Subscription.Retrieve(context.Background(), ActiveUserID); err != nil {
+		t.Errorf("query-param key rejected: %v", err)
+	}
+}
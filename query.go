@@ -0,0 +1,78 @@
+package paylio
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// encodeQuery converts a struct with `query:"name"` tags into request query
+// parameters. Fields without a tag, or tagged "-", are skipped. The
+// "omitempty" tag option omits zero values; nil pointers are always omitted.
+// Times are formatted as RFC 3339 and bools as "true"/"false".
+func encodeQuery(v any) (map[string]string, error) {
+	params := make(map[string]string)
+	rv := reflect.ValueOf(v)
+	for rv.Kind() == reflect.Pointer {
+		if rv.IsNil() {
+			return params, nil
+		}
+		rv = rv.Elem()
+	}
+	if rv.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("encodeQuery: expected struct, got %s", rv.Kind())
+	}
+
+	rt := rv.Type()
+	for i := 0; i < rt.NumField(); i++ {
+		field := rt.Field(i)
+		tag := field.Tag.Get("query")
+		if tag == "" || tag == "-" {
+			continue
+		}
+		name, opts, _ := strings.Cut(tag, ",")
+		omitEmpty := opts == "omitempty"
+
+		fv := rv.Field(i)
+		if fv.Kind() == reflect.Pointer {
+			if fv.IsNil() {
+				continue
+			}
+			fv = fv.Elem()
+		}
+		if omitEmpty && fv.IsZero() {
+			continue
+		}
+		s, err := formatQueryValue(fv)
+		if err != nil {
+			return nil, fmt.Errorf("encodeQuery: field %s: %w", field.Name, err)
+		}
+		params[name] = s
+	}
+	return params, nil
+}
+
+// formatQueryValue renders a single field value as a query string value.
+func formatQueryValue(v reflect.Value) (string, error) {
+	if v.Type() == timeType {
+		return v.Interface().(time.Time).Format(time.RFC3339), nil
+	}
+	switch v.Kind() {
+	case reflect.String:
+		return v.String(), nil
+	case reflect.Bool:
+		return strconv.FormatBool(v.Bool()), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(v.Int(), 10), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return strconv.FormatUint(v.Uint(), 10), nil
+	case reflect.Float32, reflect.Float64:
+		return strconv.FormatFloat(v.Float(), 'f', -1, 64), nil
+	default:
+		return "", fmt.Errorf("unsupported kind %s", v.Kind())
+	}
+}
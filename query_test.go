@@ -0,0 +1,123 @@
+package paylio
+
+import (
+	"testing"
+	"time"
+)
+
+func TestEncodeQueryScalarTypes(t *testing.T) {
+	type params struct {
+		Status   string    `query:"status"`
+		Page     int       `query:"page"`
+		Limit    uint      `query:"limit"`
+		Ratio    float64   `query:"ratio"`
+		Archived bool      `query:"archived"`
+		Since    time.Time `query:"since"`
+	}
+	since := time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC)
+	got, err := encodeQuery(params{
+		Status:   "active",
+		Page:     2,
+		Limit:    50,
+		Ratio:    0.5,
+		Archived: true,
+		Since:    since,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{
+		"status":   "active",
+		"page":     "2",
+		"limit":    "50",
+		"ratio":    "0.5",
+		"archived": "true",
+		"since":    "2025-01-02T03:04:05Z",
+	}
+	if len(got) != len(want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+	for k, v := range want {
+		if got[k] != v {
+			t.Errorf("%s = %q, want %q", k, got[k], v)
+		}
+	}
+}
+
+func TestEncodeQueryOmitEmpty(t *testing.T) {
+	type params struct {
+		Status   string    `query:"status,omitempty"`
+		Page     int       `query:"page,omitempty"`
+		Archived bool      `query:"archived,omitempty"`
+		Since    time.Time `query:"since,omitempty"`
+		Active   bool      `query:"active"`
+		Count    int       `query:"count"`
+	}
+	got, err := encodeQuery(&params{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{"active": "false", "count": "0"}
+	if len(got) != len(want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+	for k, v := range want {
+		if got[k] != v {
+			t.Errorf("%s = %q, want %q", k, got[k], v)
+		}
+	}
+}
+
+func TestEncodeQueryPointersAndSkippedFields(t *testing.T) {
+	type params struct {
+		Archived *bool  `query:"archived"`
+		Page     *int   `query:"page"`
+		Ignored  string `query:"-"`
+		Untagged string
+	}
+	archived := false
+	got, err := encodeQuery(params{Archived: &archived, Ignored: "x", Untagged: "y"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 1 || got["archived"] != "false" {
+		t.Errorf("got %v, want only archived=false", got)
+	}
+}
+
+func TestEncodeQueryNilPointer(t *testing.T) {
+	var opts *ListOptions
+	got, err := encodeQuery(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 0 {
+		t.Errorf("got %v, want empty", got)
+	}
+}
+
+func TestEncodeQueryNonStruct(t *testing.T) {
+	if _, err := encodeQuery("page=1"); err == nil {
+		t.Fatal("expected error for non-struct")
+	}
+}
+
+func TestEncodeQueryUnsupportedKind(t *testing.T) {
+	type params struct {
+		IDs []string `query:"ids"`
+	}
+	if _, err := encodeQuery(params{IDs: []string{"a"}}); err == nil {
+		t.Fatal("expected error for unsupported slice field")
+	}
+}
+
+func TestListOptionsParams(t *testing.T) {
+	got := (&ListOptions{Page: 3, PageSize: 50}).params()
+	if got["page"] != "3" || got["page_size"] != "50" {
+		t.Errorf("params = %v", got)
+	}
+	defaults := (*ListOptions)(nil).params()
+	if defaults["page"] != "1" || defaults["page_size"] != "20" {
+		t.Errorf("defaults = %v", defaults)
+	}
+}
@@ -0,0 +1,65 @@
+package paylio
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// RateLimit is a snapshot of the API rate limit, as reported by the
+// X-Rate-Limit-Limit, X-Rate-Limit-Remaining, and X-Rate-Limit-Reset headers
+// of a response.
+type RateLimit struct {
+	// Limit is the number of requests allowed in the current window.
+	Limit int
+	// Remaining is the number of requests left in the current window.
+	Remaining int
+	// Reset is when the current window ends. It is zero if the response did
+	// not report it.
+	Reset time.Time
+}
+
+// parseRateLimit reads a RateLimit from response headers, looked up through
+// get by canonical name. X-Rate-Limit-Reset is a Unix timestamp in seconds.
+// It reports false when neither the limit nor the remaining count is present.
+func parseRateLimit(get func(string) string) (RateLimit, bool) {
+	limit, limitErr := strconv.Atoi(get("X-Rate-Limit-Limit"))
+	remaining, remainingErr := strconv.Atoi(get("X-Rate-Limit-Remaining"))
+	if limitErr != nil && remainingErr != nil {
+		return RateLimit{}, false
+	}
+	rl := RateLimit{Limit: limit, Remaining: remaining}
+	if reset, err := strconv.ParseInt(get("X-Rate-Limit-Reset"), 10, 64); err == nil {
+		rl.Reset = time.Unix(reset, 0)
+	}
+	return rl, true
+}
+
+// RateLimit returns the rate limit reported with the 429 response. It reports
+// false when the response carried no rate-limit headers.
+func (e *RateLimitError) RateLimit() (RateLimit, bool) {
+	return parseRateLimit(func(k string) string { return e.Headers[k] })
+}
+
+// recordRateLimit remembers the rate limit reported by a response, if any.
+func (hc *httpClient) recordRateLimit(h http.Header) {
+	rl, ok := parseRateLimit(h.Get)
+	if !ok {
+		return
+	}
+	hc.rateLimitMu.Lock()
+	defer hc.rateLimitMu.Unlock()
+	hc.lastRateLimit = &rl
+}
+
+// LastRateLimit returns the rate limit reported by the most recent response
+// that carried rate-limit headers, whether it succeeded or failed. It reports
+// false if no such response has been received yet.
+func (c *Client) LastRateLimit() (RateLimit, bool) {
+	c.hc.rateLimitMu.Lock()
+	defer c.hc.rateLimitMu.Unlock()
+	if c.hc.lastRateLimit == nil {
+		return RateLimit{}, false
+	}
+	return *c.hc.lastRateLimit, true
+}
@@ -0,0 +1,139 @@
+package paylio
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestParseRateLimit(t *testing.T) {
+	tests := []struct {
+		name   string
+		header http.Header
+		want   RateLimit
+		ok     bool
+	}{
+		{
+			name: "all headers",
+			header: http.Header{
+				"X-Rate-Limit-Limit":     {"100"},
+				"X-Rate-Limit-Remaining": {"42"},
+				"X-Rate-Limit-Reset":     {"1735689600"},
+			},
+			want: RateLimit{Limit: 100, Remaining: 42, Reset: time.Unix(1735689600, 0)},
+			ok:   true,
+		},
+		{
+			name: "missing reset",
+			header: http.Header{
+				"X-Rate-Limit-Limit":     {"100"},
+				"X-Rate-Limit-Remaining": {"0"},
+			},
+			want: RateLimit{Limit: 100, Remaining: 0},
+			ok:   true,
+		},
+		{
+			name:   "absent",
+			header: http.Header{},
+		},
+	}
+	for _, tt := range tests {
+		got, ok := parseRateLimit(tt.header.Get)
+		if ok != tt.ok || got.Limit != tt.want.Limit || got.Remaining != tt.want.Remaining || !got.Reset.Equal(tt.want.Reset) {
+			t.Errorf("%s: parseRateLimit = %+v, %v; want %+v, %v", tt.name, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestClientLastRateLimit(t *testing.T) {
+	status := 200
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("X-Rate-Limit-Limit", "100")
+		if status == 200 {
+			w.Header().Set("X-Rate-Limit-Remaining", "99")
+		} else {
+			w.Header().Set("X-Rate-Limit-Remaining", "0")
+			w.Header().Set("X-Rate-Limit-Reset", "1735689600")
+		}
+		w.WriteHeader(status)
+		_, _ = w.Write([]byte(`{"id":"sub_1"}`))
+	}))
+	defer srv.Close()
+
+	client, err := NewClient("sk_test", WithBaseURL(srv.URL))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := client.LastRateLimit(); ok {
+		t.Error("LastRateLimit should report false before any response")
+	}
+
+	if _, err := client.Subscription.Retrieve(context.Background(), "user_1"); err != nil {
+		t.Fatal(err)
+	}
+	if rl, ok := client.LastRateLimit(); !ok || rl.Limit != 100 || rl.Remaining != 99 || !rl.Reset.IsZero() {
+		t.Errorf("after success: LastRateLimit = %+v, %v", rl, ok)
+	}
+
+	status = 429
+	_, err = client.Subscription.Retrieve(context.Background(), "user_1")
+	var rlErr *RateLimitError
+	if !errors.As(err, &rlErr) {
+		t.Fatalf("expected *RateLimitError, got %T: %v", err, err)
+	}
+	want := RateLimit{Limit: 100, Remaining: 0, Reset: time.Unix(1735689600, 0)}
+	if rl, ok := rlErr.RateLimit(); !ok || rl != want {
+		t.Errorf("RateLimitError.RateLimit = %+v, %v; want %+v", rl, ok, want)
+	}
+	if rl, ok := client.LastRateLimit(); !ok || rl != want {
+		t.Errorf("after 429: LastRateLimit = %+v, %v; want %+v", rl, ok, want)
+	}
+}
+
+// TestClientConcurrentRequestsAndAccessors is meant to be run with -race: it
+// exercises the client's mutable per-client state from many goroutines.
+func TestClientConcurrentRequestsAndAccessors(t *testing.T) {
+	var n atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("X-Rate-Limit-Limit", "1000")
+		w.Header().Set("X-Rate-Limit-Remaining", strconv.Itoa(int(1000-n.Add(1))))
+		w.WriteHeader(200)
+		_, _ = w.Write([]byte(`{"id":"sub_1"}`))
+	}))
+	defer srv.Close()
+
+	client, err := NewClient("sk_test", WithBaseURL(srv.URL), WithRequestIDGenerator(func() string { return "req_1" }))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 5; j++ {
+				if _, err := client.Subscription.Retrieve(context.Background(), "user_1"); err != nil {
+					t.Error(err)
+				}
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 5; j++ {
+				if rl, ok := client.LastRateLimit(); ok && rl.Limit != 1000 {
+					t.Errorf("Limit = %d", rl.Limit)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	if rl, ok := client.LastRateLimit(); !ok || rl.Remaining < 900 || rl.Remaining > 999 {
+		t.Errorf("LastRateLimit = %+v, %v", rl, ok)
+	}
+}
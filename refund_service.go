@@ -0,0 +1,61 @@
+package paylio
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// CreateRefundParams configures a refund for a subscription payment.
+type CreateRefundParams struct {
+	SubscriptionID string
+	Amount         float64
+	Reason         string
+}
+
+// RefundService provides methods for interacting with refunds.
+type RefundService struct {
+	http *httpClient
+}
+
+func newRefundService(hc *httpClient) *RefundService {
+	return &RefundService{http: hc}
+}
+
+// Create issues a refund against a subscription.
+func (s *RefundService) Create(ctx context.Context, params *CreateRefundParams, opts ...RequestOption) (*Refund, error) {
+	if params == nil {
+		return nil, errors.New("params are required")
+	}
+	if strings.TrimSpace(params.SubscriptionID) == "" {
+		return nil, errors.New("subscriptionID is required")
+	}
+	if params.Amount <= 0 {
+		return nil, errors.New("amount must be positive")
+	}
+	body := map[string]any{
+		"subscription_id": params.SubscriptionID,
+		"amount":          params.Amount,
+	}
+	if params.Reason != "" {
+		body["reason"] = params.Reason
+	}
+	data, err := s.http.request(ctx, "POST", "/refunds", applyRequestOptions(&requestOptions{JSONBody: body}, opts))
+	if err != nil {
+		return nil, err
+	}
+	return decodeResource[Refund](s.http, data)
+}
+
+// Retrieve fetches a refund by ID.
+func (s *RefundService) Retrieve(ctx context.Context, refundID string, opts ...RequestOption) (*Refund, error) {
+	if strings.TrimSpace(refundID) == "" {
+		return nil, errors.New("refundID is required")
+	}
+	data, err := s.http.request(ctx, "GET", fmt.Sprintf("/refunds/%s", refundID), applyRequestOptions(nil, opts))
+	if err != nil {
+		return nil, err
+	}
+	return decodeResource[Refund](s.http, data)
+}
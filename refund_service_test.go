@@ -0,0 +1,176 @@
+package paylio
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func newTestRefundService(handler http.HandlerFunc) (*RefundService, *httptest.Server) {
+	srv := httptest.NewServer(handler)
+	hc := newHTTPClient("sk_test", srv.URL, 10*time.Second, srv.Client())
+	return newRefundService(hc), srv
+}
+
+func TestRefundCreate(t *testing.T) {
+	svc, srv := newTestRefundService(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "POST" {
+			t.Errorf("Method = %q", r.Method)
+		}
+		if r.URL.Path != "/refunds" {
+			t.Errorf("Path = %q", r.URL.Path)
+		}
+		body, _ := io.ReadAll(r.Body)
+		var parsed map[string]any
+		if err := json.Unmarshal(body, &parsed); err != nil {
+			t.Fatal(err)
+		}
+		if parsed["subscription_id"] != "sub_1" {
+			t.Errorf("subscription_id = %v", parsed["subscription_id"])
+		}
+		if parsed["amount"] != 4.5 {
+			t.Errorf("amount = %v", parsed["amount"])
+		}
+		if parsed["reason"] != "requested_by_customer" {
+			t.Errorf("reason = %v", parsed["reason"])
+		}
+		w.WriteHeader(200)
+		_, _ = w.Write([]byte(`{"id":"re_1","amount":4.5,"currency":"usd","status":"pending","created_at":"2025-01-01T00:00:00Z"}`))
+	})
+	defer srv.Close()
+
+	refund, err := svc.Create(context.Background(), &CreateRefundParams{
+		SubscriptionID: "sub_1",
+		Amount:         4.5,
+		Reason:         "requested_by_customer",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if refund.ID != "re_1" {
+		t.Errorf("ID = %q", refund.ID)
+	}
+	if refund.Status != "pending" {
+		t.Errorf("Status = %q", refund.Status)
+	}
+}
+
+func TestRefundCreateOmitsEmptyReason(t *testing.T) {
+	svc, srv := newTestRefundService(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		var parsed map[string]any
+		if err := json.Unmarshal(body, &parsed); err != nil {
+			t.Fatal(err)
+		}
+		if _, ok := parsed["reason"]; ok {
+			t.Errorf("reason should be omitted, body = %v", parsed)
+		}
+		w.WriteHeader(200)
+		_, _ = w.Write([]byte(`{"id":"re_1"}`))
+	})
+	defer srv.Close()
+
+	_, err := svc.Create(context.Background(), &CreateRefundParams{SubscriptionID: "sub_1", Amount: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRefundCreateValidation(t *testing.T) {
+	svc, srv := newTestRefundService(func(w http.ResponseWriter, _ *http.Request) {
+		t.Error("request should not be sent")
+		w.WriteHeader(200)
+	})
+	defer srv.Close()
+
+	tests := []struct {
+		name    string
+		params  *CreateRefundParams
+		wantErr string
+	}{
+		{"nil params", nil, "params are required"},
+		{"empty subscription", &CreateRefundParams{Amount: 1}, "subscriptionID is required"},
+		{"whitespace subscription", &CreateRefundParams{SubscriptionID: "  ", Amount: 1}, "subscriptionID is required"},
+		{"zero amount", &CreateRefundParams{SubscriptionID: "sub_1"}, "amount must be positive"},
+		{"negative amount", &CreateRefundParams{SubscriptionID: "sub_1", Amount: -1}, "amount must be positive"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := svc.Create(context.Background(), tt.params)
+			if err == nil {
+				t.Fatal("expected error")
+			}
+			if err.Error() != tt.wantErr {
+				t.Errorf("error = %q, want %q", err.Error(), tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestRefundCreateAPIErrorPropagation(t *testing.T) {
+	hc := newHTTPClient("sk_test", "http://127.0.0.1:1", 5*time.Second, &http.Client{})
+	svc := newRefundService(hc)
+	_, err := svc.Create(context.Background(), &CreateRefundParams{SubscriptionID: "sub_1", Amount: 1})
+	if err == nil {
+		t.Fatal("expected error")
+	}
+}
+
+func TestRefundRetrieve(t *testing.T) {
+	svc, srv := newTestRefundService(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "GET" {
+			t.Errorf("Method = %q", r.Method)
+		}
+		if r.URL.Path != "/refunds/re_1" {
+			t.Errorf("Path = %q", r.URL.Path)
+		}
+		w.WriteHeader(200)
+		_, _ = w.Write([]byte(`{"id":"re_1","amount":9.99,"currency":"usd","status":"succeeded","created_at":"2025-01-02T00:00:00Z"}`))
+	})
+	defer srv.Close()
+
+	refund, err := svc.Retrieve(context.Background(), "re_1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if refund.ID != "re_1" {
+		t.Errorf("ID = %q", refund.ID)
+	}
+	if refund.Amount != 9.99 {
+		t.Errorf("Amount = %v", refund.Amount)
+	}
+	if refund.Currency != "usd" {
+		t.Errorf("Currency = %q", refund.Currency)
+	}
+	if refund.Status != "succeeded" {
+		t.Errorf("Status = %q", refund.Status)
+	}
+	if refund.CreatedAt != "2025-01-02T00:00:00Z" {
+		t.Errorf("CreatedAt = %q", refund.CreatedAt)
+	}
+}
+
+func TestRefundRetrieveEmptyIDReturnsError(t *testing.T) {
+	svc, srv := newTestRefundService(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(200)
+	})
+	defer srv.Close()
+
+	_, err := svc.Retrieve(context.Background(), "")
+	if err == nil {
+		t.Fatal("expected error for empty refundID")
+	}
+}
+
+func TestRefundRetrieveAPIErrorPropagation(t *testing.T) {
+	hc := newHTTPClient("sk_test", "http://127.0.0.1:1", 5*time.Second, &http.Client{})
+	svc := newRefundService(hc)
+	_, err := svc.Retrieve(context.Background(), "re_1")
+	if err == nil {
+		t.Fatal("expected error")
+	}
+}
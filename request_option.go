@@ -0,0 +1,84 @@
+package paylio
+
+import (
+	"net/http"
+	"strings"
+)
+
+// RequestOption configures a single API call, complementing the client-wide
+// Option.
+type RequestOption func(*requestOptions)
+
+// WithIfNoneMatch sends an If-None-Match header carrying a previously
+// returned ETag. If the resource is unchanged, the server responds 304 Not
+// Modified and the call returns ErrNotModified, signalling that the cached
+// copy is still current.
+func WithIfNoneMatch(etag string) RequestOption {
+	return func(o *requestOptions) { o.setHeader("If-None-Match", etag) }
+}
+
+// WithAPIKey overrides the client's API key for a single call, for example
+// to act on behalf of a different tenant without building a new client. An
+// empty key fails the call with an AuthenticationError.
+func WithAPIKey(key string) RequestOption {
+	return func(o *requestOptions) { o.APIKey = &key }
+}
+
+// WithResponseHeader stores the headers of the call's final response in *h,
+// for example to read the ETag to pass to a later WithIfNoneMatch.
+func WithResponseHeader(h *http.Header) RequestOption {
+	return func(o *requestOptions) { o.ResponseHeader = h }
+}
+
+// idempotencyKeyHeader carries the key that lets the API recognize a
+// repeated request and apply it only once.
+const idempotencyKeyHeader = "Idempotency-Key"
+
+// WithIdempotencyKey sends key as the request's Idempotency-Key, so that the
+// API applies a repeated request only once. Requests with a key are retried
+// like idempotent ones when retries are enabled, even if they are POSTs.
+func WithIdempotencyKey(key string) RequestOption {
+	return func(o *requestOptions) { o.setHeader(idempotencyKeyHeader, key) }
+}
+
+// WithExpand asks the API to embed the related objects named by fields,
+// such as "plan", in the response instead of referencing them. Each field
+// is sent as an expand[] query parameter.
+func WithExpand(fields ...string) RequestOption {
+	return func(o *requestOptions) { o.Expand = append(o.Expand, fields...) }
+}
+
+// WithFields asks the API to return only the named top-level fields, such
+// as "id" and "status", to reduce the response size. They are sent as a
+// comma-separated fields query parameter. Fields left out of the response
+// keep their zero values.
+func WithFields(fields ...string) RequestOption {
+	return func(o *requestOptions) {
+		if o.Params == nil {
+			o.Params = make(map[string]string)
+		}
+		o.Params["fields"] = strings.Join(fields, ",")
+	}
+}
+
+// applyRequestOptions applies opts to o, allocating o if needed.
+func applyRequestOptions(o *requestOptions, opts []RequestOption) *requestOptions {
+	if len(opts) == 0 {
+		return o
+	}
+	if o == nil {
+		o = &requestOptions{}
+	}
+	for _, opt := range opts {
+		opt(o)
+	}
+	return o
+}
+
+// setHeader sets a header sent with the request.
+func (o *requestOptions) setHeader(key, value string) {
+	if o.Header == nil {
+		o.Header = make(http.Header)
+	}
+	o.Header.Set(key, value)
+}
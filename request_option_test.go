@@ -0,0 +1,171 @@
+package paylio
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestWithIfNoneMatchNotModified(t *testing.T) {
+	svc, srv := newTestService(func(w http.ResponseWriter, r *http.Request) {
+		if got := r.Header.Get("If-None-Match"); got != `"v1"` {
+			t.Errorf("If-None-Match = %q", got)
+		}
+		w.WriteHeader(http.StatusNotModified)
+	})
+	defer srv.Close()
+
+	sub, err := svc.Retrieve(context.Background(), "user_1", WithIfNoneMatch(`"v1"`))
+	if !errors.Is(err, ErrNotModified) {
+		t.Fatalf("err = %v, want ErrNotModified", err)
+	}
+	if sub != nil {
+		t.Errorf("sub = %+v, want nil", sub)
+	}
+}
+
+func TestWithIfNoneMatchModifiedReturnsETag(t *testing.T) {
+	svc, srv := newTestService(func(w http.ResponseWriter, r *http.Request) {
+		if got := r.Header.Get("If-None-Match"); got != `"v1"` {
+			t.Errorf("If-None-Match = %q", got)
+		}
+		w.Header().Set("ETag", `"v2"`)
+		w.WriteHeader(200)
+		_, _ = w.Write([]byte(`{"id":"sub_1","status":"active"}`))
+	})
+	defer srv.Close()
+
+	var header http.Header
+	sub, err := svc.Retrieve(context.Background(), "user_1", WithIfNoneMatch(`"v1"`), WithResponseHeader(&header))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sub.ID != "sub_1" {
+		t.Errorf("ID = %q", sub.ID)
+	}
+	if got := header.Get("ETag"); got != `"v2"` {
+		t.Errorf("ETag = %q", got)
+	}
+}
+
+func TestNotModifiedIsNotRetried(t *testing.T) {
+	srv, hits := newSequenceServer(t,
+		sequenceResponse{status: http.StatusNotModified},
+		sequenceResponse{status: 200, body: `{"id":"sub_1"}`},
+	)
+	defer srv.Close()
+
+	hc, _ := newRetryingHTTPClient(srv.URL, 3)
+	_, err := hc.request(context.Background(), "GET", "/subscription/user_1", nil)
+	if !errors.Is(err, ErrNotModified) {
+		t.Fatalf("err = %v, want ErrNotModified", err)
+	}
+	if got := hits.Load(); got != 1 {
+		t.Errorf("hits = %d, want 1", got)
+	}
+}
+
+func TestApplyRequestOptions(t *testing.T) {
+	if got := applyRequestOptions(nil, nil); got != nil {
+		t.Errorf("applyRequestOptions(nil, nil) = %+v, want nil", got)
+	}
+	o := &requestOptions{Params: map[string]string{"page": "1"}}
+	got := applyRequestOptions(o, []RequestOption{WithIfNoneMatch("x")})
+	if got != o {
+		t.Error("expected options to be applied in place")
+	}
+	if got.Header.Get("If-None-Match") != "x" || got.Params["page"] != "1" {
+		t.Errorf("options = %+v", got)
+	}
+}
+
+func TestWithAPIKeyOverridesClientKey(t *testing.T) {
+	var keys []string
+	svc, srv := newTestService(func(w http.ResponseWriter, r *http.Request) {
+		keys = append(keys, r.Header.Get("X-API-Key"))
+		w.WriteHeader(200)
+		_, _ = w.Write([]byte(`{"id":"sub_1"}`))
+	})
+	defer srv.Close()
+
+	if _, err := svc.Retrieve(context.Background(), "user_1", WithAPIKey("sk_tenant_b")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := svc.Retrieve(context.Background(), "user_1"); err != nil {
+		t.Fatal(err)
+	}
+	if len(keys) != 2 || keys[0] != "sk_tenant_b" || keys[1] != "sk_test" {
+		t.Errorf("X-API-Key values = %q, want [sk_tenant_b sk_test]", keys)
+	}
+}
+
+func TestWithAPIKeyEmptyReturnsAuthenticationError(t *testing.T) {
+	svc, srv := newTestService(func(http.ResponseWriter, *http.Request) {
+		t.Error("request should not be sent")
+	})
+	defer srv.Close()
+
+	_, err := svc.Retrieve(context.Background(), "user_1", WithAPIKey("  "))
+	var authErr *AuthenticationError
+	if !errors.As(err, &authErr) {
+		t.Fatalf("expected *AuthenticationError, got %T: %v", err, err)
+	}
+}
+
+func TestRequestOptionsOnListAndWrites(t *testing.T) {
+	var keys []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		keys = append(keys, r.Header.Get("X-API-Key"))
+		w.Header().Set("X-Trace", r.URL.Path)
+		w.WriteHeader(200)
+		_, _ = w.Write([]byte(`{"id":"x","items":[],"page":1,"total_pages":1}`))
+	}))
+	defer srv.Close()
+
+	client, err := NewClient("sk_test", WithBaseURL(srv.URL))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	key := WithAPIKey("sk_other")
+
+	var header http.Header
+	if _, err := client.Subscription.List(ctx, "user_1", nil, key, WithResponseHeader(&header)); err != nil {
+		t.Fatal(err)
+	}
+	if got := header.Get("X-Trace"); got != "/users/user_1/subscriptions" {
+		t.Errorf("X-Trace = %q", got)
+	}
+	if _, err := client.Subscription.Create(ctx, &CreateSubscriptionParams{UserID: "u", PlanSlug: "p"}, key); err != nil {
+		t.Fatal(err)
+	}
+	var out map[string]any
+	if err := client.Subscription.RetrieveInto(ctx, "user_1", &out, key); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.Subscription.Cancel(ctx, "sub_1", nil, key); err != nil {
+		t.Fatal(err)
+	}
+	it := client.Subscription.ListAutoPaging(ctx, "user_1", nil, key)
+	for it.Next() {
+	}
+	if _, err := client.Invoices.List(ctx, "sub_1", nil, key); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.Refunds.Create(ctx, &CreateRefundParams{SubscriptionID: "sub_1", Amount: 100}, key); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.Disputes.SubmitEvidence(ctx, "dp_1", nil, map[string]string{"note": "n"}, key); err != nil {
+		t.Fatal(err)
+	}
+	for i, k := range keys {
+		if k != "sk_other" {
+			t.Errorf("request %d X-API-Key = %q", i, k)
+		}
+	}
+	if len(keys) != 8 {
+		t.Errorf("requests = %d, want 8", len(keys))
+	}
+}
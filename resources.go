@@ -1,17 +1,83 @@
 package paylio
 
 import (
+	"bytes"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// Provider identifies the payment provider backing a subscription. Values
+// returned by the API that the SDK does not know are preserved as-is.
+type Provider string
+
+// Known payment providers.
+const (
+	ProviderStripe    Provider = "stripe"
+	ProviderPaddle    Provider = "paddle"
+	ProviderBraintree Provider = "braintree"
+)
+
+// isKnown reports whether p is one of the providers the SDK supports.
+func (p Provider) isKnown() bool {
+	switch p {
+	case ProviderStripe, ProviderPaddle, ProviderBraintree:
+		return true
+	default:
+		return false
+	}
+}
+
+// SubscriptionStatus is the lifecycle state of a subscription. Values
+// returned by the API that the SDK does not know are preserved as-is.
+type SubscriptionStatus string
+
+// Known subscription statuses.
+const (
+	SubscriptionStatusActive   SubscriptionStatus = "active"
+	SubscriptionStatusTrialing SubscriptionStatus = "trialing"
+	SubscriptionStatusPastDue  SubscriptionStatus = "past_due"
+	SubscriptionStatusCanceled SubscriptionStatus = "canceled"
+)
+
+// Interval is the unit of a plan's billing period. Values returned by the
+// API that the SDK does not know are preserved as-is.
+type Interval string
+
+// Known billing intervals.
+const (
+	IntervalDay   Interval = "day"
+	IntervalWeek  Interval = "week"
+	IntervalMonth Interval = "month"
+	IntervalYear  Interval = "year"
 )
 
 // Plan represents a subscription plan.
 type Plan struct {
-	Slug     string  `json:"slug"`
-	Name     string  `json:"name"`
-	Interval string  `json:"interval"`
-	Amount   float64 `json:"amount"`
-	Currency string  `json:"currency"`
+	Slug     string   `json:"slug"`
+	Name     string   `json:"name"`
+	Interval Interval `json:"interval"`
+	// IntervalCount is the number of intervals between billings, such as 3
+	// for a plan billed every 3 months. It is 1 when the API omits it.
+	IntervalCount int     `json:"interval_count"`
+	Amount        float64 `json:"amount"`
+	Currency      string  `json:"currency"`
+}
+
+// UnmarshalJSON decodes a plan, defaulting IntervalCount to 1 when the field
+// is absent.
+func (p *Plan) UnmarshalJSON(data []byte) error {
+	type plan Plan
+	aux := plan{IntervalCount: 1}
+	if err := json.Unmarshal(data, &aux); err != nil {
+		return err
+	}
+	*p = Plan(aux)
+	return nil
 }
 
 // Period represents a time period with start and end timestamps.
@@ -20,18 +86,141 @@ type Period struct {
 	End   string `json:"end"`
 }
 
-// Subscription represents a user's subscription.
+// Discount represents a coupon applied to a subscription. Either PercentOff
+// or AmountOff (in Currency) is set.
+type Discount struct {
+	CouponCode string     `json:"coupon_code"`
+	PercentOff float64    `json:"percent_off"`
+	AmountOff  float64    `json:"amount_off"`
+	Currency   string     `json:"currency"`
+	EndsAt     *time.Time `json:"ends_at"`
+}
+
+// Subscription represents a user's subscription. Marshaling it omits empty
+// optional fields, so a partially populated subscription stays compact.
 type Subscription struct {
-	ID                 string  `json:"id"`
-	Object             string  `json:"object"`
-	Status             string  `json:"status"`
-	UserID             string  `json:"user_id"`
-	Plan               Plan    `json:"plan"`
-	SubscriptionPeriod Period  `json:"subscription_period"`
-	CancelAtPeriodEnd  bool    `json:"cancel_at_period_end"`
-	CanceledAt         *string `json:"canceled_at"`
-	Provider           string  `json:"provider"`
-	CreatedAt          string  `json:"created_at"`
+	ID                 string             `json:"id"`
+	Object             string             `json:"object,omitempty"`
+	Status             SubscriptionStatus `json:"status"`
+	UserID             string             `json:"user_id,omitempty"`
+	Plan               Plan               `json:"plan"`
+	SubscriptionPeriod Period             `json:"subscription_period"`
+	CancelAtPeriodEnd  bool               `json:"cancel_at_period_end,omitempty"`
+	CanceledAt         *string            `json:"canceled_at,omitempty"`
+	Provider           Provider           `json:"provider,omitempty"`
+	TrialStart         *time.Time         `json:"trial_start,omitempty"`
+	TrialEnd           *time.Time         `json:"trial_end,omitempty"`
+	// Quantity is the number of seats. It is 1 when the API omits it.
+	Quantity int `json:"quantity"`
+	// Discount is the discount applied to the subscription, if any.
+	Discount  *Discount `json:"discount,omitempty"`
+	CreatedAt string    `json:"created_at,omitempty"`
+	// ProviderIDs maps each payment provider, such as "stripe", to the
+	// subscription's identifier on that provider. Use ProviderID to look one
+	// up.
+	ProviderIDs map[string]string `json:"provider_ids,omitempty"`
+
+	// Raw holds every field of the JSON object the subscription was decoded
+	// from, including fields this struct does not model yet.
+	Raw map[string]json.RawMessage `json:"-"`
+}
+
+// UnmarshalJSON decodes a subscription, defaulting Quantity to 1 when the
+// field is absent and retaining the original fields in Raw.
+func (s *Subscription) UnmarshalJSON(data []byte) error {
+	type subscription Subscription
+	aux := subscription{Quantity: 1}
+	if err := json.Unmarshal(data, &aux); err != nil {
+		return err
+	}
+	// data is a valid JSON object at this point, so decoding it into a map of
+	// raw messages cannot fail.
+	_ = json.Unmarshal(data, &aux.Raw)
+	*s = Subscription(aux)
+	return nil
+}
+
+// MarshalJSON encodes a subscription, leaving out the plan and subscription
+// period when they are zero. Quantity is always written so that it
+// round-trips through UnmarshalJSON unchanged.
+func (s Subscription) MarshalJSON() ([]byte, error) {
+	type subscription Subscription
+	aux := struct {
+		subscription
+		Plan               *Plan   `json:"plan,omitempty"`
+		SubscriptionPeriod *Period `json:"subscription_period,omitempty"`
+	}{subscription: subscription(s)}
+	if s.Plan != (Plan{}) {
+		aux.Plan = &s.Plan
+	}
+	if s.SubscriptionPeriod != (Period{}) {
+		aux.SubscriptionPeriod = &s.SubscriptionPeriod
+	}
+	return json.Marshal(aux)
+}
+
+// IsTrialing reports whether now falls within the subscription's trial
+// period. It is false for subscriptions without a trial end.
+func (s *Subscription) IsTrialing(now time.Time) bool {
+	if s.TrialEnd == nil || !now.Before(*s.TrialEnd) {
+		return false
+	}
+	return s.TrialStart == nil || !now.Before(*s.TrialStart)
+}
+
+// DaysRemaining returns the number of days from now until the end of the
+// current period, counting a partial day as a whole one. It is 0 once the
+// period has ended, or when its end is unset or not an RFC 3339 time.
+func (s *Subscription) DaysRemaining(now time.Time) int {
+	end, err := time.Parse(time.RFC3339, s.SubscriptionPeriod.End)
+	if err != nil || !now.Before(end) {
+		return 0
+	}
+	return int((end.Sub(now) + 24*time.Hour - 1) / (24 * time.Hour))
+}
+
+// ProviderID returns the subscription's identifier on provider. It reports
+// false when the API did not return one.
+func (s *Subscription) ProviderID(provider Provider) (string, bool) {
+	id, ok := s.ProviderIDs[string(provider)]
+	return id, ok
+}
+
+// IsActive reports whether the subscription's status is active.
+func (s *Subscription) IsActive() bool {
+	return s.Status == SubscriptionStatusActive
+}
+
+// IsCanceled reports whether the subscription's status is canceled.
+func (s *Subscription) IsCanceled() bool {
+	return s.Status == SubscriptionStatusCanceled
+}
+
+// IsPastDue reports whether the subscription's status is past due.
+func (s *Subscription) IsPastDue() bool {
+	return s.Status == SubscriptionStatusPastDue
+}
+
+// HasTrialStatus reports whether the subscription's status is trialing.
+// Unlike IsTrialing, it relies on the status alone rather than the trial
+// dates.
+func (s *Subscription) HasTrialStatus() bool {
+	return s.Status == SubscriptionStatusTrialing
+}
+
+// WillCancelAtPeriodEnd reports whether the subscription is still running
+// but scheduled to cancel at the end of the current period.
+func (s *Subscription) WillCancelAtPeriodEnd() bool {
+	return s.CancelAtPeriodEnd && !s.IsCanceled()
+}
+
+// SubscriptionEvent represents a live subscription update delivered by
+// SubscriptionService.Watch.
+type SubscriptionEvent struct {
+	ID           string       `json:"id"`
+	Type         string       `json:"type"`
+	Subscription Subscription `json:"subscription"`
+	CreatedAt    string       `json:"created_at"`
 }
 
 // SubscriptionCancel represents the result of canceling a subscription.
@@ -42,6 +231,15 @@ type SubscriptionCancel struct {
 	CancelAtPeriodEnd bool   `json:"cancel_at_period_end"`
 }
 
+// StatusChange represents one status transition in a subscription's audit
+// trail.
+type StatusChange struct {
+	From      SubscriptionStatus `json:"from"`
+	To        SubscriptionStatus `json:"to"`
+	ChangedAt time.Time          `json:"changed_at"`
+	Reason    string             `json:"reason"`
+}
+
 // SubscriptionHistoryItem represents a single item in subscription history.
 type SubscriptionHistoryItem struct {
 	ID                 string  `json:"id"`
@@ -55,6 +253,47 @@ type SubscriptionHistoryItem struct {
 	CurrentPeriodStart string  `json:"current_period_start"`
 	CurrentPeriodEnd   string  `json:"current_period_end"`
 	CreatedAt          string  `json:"created_at"`
+	// Plan is the full plan, present only when "plan" is expanded.
+	Plan *Plan `json:"plan,omitempty"`
+}
+
+// Refund represents a refund issued against a subscription payment.
+type Refund struct {
+	ID        string  `json:"id"`
+	Amount    float64 `json:"amount"`
+	Currency  string  `json:"currency"`
+	Status    string  `json:"status"`
+	CreatedAt string  `json:"created_at"`
+}
+
+// Invoice represents an invoice issued for a subscription billing period.
+type Invoice struct {
+	ID          string  `json:"id"`
+	Amount      float64 `json:"amount"`
+	Currency    string  `json:"currency"`
+	Status      string  `json:"status"`
+	PeriodStart string  `json:"period_start"`
+	PeriodEnd   string  `json:"period_end"`
+	PaidAt      *string `json:"paid_at"`
+}
+
+// Dispute represents a chargeback raised against a subscription payment.
+type Dispute struct {
+	ID             string  `json:"id"`
+	SubscriptionID string  `json:"subscription_id"`
+	Amount         float64 `json:"amount"`
+	Currency       string  `json:"currency"`
+	Reason         string  `json:"reason"`
+	Status         string  `json:"status"`
+	CreatedAt      string  `json:"created_at"`
+}
+
+// UsageRecord represents a usage quantity reported for a metered
+// subscription.
+type UsageRecord struct {
+	ID        string `json:"id"`
+	Quantity  int64  `json:"quantity"`
+	Timestamp string `json:"timestamp"`
 }
 
 // PaginatedList is a generic paginated response container.
@@ -64,6 +303,15 @@ type PaginatedList[T any] struct {
 	Page       int `json:"page"`
 	PageSize   int `json:"page_size"`
 	TotalPages int `json:"total_pages"`
+
+	// NextCursor and PrevCursor are set by endpoints that paginate with
+	// cursors. Pass NextCursor as ListOptions.Cursor to fetch the next page.
+	NextCursor string `json:"next_cursor,omitempty"`
+	PrevCursor string `json:"prev_cursor,omitempty"`
+
+	// header holds the response headers of the request that produced this
+	// page, when it came from the API.
+	header http.Header
 }
 
 // HasMore returns true if there are additional pages of results.
@@ -71,15 +319,97 @@ func (p *PaginatedList[T]) HasMore() bool {
 	return p.Page > 0 && p.Page < p.TotalPages
 }
 
-// unmarshalTo converts a map[string]any to a typed struct via JSON round-trip.
-func unmarshalTo[T any](data map[string]any) (*T, error) {
-	b, err := json.Marshal(data)
+// HasMoreCursor returns true if the response carries a cursor to the next
+// page of results.
+func (p *PaginatedList[T]) HasMoreCursor() bool {
+	return p.NextCursor != ""
+}
+
+// IsEmpty returns true if the list has no results at all, as opposed to an
+// empty page past the end of a non-empty list.
+func (p *PaginatedList[T]) IsEmpty() bool {
+	return p.Total == 0 && len(p.Items) == 0
+}
+
+// PageInfo returns the page number, total number of pages, and total number
+// of items reported with this page.
+func (p *PaginatedList[T]) PageInfo() (page, totalPages, total int) {
+	return p.Page, p.TotalPages, p.Total
+}
+
+// ForEach calls f for each item of the page, in order.
+func (p *PaginatedList[T]) ForEach(f func(T)) {
+	for _, item := range p.Items {
+		f(item)
+	}
+}
+
+// MapItems returns f applied to each item of the page, in order.
+func MapItems[T, U any](pl *PaginatedList[T], f func(T) U) []U {
+	out := make([]U, len(pl.Items))
+	for i, item := range pl.Items {
+		out[i] = f(item)
+	}
+	return out
+}
+
+// RateLimitRemaining returns the number of requests remaining in the current
+// rate-limit window, as reported by the X-Rate-Limit-Remaining header of the
+// response that produced this page. It reports false when the header is
+// absent or malformed.
+func (p *PaginatedList[T]) RateLimitRemaining() (int, bool) {
+	n, err := strconv.Atoi(p.header.Get("X-Rate-Limit-Remaining"))
 	if err != nil {
-		return nil, fmt.Errorf("failed to marshal response: %w", err)
+		return 0, false
 	}
+	return n, true
+}
+
+// unmarshalTo converts a map[string]any to a typed struct via JSON round-trip.
+func unmarshalTo[T any](data map[string]any) (*T, error) {
 	var result T
-	if err := json.Unmarshal(b, &result); err != nil {
-		return nil, fmt.Errorf("failed to unmarshal response: %w", err)
+	if err := decodeInto(data, &result); err != nil {
+		return nil, err
 	}
 	return &result, nil
 }
+
+// encodeBody converts a params struct into the map form sent as a JSON
+// request body, honoring its json tags. Numbers are kept as json.Number so
+// that large integers survive the round-trip. Params structs hold only
+// strings, numbers, bools, and maps of those, which always marshal.
+func encodeBody(v any) map[string]any {
+	b, _ := json.Marshal(v)
+	var body map[string]any
+	_ = unmarshalJSON(b, &body)
+	return body
+}
+
+// unmarshalJSON is json.Unmarshal except that numbers decoded into interface
+// values become json.Number rather than float64, so large integers such as
+// amounts in minor units keep their exact value.
+func unmarshalJSON(data []byte, v any) error {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	if err := dec.Decode(v); err != nil {
+		return err
+	}
+	if _, err := dec.Token(); err != io.EOF {
+		return errors.New("invalid character after top-level value")
+	}
+	return nil
+}
+
+// decodeInto converts a map[string]any into the value pointed to by out via
+// JSON round-trip, using the configured JSON functions. By default numbers
+// are preserved exactly; see unmarshalJSON.
+func decodeInto(data map[string]any, out any) error {
+	b, err := jsonMarshal(data)
+	if err != nil {
+		return fmt.Errorf("failed to marshal response: %w", err)
+	}
+	if err := jsonUnmarshal(b, out); err != nil {
+		return fmt.Errorf("failed to unmarshal response: %w", err)
+	}
+	return nil
+}
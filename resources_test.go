@@ -1,8 +1,13 @@
 package paylio
 
 import (
+	"context"
 	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
 	"testing"
+	"time"
 )
 
 func TestSubscriptionUnmarshal(t *testing.T) {
@@ -82,6 +87,176 @@ func TestSubscriptionCanceledAtNonNull(t *testing.T) {
 	}
 }
 
+func TestSubscriptionTrialFields(t *testing.T) {
+	tests := []struct {
+		name      string
+		raw       string
+		wantStart *time.Time
+		wantEnd   *time.Time
+	}{
+		{
+			name:      "present",
+			raw:       `{"id":"sub_1","trial_start":"2025-03-01T00:00:00Z","trial_end":"2025-03-15T00:00:00Z"}`,
+			wantStart: ptrTime(time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC)),
+			wantEnd:   ptrTime(time.Date(2025, 3, 15, 0, 0, 0, 0, time.UTC)),
+		},
+		{name: "null", raw: `{"id":"sub_1","trial_start":null,"trial_end":null}`},
+		{name: "absent", raw: `{"id":"sub_1"}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var sub Subscription
+			if err := json.Unmarshal([]byte(tt.raw), &sub); err != nil {
+				t.Fatal(err)
+			}
+			if !equalTimePtr(sub.TrialStart, tt.wantStart) {
+				t.Errorf("TrialStart = %v, want %v", sub.TrialStart, tt.wantStart)
+			}
+			if !equalTimePtr(sub.TrialEnd, tt.wantEnd) {
+				t.Errorf("TrialEnd = %v, want %v", sub.TrialEnd, tt.wantEnd)
+			}
+		})
+	}
+}
+
+func TestSubscriptionQuantity(t *testing.T) {
+	tests := []struct {
+		name string
+		raw  string
+		want int
+	}{
+		{"present", `{"id":"sub_1","quantity":12}`, 12},
+		{"absent", `{"id":"sub_1"}`, 1},
+	}
+	for _, tt := range tests {
+		var sub Subscription
+		if err := json.Unmarshal([]byte(tt.raw), &sub); err != nil {
+			t.Fatal(err)
+		}
+		if sub.Quantity != tt.want {
+			t.Errorf("%s: Quantity = %d, want %d", tt.name, sub.Quantity, tt.want)
+		}
+		if sub.ID != "sub_1" {
+			t.Errorf("%s: ID = %q", tt.name, sub.ID)
+		}
+	}
+}
+
+func TestSubscriptionUnmarshalInvalid(t *testing.T) {
+	var sub Subscription
+	if err := json.Unmarshal([]byte(`{"quantity":"many"}`), &sub); err == nil {
+		t.Error("expected error for non-numeric quantity")
+	}
+}
+
+func TestSubscriptionDiscount(t *testing.T) {
+	raw := `{"id":"sub_1","discount":{"coupon_code":"SPRING25","percent_off":25,"amount_off":0,"currency":"usd","ends_at":"2025-06-01T00:00:00Z"}}`
+	var sub Subscription
+	if err := json.Unmarshal([]byte(raw), &sub); err != nil {
+		t.Fatal(err)
+	}
+	d := sub.Discount
+	if d == nil {
+		t.Fatal("Discount = nil")
+	}
+	if d.CouponCode != "SPRING25" || d.PercentOff != 25 || d.AmountOff != 0 || d.Currency != "usd" {
+		t.Errorf("Discount = %+v", d)
+	}
+	if d.EndsAt == nil || !d.EndsAt.Equal(time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("EndsAt = %v", d.EndsAt)
+	}
+
+	for _, raw := range []string{`{"id":"sub_1","discount":null}`, `{"id":"sub_1"}`} {
+		var sub Subscription
+		if err := json.Unmarshal([]byte(raw), &sub); err != nil {
+			t.Fatal(err)
+		}
+		if sub.Discount != nil {
+			t.Errorf("%s: Discount = %+v, want nil", raw, sub.Discount)
+		}
+	}
+}
+
+func TestSubscriptionProviderIDs(t *testing.T) {
+	var sub Subscription
+	if err := json.Unmarshal([]byte(`{"id":"sub_1","provider_ids":{"stripe":"sub_1Nx9","paddle":"pdl_42"}}`), &sub); err != nil {
+		t.Fatal(err)
+	}
+	if id, ok := sub.ProviderID(ProviderStripe); !ok || id != "sub_1Nx9" {
+		t.Errorf("ProviderID(stripe) = %q, %v", id, ok)
+	}
+	if id, ok := sub.ProviderID(ProviderPaddle); !ok || id != "pdl_42" {
+		t.Errorf("ProviderID(paddle) = %q, %v", id, ok)
+	}
+	if id, ok := sub.ProviderID(ProviderBraintree); ok {
+		t.Errorf("ProviderID(braintree) = %q, want absent", id)
+	}
+
+	var bare Subscription
+	if err := json.Unmarshal([]byte(`{"id":"sub_2"}`), &bare); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := bare.ProviderID(ProviderStripe); ok || bare.ProviderIDs != nil {
+		t.Errorf("absent provider_ids: ProviderIDs = %v", bare.ProviderIDs)
+	}
+}
+
+func TestSubscriptionIsTrialing(t *testing.T) {
+	start := time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC)
+	end := time.Date(2025, 3, 15, 0, 0, 0, 0, time.UTC)
+	trial := Subscription{TrialStart: &start, TrialEnd: &end}
+	tests := []struct {
+		name string
+		sub  Subscription
+		now  time.Time
+		want bool
+	}{
+		{"during trial", trial, start.Add(time.Hour), true},
+		{"at trial start", trial, start, true},
+		{"before trial", trial, start.Add(-time.Hour), false},
+		{"at trial end", trial, end, false},
+		{"after trial", trial, end.Add(time.Hour), false},
+		{"no trial", Subscription{}, start, false},
+		{"end only", Subscription{TrialEnd: &end}, start, true},
+	}
+	for _, tt := range tests {
+		if got := tt.sub.IsTrialing(tt.now); got != tt.want {
+			t.Errorf("%s: IsTrialing() = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestSubscriptionDaysRemaining(t *testing.T) {
+	now := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name string
+		end  string
+		want int
+	}{
+		{"future end", "2025-03-11T12:00:00Z", 10},
+		{"partial day", "2025-03-02T00:00:00Z", 1},
+		{"past end", "2025-02-01T00:00:00Z", 0},
+		{"ends now", "2025-03-01T12:00:00Z", 0},
+		{"unset", "", 0},
+		{"malformed", "soon", 0},
+	}
+	for _, tt := range tests {
+		sub := Subscription{SubscriptionPeriod: Period{End: tt.end}}
+		if got := sub.DaysRemaining(now); got != tt.want {
+			t.Errorf("%s: DaysRemaining() = %d, want %d", tt.name, got, tt.want)
+		}
+	}
+}
+
+func ptrTime(t time.Time) *time.Time { return &t }
+
+func equalTimePtr(a, b *time.Time) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return a.Equal(*b)
+}
+
 func TestSubscriptionMarshalRoundTrip(t *testing.T) {
 	original := Subscription{
 		ID:     "sub_1",
@@ -101,6 +276,108 @@ func TestSubscriptionMarshalRoundTrip(t *testing.T) {
 	}
 }
 
+func TestSubscriptionMarshalOmitsEmptyOptionalFields(t *testing.T) {
+	data, err := json.Marshal(Subscription{ID: "sub_1", Status: "active", Quantity: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `{"id":"sub_1","status":"active","quantity":1}`
+	if string(data) != want {
+		t.Errorf("Marshal = %s, want %s", data, want)
+	}
+
+	canceled := "2025-01-01T00:00:00Z"
+	full := Subscription{
+		ID:                 "sub_1",
+		Plan:               Plan{Slug: "pro"},
+		SubscriptionPeriod: Period{Start: "2025-01-01"},
+		CanceledAt:         &canceled,
+	}
+	data, _ = json.Marshal(full)
+	var decoded Subscription
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if decoded.Plan.Slug != "pro" || decoded.SubscriptionPeriod.Start != "2025-01-01" || *decoded.CanceledAt != canceled {
+		t.Errorf("round-trip = %s", data)
+	}
+}
+
+func TestPlanUnmarshalInterval(t *testing.T) {
+	tests := []struct {
+		raw       string
+		interval  Interval
+		count     int
+		wantError bool
+	}{
+		{`{"slug":"pro","interval":"month"}`, IntervalMonth, 1, false},
+		{`{"slug":"pro","interval":"month","interval_count":3}`, IntervalMonth, 3, false},
+		{`{"slug":"pro","interval":"year","interval_count":1}`, IntervalYear, 1, false},
+		{`{"slug":"pro","interval":"fortnight","interval_count":2}`, Interval("fortnight"), 2, false},
+		{`{"slug":"pro","interval_count":"3"}`, "", 0, true},
+	}
+	for _, tt := range tests {
+		var p Plan
+		err := json.Unmarshal([]byte(tt.raw), &p)
+		if (err != nil) != tt.wantError {
+			t.Errorf("%s: err = %v", tt.raw, err)
+			continue
+		}
+		if err == nil && (p.Interval != tt.interval || p.IntervalCount != tt.count) {
+			t.Errorf("%s: Interval = %q, IntervalCount = %d", tt.raw, p.Interval, p.IntervalCount)
+		}
+	}
+}
+
+func TestSubscriptionStatusPredicates(t *testing.T) {
+	tests := []struct {
+		status            SubscriptionStatus
+		cancelAtPeriodEnd bool
+		active, canceled  bool
+		pastDue, trialing bool
+		willCancel        bool
+	}{
+		{status: SubscriptionStatusActive, active: true},
+		{status: SubscriptionStatusActive, cancelAtPeriodEnd: true, active: true, willCancel: true},
+		{status: SubscriptionStatusTrialing, trialing: true},
+		{status: SubscriptionStatusTrialing, cancelAtPeriodEnd: true, trialing: true, willCancel: true},
+		{status: SubscriptionStatusPastDue, pastDue: true},
+		{status: SubscriptionStatusCanceled, canceled: true},
+		{status: SubscriptionStatusCanceled, cancelAtPeriodEnd: true, canceled: true},
+		{status: "paused"},
+	}
+	for _, tt := range tests {
+		sub := Subscription{Status: tt.status, CancelAtPeriodEnd: tt.cancelAtPeriodEnd}
+		got := [5]bool{sub.IsActive(), sub.IsCanceled(), sub.IsPastDue(), sub.HasTrialStatus(), sub.WillCancelAtPeriodEnd()}
+		want := [5]bool{tt.active, tt.canceled, tt.pastDue, tt.trialing, tt.willCancel}
+		if got != want {
+			t.Errorf("%s (cancel_at_period_end=%v): [active canceled pastDue trialing willCancel] = %v, want %v",
+				tt.status, tt.cancelAtPeriodEnd, got, want)
+		}
+	}
+}
+
+func TestPaginatedListForEachAndMapItems(t *testing.T) {
+	list := &PaginatedList[SubscriptionHistoryItem]{Items: []SubscriptionHistoryItem{
+		{ID: "sub_1", Status: "active"},
+		{ID: "sub_2", Status: "canceled"},
+	}}
+
+	var visited []string
+	list.ForEach(func(item SubscriptionHistoryItem) { visited = append(visited, item.ID) })
+	if strings.Join(visited, ",") != "sub_1,sub_2" {
+		t.Errorf("ForEach visited %v", visited)
+	}
+
+	ids := MapItems(list, func(item SubscriptionHistoryItem) string { return item.ID })
+	if strings.Join(ids, ",") != "sub_1,sub_2" {
+		t.Errorf("MapItems = %v", ids)
+	}
+	if got := MapItems(&PaginatedList[SubscriptionHistoryItem]{}, func(item SubscriptionHistoryItem) string { return item.ID }); got == nil || len(got) != 0 {
+		t.Errorf("MapItems on an empty page = %#v, want an empty slice", got)
+	}
+}
+
 func TestSubscriptionCancelUnmarshal(t *testing.T) {
 	raw := `{"id":"sub_1","object":"subscription_cancel","success":true,"cancel_at_period_end":true}`
 	var sc SubscriptionCancel
@@ -177,6 +454,38 @@ func TestPaginatedListHasMore(t *testing.T) {
 	}
 }
 
+func TestPaginatedListIsEmptyAndPageInfo(t *testing.T) {
+	var empty PaginatedList[SubscriptionHistoryItem]
+	if err := json.Unmarshal([]byte(`{"items":[],"total":0,"page":1,"page_size":20,"total_pages":0}`), &empty); err != nil {
+		t.Fatal(err)
+	}
+	if !empty.IsEmpty() || empty.HasMore() {
+		t.Errorf("empty: IsEmpty() = %v, HasMore() = %v", empty.IsEmpty(), empty.HasMore())
+	}
+	if page, totalPages, total := empty.PageInfo(); page != 1 || totalPages != 0 || total != 0 {
+		t.Errorf("empty: PageInfo() = %d, %d, %d", page, totalPages, total)
+	}
+
+	populated := PaginatedList[SubscriptionHistoryItem]{
+		Items:      []SubscriptionHistoryItem{{ID: "h_1"}},
+		Total:      3,
+		Page:       2,
+		PageSize:   1,
+		TotalPages: 3,
+	}
+	if populated.IsEmpty() {
+		t.Error("populated: IsEmpty() = true")
+	}
+	if page, totalPages, total := populated.PageInfo(); page != 2 || totalPages != 3 || total != 3 {
+		t.Errorf("populated: PageInfo() = %d, %d, %d", page, totalPages, total)
+	}
+
+	pastEnd := PaginatedList[SubscriptionHistoryItem]{Total: 3, Page: 4, TotalPages: 3}
+	if pastEnd.IsEmpty() {
+		t.Error("page past the end: IsEmpty() = true")
+	}
+}
+
 func TestPaginatedListUnmarshal(t *testing.T) {
 	raw := `{
 		"items": [{"id": "sub_1", "status": "active"}, {"id": "sub_2", "status": "canceled"}],
@@ -213,3 +522,78 @@ func TestUnmarshalToSuccess(t *testing.T) {
 		t.Errorf("ID = %q", result.ID)
 	}
 }
+
+func TestSubscriptionUnknownProviderUnmarshals(t *testing.T) {
+	var sub Subscription
+	if err := json.Unmarshal([]byte(`{"id":"sub_1","provider":"mollie"}`), &sub); err != nil {
+		t.Fatal(err)
+	}
+	if sub.Provider != Provider("mollie") {
+		t.Errorf("Provider = %q", sub.Provider)
+	}
+	if sub.Provider.isKnown() {
+		t.Error("mollie should not be a known provider")
+	}
+}
+
+func TestProviderIsKnown(t *testing.T) {
+	for _, p := range []Provider{ProviderStripe, ProviderPaddle, ProviderBraintree} {
+		if !p.isKnown() {
+			t.Errorf("%q should be known", p)
+		}
+	}
+	for _, p := range []Provider{"", "Stripe", "paypal"} {
+		if p.isKnown() {
+			t.Errorf("%q should not be known", p)
+		}
+	}
+}
+
+func TestPaginatedListRateLimitRemainingAbsent(t *testing.T) {
+	var pl PaginatedList[SubscriptionHistoryItem]
+	if _, ok := pl.RateLimitRemaining(); ok {
+		t.Error("expected false without response headers")
+	}
+	pl.header = http.Header{"X-Rate-Limit-Remaining": {"lots"}}
+	if _, ok := pl.RateLimitRemaining(); ok {
+		t.Error("expected false for malformed header")
+	}
+}
+
+func TestLargeIntegerAmountRoundTripsExactly(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(200)
+		_, _ = w.Write([]byte(`{"id":"in_1","amount_minor":9007199254740993}`))
+	}))
+	defer srv.Close()
+
+	hc := newHTTPClient("sk_test", srv.URL, 10*time.Second, srv.Client())
+	data, err := hc.request(context.Background(), "GET", "/invoices/in_1", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n, ok := data["amount_minor"].(json.Number); !ok || n.String() != "9007199254740993" {
+		t.Errorf("amount_minor = %#v, want json.Number 9007199254740993", data["amount_minor"])
+	}
+
+	type invoice struct {
+		AmountMinor int64 `json:"amount_minor"`
+	}
+	inv, err := unmarshalTo[invoice](data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if inv.AmountMinor != 9007199254740993 {
+		t.Errorf("AmountMinor = %d, want 9007199254740993", inv.AmountMinor)
+	}
+}
+
+func TestUnmarshalJSONRejectsTrailingData(t *testing.T) {
+	var v map[string]any
+	if err := unmarshalJSON([]byte(`{"a":1} {"b":2}`), &v); err == nil {
+		t.Error("expected error for trailing data")
+	}
+	if err := unmarshalJSON([]byte(`{"a":1}`+"\n"), &v); err != nil {
+		t.Errorf("trailing whitespace: %v", err)
+	}
+}
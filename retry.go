@@ -0,0 +1,213 @@
+package paylio
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"io"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"syscall"
+	"time"
+)
+
+const (
+	// initialRetryDelay is the backoff before the first retry.
+	initialRetryDelay = 500 * time.Millisecond
+
+	// defaultMaxRetryDelay caps the delay between retries unless overridden
+	// with WithMaxRetryDelay.
+	defaultMaxRetryDelay = 8 * time.Second
+)
+
+// backoffDelay returns the exponential delay before the given retry attempt
+// (1-based), doubling from initialRetryDelay and capped at
+// defaultMaxRetryDelay.
+func backoffDelay(attempt int) time.Duration {
+	return exponentialDelay(initialRetryDelay, defaultMaxRetryDelay, attempt)
+}
+
+// RetryPolicy computes the backoff between retries. Backoff is called before
+// each retry attempt (1-based) of a request that failed with err; resp holds
+// the status code and headers of the failed response, or is nil for
+// connection failures, and its body has already been consumed. Returning
+// false stops retrying. The client only consults the policy for failures it
+// considers retryable, and a Retry-After hint from the API still takes
+// precedence over the returned delay.
+type RetryPolicy interface {
+	Backoff(attempt int, resp *http.Response, err error) (time.Duration, bool)
+}
+
+// NoJitterPolicy backs off exponentially without randomization, doubling
+// from Base up to Max. Zero fields default to 500ms and 8s.
+type NoJitterPolicy struct {
+	Base time.Duration
+	Max  time.Duration
+}
+
+// Backoff implements RetryPolicy.
+func (p NoJitterPolicy) Backoff(attempt int, _ *http.Response, _ error) (time.Duration, bool) {
+	return exponentialDelay(p.Base, p.Max, attempt), true
+}
+
+// FullJitterPolicy backs off by a random duration between zero and the
+// exponential delay, doubling from Base up to Max, which spreads out retries
+// from many clients. Zero fields default to 500ms and 8s.
+type FullJitterPolicy struct {
+	Base time.Duration
+	Max  time.Duration
+}
+
+// Backoff implements RetryPolicy.
+func (p FullJitterPolicy) Backoff(attempt int, _ *http.Response, _ error) (time.Duration, bool) {
+	return rand.N(exponentialDelay(p.Base, p.Max, attempt) + 1), true
+}
+
+// exponentialDelay doubles base for each attempt after the first, capped at
+// max. Zero values take the client defaults.
+func exponentialDelay(base, maxDelay time.Duration, attempt int) time.Duration {
+	if base <= 0 {
+		base = initialRetryDelay
+	}
+	if maxDelay <= 0 {
+		maxDelay = defaultMaxRetryDelay
+	}
+	d := base
+	for i := 1; i < attempt && d < maxDelay; i++ {
+		d *= 2
+	}
+	return min(d, maxDelay)
+}
+
+// failedResponse reconstructs the status code and headers of the response
+// behind err, or returns nil if err is not an API error response.
+func failedResponse(err error) *http.Response {
+	var pe *PaylioError
+	if !errors.As(err, &pe) || pe.HTTPStatus == 0 {
+		return nil
+	}
+	header := make(http.Header, len(pe.Headers))
+	for k, v := range pe.Headers {
+		header.Set(k, v)
+	}
+	return &http.Response{StatusCode: pe.HTTPStatus, Header: header}
+}
+
+// retryDelay returns how long to wait before the given retry attempt
+// (1-based) after err, and false if a configured RetryPolicy declines the
+// retry. A Retry-After hint on a RateLimitError or MaintenanceError takes
+// precedence over the backoff. The result is capped at hc.maxRetryDelay.
+func (hc *httpClient) retryDelay(attempt int, err error) (time.Duration, bool) {
+	d := backoffDelay(attempt)
+	if hc.retryPolicy != nil {
+		var ok bool
+		if d, ok = hc.retryPolicy.Backoff(attempt, failedResponse(err), err); !ok {
+			return 0, false
+		}
+	}
+	var raErr interface {
+		retryAfter(now time.Time) (time.Duration, bool)
+	}
+	if errors.As(err, &raErr) {
+		if ra, ok := raErr.retryAfter(hc.clock.Now()); ok {
+			d = ra
+		}
+	}
+	if d > hc.maxRetryDelay {
+		d = hc.maxRetryDelay
+	}
+	return d, true
+}
+
+// isRetryableError reports whether err is a connection failure, rate
+// limiting, or a transient server error, regardless of the request method.
+func isRetryableError(err error) bool {
+	var connErr *APIConnectionError
+	if errors.As(err, &connErr) {
+		return connErr.cause != nil && isTransientNetworkError(connErr.cause)
+	}
+	var pe *PaylioError
+	if !errors.As(err, &pe) {
+		return false
+	}
+	switch pe.HTTPStatus {
+	case http.StatusTooManyRequests,
+		http.StatusInternalServerError,
+		http.StatusBadGateway,
+		http.StatusServiceUnavailable,
+		http.StatusGatewayTimeout:
+		return true
+	default:
+		return false
+	}
+}
+
+// shouldRetry reports whether a failed request may be attempted again. The
+// request must be idempotent, either by method or because it carries an
+// Idempotency-Key header, and must have failed with a retryable error or one
+// of the client's retryable error codes.
+func (hc *httpClient) shouldRetry(method string, opts *requestOptions, err error) bool {
+	if !isIdempotent(method) && (opts == nil || opts.Header.Get(idempotencyKeyHeader) == "") {
+		return false
+	}
+	if isRetryableError(err) {
+		return true
+	}
+	var pe *PaylioError
+	return errors.As(err, &pe) && hc.retryableCodes[pe.Code]
+}
+
+// isTransientNetworkError reports whether a transport failure is likely to
+// succeed on retry: timeouts, temporary errors, refused or reset
+// connections, and connections closed mid-response. Permanent failures, such
+// as a server certificate that fails verification or an unresolvable host,
+// are not transient.
+func isTransientNetworkError(err error) bool {
+	var certErr *tls.CertificateVerificationError
+	var unknownAuthErr x509.UnknownAuthorityError
+	var hostnameErr x509.HostnameError
+	var invalidCertErr x509.CertificateInvalidError
+	if errors.As(err, &certErr) || errors.As(err, &unknownAuthErr) ||
+		errors.As(err, &hostnameErr) || errors.As(err, &invalidCertErr) {
+		return false
+	}
+	var dnsErr *net.DNSError
+	if errors.As(err, &dnsErr) {
+		return dnsErr.IsTimeout || dnsErr.IsTemporary
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return true
+	}
+	var tempErr interface{ Temporary() bool }
+	if errors.As(err, &tempErr) && tempErr.Temporary() {
+		return true
+	}
+	return errors.Is(err, syscall.ECONNREFUSED) || errors.Is(err, syscall.ECONNRESET) ||
+		errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF)
+}
+
+// outlastsDeadline reports whether waiting d from now would pass ctx's
+// deadline.
+func (hc *httpClient) outlastsDeadline(ctx context.Context, d time.Duration) bool {
+	deadline, ok := ctx.Deadline()
+	return ok && hc.clock.Now().Add(d).After(deadline)
+}
+
+// sleep waits for d on the client's clock, returning early with the context's
+// error if ctx is done first. If ctx's deadline would pass before d elapses,
+// it returns context.DeadlineExceeded immediately rather than waiting for a
+// wake-up that would come too late.
+func (hc *httpClient) sleep(ctx context.Context, d time.Duration) error {
+	if hc.outlastsDeadline(ctx, d) {
+		return context.DeadlineExceeded
+	}
+	select {
+	case <-hc.clock.After(d):
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,587 @@
+package paylio
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"reflect"
+	"sync/atomic"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestBackoffDelay(t *testing.T) {
+	tests := []struct {
+		attempt int
+		want    time.Duration
+	}{
+		{1, 500 * time.Millisecond},
+		{2, 1 * time.Second},
+		{3, 2 * time.Second},
+		{4, 4 * time.Second},
+		{5, 8 * time.Second},
+		{10, 8 * time.Second},
+	}
+	for _, tt := range tests {
+		if got := backoffDelay(tt.attempt); got != tt.want {
+			t.Errorf("backoffDelay(%d) = %v, want %v", tt.attempt, got, tt.want)
+		}
+	}
+}
+
+func TestSleepUsesInjectedClock(t *testing.T) {
+	fc := newFakeClock()
+	hc := newHTTPClient("sk_test", "http://localhost", 10*time.Second, &http.Client{})
+	hc.clock = fc
+
+	for attempt := 1; attempt <= 5; attempt++ {
+		if err := hc.sleep(context.Background(), backoffDelay(attempt)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	want := []time.Duration{
+		500 * time.Millisecond,
+		1 * time.Second,
+		2 * time.Second,
+		4 * time.Second,
+		8 * time.Second,
+	}
+	got := fc.Sleeps()
+	if len(got) != len(want) {
+		t.Fatalf("sleeps = %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("sleep[%d] = %v, want %v", i, got[i], want[i])
+		}
+	}
+}
+
+// blockingClock is a clock whose After never fires.
+type blockingClock struct{ fakeClock }
+
+func (*blockingClock) After(time.Duration) <-chan time.Time { return nil }
+
+func TestSleepReturnsOnContextCancel(t *testing.T) {
+	hc := newHTTPClient("sk_test", "http://localhost", 10*time.Second, &http.Client{})
+	hc.clock = &blockingClock{}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	err := hc.sleep(ctx, time.Hour)
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("err = %v, want context.Canceled", err)
+	}
+}
+
+// sequenceServer replies with the given statuses in order, repeating the last
+// one, and counts hits. Each response may carry extra headers.
+type sequenceResponse struct {
+	status int
+	header map[string]string
+	body   string
+}
+
+func newSequenceServer(t *testing.T, responses ...sequenceResponse) (*httptest.Server, *atomic.Int32) {
+	t.Helper()
+	var hits atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		i := int(hits.Add(1)) - 1
+		if i >= len(responses) {
+			i = len(responses) - 1
+		}
+		resp := responses[i]
+		for k, v := range resp.header {
+			w.Header().Set(k, v)
+		}
+		w.WriteHeader(resp.status)
+		body := resp.body
+		if body == "" {
+			body = `{"ok":true}`
+		}
+		_, _ = w.Write([]byte(body))
+	}))
+	return srv, &hits
+}
+
+func newRetryingHTTPClient(baseURL string, maxRetries int) (*httpClient, *fakeClock) {
+	hc := newHTTPClient("sk_test", baseURL, 5*time.Second, &http.Client{})
+	fc := newFakeClock()
+	hc.clock = fc
+	hc.maxRetries = maxRetries
+	return hc, fc
+}
+
+func TestRetryHonorsRetryAfterOn429(t *testing.T) {
+	srv, hits := newSequenceServer(t,
+		sequenceResponse{status: 429, header: map[string]string{"Retry-After": "2"}, body: `{"error":"slow down"}`},
+		sequenceResponse{status: 200},
+	)
+	defer srv.Close()
+
+	hc, fc := newRetryingHTTPClient(srv.URL, 3)
+	data, err := hc.request(context.Background(), "GET", "/sub", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if data["ok"] != true {
+		t.Errorf("data = %v", data)
+	}
+	if n := hits.Load(); n != 2 {
+		t.Errorf("hits = %d, want 2", n)
+	}
+	if got := fc.Sleeps(); len(got) != 1 || got[0] != 2*time.Second {
+		t.Errorf("sleeps = %v, want [2s]", got)
+	}
+}
+
+func TestRetryAfterHTTPDateUsesClientClock(t *testing.T) {
+	fc := newFakeClock()
+	srv, _ := newSequenceServer(t,
+		sequenceResponse{status: 429, header: map[string]string{"Retry-After": fc.Now().Add(3 * time.Second).Format(http.TimeFormat)}},
+		sequenceResponse{status: 200},
+	)
+	defer srv.Close()
+
+	hc, _ := newRetryingHTTPClient(srv.URL, 1)
+	hc.clock = fc
+	if _, err := hc.request(context.Background(), "GET", "/sub", nil); err != nil {
+		t.Fatal(err)
+	}
+	if got := fc.Sleeps(); len(got) != 1 || got[0] != 3*time.Second {
+		t.Errorf("sleeps = %v, want [3s]", got)
+	}
+}
+
+func TestRetryHonorsRetryAfterOnMaintenance(t *testing.T) {
+	srv, hits := newSequenceServer(t,
+		sequenceResponse{status: 503, header: map[string]string{"Retry-After": "5"}, body: `{"error":{"code":"maintenance","message":"Scheduled maintenance"}}`},
+		sequenceResponse{status: 200},
+	)
+	defer srv.Close()
+
+	hc, fc := newRetryingHTTPClient(srv.URL, 3)
+	hc.maxRetryDelay = time.Minute
+	if _, err := hc.request(context.Background(), "GET", "/sub", nil); err != nil {
+		t.Fatal(err)
+	}
+	if n := hits.Load(); n != 2 {
+		t.Errorf("hits = %d, want 2", n)
+	}
+	if got := fc.Sleeps(); len(got) != 1 || got[0] != 5*time.Second {
+		t.Errorf("sleeps = %v, want [5s]", got)
+	}
+}
+
+func TestRetryAfterCappedAtMaxRetryDelay(t *testing.T) {
+	srv, _ := newSequenceServer(t,
+		sequenceResponse{status: 429, header: map[string]string{"Retry-After": "120"}},
+		sequenceResponse{status: 200},
+	)
+	defer srv.Close()
+
+	hc, fc := newRetryingHTTPClient(srv.URL, 1)
+	hc.maxRetryDelay = 5 * time.Second
+	if _, err := hc.request(context.Background(), "GET", "/sub", nil); err != nil {
+		t.Fatal(err)
+	}
+	if got := fc.Sleeps(); len(got) != 1 || got[0] != 5*time.Second {
+		t.Errorf("sleeps = %v, want [5s]", got)
+	}
+}
+
+func TestRetryExponentialBackoffOnServerErrors(t *testing.T) {
+	srv, hits := newSequenceServer(t,
+		sequenceResponse{status: 503},
+		sequenceResponse{status: 502},
+		sequenceResponse{status: 200},
+	)
+	defer srv.Close()
+
+	hc, fc := newRetryingHTTPClient(srv.URL, 3)
+	if _, err := hc.request(context.Background(), "GET", "/sub", nil); err != nil {
+		t.Fatal(err)
+	}
+	if n := hits.Load(); n != 3 {
+		t.Errorf("hits = %d, want 3", n)
+	}
+	want := []time.Duration{500 * time.Millisecond, time.Second}
+	got := fc.Sleeps()
+	if len(got) != len(want) || got[0] != want[0] || got[1] != want[1] {
+		t.Errorf("sleeps = %v, want %v", got, want)
+	}
+}
+
+func TestRetryGivesUpAfterMaxRetries(t *testing.T) {
+	srv, hits := newSequenceServer(t, sequenceResponse{status: 500, body: `{"error":"boom"}`})
+	defer srv.Close()
+
+	hc, _ := newRetryingHTTPClient(srv.URL, 2)
+	_, err := hc.request(context.Background(), "GET", "/sub", nil)
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) {
+		t.Fatalf("expected *APIError, got %T: %v", err, err)
+	}
+	if n := hits.Load(); n != 3 {
+		t.Errorf("hits = %d, want 3", n)
+	}
+}
+
+func TestRetryDisabledByDefault(t *testing.T) {
+	srv, hits := newSequenceServer(t, sequenceResponse{status: 503}, sequenceResponse{status: 200})
+	defer srv.Close()
+
+	hc := newHTTPClient("sk_test", srv.URL, 5*time.Second, &http.Client{})
+	if _, err := hc.request(context.Background(), "GET", "/sub", nil); err == nil {
+		t.Fatal("expected error without retries")
+	}
+	if n := hits.Load(); n != 1 {
+		t.Errorf("hits = %d, want 1", n)
+	}
+}
+
+func TestRetrySkipsNonIdempotentAndClientErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		method string
+		status int
+	}{
+		{"POST 503", "POST", 503},
+		{"GET 400", "GET", 400},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv, hits := newSequenceServer(t, sequenceResponse{status: tt.status}, sequenceResponse{status: 200})
+			defer srv.Close()
+
+			hc, _ := newRetryingHTTPClient(srv.URL, 3)
+			if _, err := hc.request(context.Background(), tt.method, "/sub", nil); err == nil {
+				t.Fatal("expected error")
+			}
+			if n := hits.Load(); n != 1 {
+				t.Errorf("hits = %d, want 1", n)
+			}
+		})
+	}
+}
+
+func TestRetryOnConnectionError(t *testing.T) {
+	hc, fc := newRetryingHTTPClient("http://127.0.0.1:1", 2)
+	_, err := hc.request(context.Background(), "GET", "/sub", nil)
+	var connErr *APIConnectionError
+	if !errors.As(err, &connErr) {
+		t.Fatalf("expected *APIConnectionError, got %T: %v", err, err)
+	}
+	if n := len(fc.Sleeps()); n != 2 {
+		t.Errorf("sleeps = %d, want 2", n)
+	}
+}
+
+func TestRetryReturnsLastErrorWhenContextCanceledDuringBackoff(t *testing.T) {
+	srv, hits := newSequenceServer(t, sequenceResponse{status: 503, body: `{"error":"down"}`})
+	defer srv.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	hc, _ := newRetryingHTTPClient(srv.URL, 3)
+	hc.clock = &cancelingClock{cancel: cancel}
+	_, err := hc.request(ctx, "GET", "/sub", nil)
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) || apiErr.Message != "down" {
+		t.Fatalf("err = %v, want the 503 APIError", err)
+	}
+	if n := hits.Load(); n != 1 {
+		t.Errorf("hits = %d, want 1", n)
+	}
+}
+
+func TestShouldRetryPlainError(t *testing.T) {
+	hc := newHTTPClient("sk_test", "http://localhost", 0, &http.Client{})
+	if hc.shouldRetry("GET", nil, errors.New("boom")) {
+		t.Error("plain errors should not be retried")
+	}
+}
+
+func TestRetryAbandonedWhenBackoffExceedsDeadline(t *testing.T) {
+	srv, hits := newSequenceServer(t, sequenceResponse{status: 503, body: `{"error":"down"}`})
+	defer srv.Close()
+
+	hc, fc := newRetryingHTTPClient(srv.URL, 5)
+	fc.now = time.Now()
+	logger := &captureLogger{}
+	hc.logger = logger
+	var backoffs []time.Duration
+	hc.onBackoff = func(wait time.Duration, _ int) { backoffs = append(backoffs, wait) }
+	ctx, cancel := context.WithDeadline(context.Background(), fc.now.Add(5*time.Second))
+	defer cancel()
+
+	_, err := hc.request(ctx, "GET", "/sub", nil)
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) || apiErr.Message != "down" {
+		t.Fatalf("err = %v, want the 503 APIError", err)
+	}
+	// Backoffs of 500ms, 1s and 2s fit within the deadline; the next 4s
+	// backoff would overrun it, so the loop stops without sleeping, logging,
+	// or reporting it.
+	want := []time.Duration{500 * time.Millisecond, time.Second, 2 * time.Second}
+	got := fc.Sleeps()
+	if len(got) != len(want) || got[0] != want[0] || got[1] != want[1] || got[2] != want[2] {
+		t.Errorf("sleeps = %v, want %v", got, want)
+	}
+	if !reflect.DeepEqual(backoffs, want) {
+		t.Errorf("backoff callbacks = %v, want %v", backoffs, want)
+	}
+	if n := len(logger.Entries()); n != len(want) {
+		t.Errorf("logged %d retries, want %d", n, len(want))
+	}
+	if n := hits.Load(); n != 4 {
+		t.Errorf("hits = %d, want 4", n)
+	}
+}
+
+func TestSleepBeyondDeadlineReturnsImmediately(t *testing.T) {
+	hc := newHTTPClient("sk_test", "http://localhost", time.Second, &http.Client{})
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	if err := hc.sleep(ctx, time.Hour); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("err = %v, want context.DeadlineExceeded", err)
+	}
+}
+
+func TestWithRateLimitCallbackFiresBeforeEachBackoff(t *testing.T) {
+	srv, _ := newSequenceServer(t,
+		sequenceResponse{status: 429, header: map[string]string{"Retry-After": "3"}, body: `{"error":"slow down"}`},
+		sequenceResponse{status: 503},
+		sequenceResponse{status: 200, body: `{"id":"sub_1"}`},
+	)
+	defer srv.Close()
+
+	type call struct {
+		wait    time.Duration
+		attempt int
+	}
+	var calls []call
+	client, err := NewClient("sk_test", WithBaseURL(srv.URL), WithMaxRetries(3), withClock(newFakeClock()),
+		WithRateLimitCallback(func(wait time.Duration, attempt int) {
+			calls = append(calls, call{wait, attempt})
+		}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.Subscription.Retrieve(context.Background(), "user_1"); err != nil {
+		t.Fatal(err)
+	}
+	want := []call{{3 * time.Second, 1}, {time.Second, 2}}
+	if len(calls) != len(want) || calls[0] != want[0] || calls[1] != want[1] {
+		t.Errorf("calls = %v, want %v", calls, want)
+	}
+}
+
+// timeoutError is a net.Error that reports a timeout.
+type timeoutError struct{}
+
+func (timeoutError) Error() string   { return "i/o timeout" }
+func (timeoutError) Timeout() bool   { return true }
+func (timeoutError) Temporary() bool { return false }
+
+// temporaryError reports itself as temporary but not as a timeout.
+type temporaryError struct{}
+
+func (temporaryError) Error() string   { return "resource temporarily unavailable" }
+func (temporaryError) Temporary() bool { return true }
+
+func TestRetryClassifiesTransportErrors(t *testing.T) {
+	tests := []struct {
+		name      string
+		err       error
+		wantCalls int
+	}{
+		{"permanent certificate error", &tls.CertificateVerificationError{Err: x509.UnknownAuthorityError{}}, 1},
+		{"timeout", timeoutError{}, 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calls := 0
+			hc, _ := newRetryingHTTPClient("http://example.invalid", 2)
+			hc.client = &http.Client{Transport: roundTripFunc(func(*http.Request) (*http.Response, error) {
+				calls++
+				return nil, tt.err
+			})}
+			_, err := hc.request(context.Background(), "GET", "/sub", nil)
+			var connErr *APIConnectionError
+			if !errors.As(err, &connErr) {
+				t.Fatalf("expected *APIConnectionError, got %T: %v", err, err)
+			}
+			if !errors.Is(err, tt.err) {
+				t.Errorf("error should wrap the transport error %v", tt.err)
+			}
+			if calls != tt.wantCalls {
+				t.Errorf("calls = %d, want %d", calls, tt.wantCalls)
+			}
+		})
+	}
+}
+
+func TestIsTransientNetworkError(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"certificate verification", &tls.CertificateVerificationError{Err: errors.New("bad cert")}, false},
+		{"unknown authority", x509.UnknownAuthorityError{}, false},
+		{"hostname mismatch", x509.HostnameError{Certificate: &x509.Certificate{}, Host: "example.com"}, false},
+		{"invalid certificate", x509.CertificateInvalidError{Reason: x509.Expired}, false},
+		{"dns not found", &net.DNSError{Err: "no such host", IsNotFound: true}, false},
+		{"dns timeout", &net.DNSError{Err: "timeout", IsTimeout: true}, true},
+		{"dns temporary", &net.DNSError{Err: "server misbehaving", IsTemporary: true}, true},
+		{"timeout", timeoutError{}, true},
+		{"client timeout", os.ErrDeadlineExceeded, true},
+		{"temporary", temporaryError{}, true},
+		{"connection refused", &net.OpError{Op: "dial", Err: &os.SyscallError{Syscall: "connect", Err: syscall.ECONNREFUSED}}, true},
+		{"connection reset", &net.OpError{Op: "read", Err: &os.SyscallError{Syscall: "read", Err: syscall.ECONNRESET}}, true},
+		{"server closed connection", io.EOF, true},
+		{"unknown", errors.New("unsupported protocol scheme"), false},
+	}
+	for _, tt := range tests {
+		if got := isTransientNetworkError(tt.err); got != tt.want {
+			t.Errorf("%s: isTransientNetworkError() = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestRetryPolicyDelays(t *testing.T) {
+	noJitter := NoJitterPolicy{Base: 100 * time.Millisecond, Max: time.Second}
+	fullJitter := FullJitterPolicy{Base: 100 * time.Millisecond, Max: time.Second}
+	ceilings := []time.Duration{
+		100 * time.Millisecond,
+		200 * time.Millisecond,
+		400 * time.Millisecond,
+		800 * time.Millisecond,
+		time.Second,
+		time.Second,
+	}
+	for i, ceiling := range ceilings {
+		attempt := i + 1
+		if d, ok := noJitter.Backoff(attempt, nil, nil); !ok || d != ceiling {
+			t.Errorf("NoJitterPolicy.Backoff(%d) = %v, %v; want %v", attempt, d, ok, ceiling)
+		}
+		for n := 0; n < 50; n++ {
+			if d, ok := fullJitter.Backoff(attempt, nil, nil); !ok || d < 0 || d > ceiling {
+				t.Fatalf("FullJitterPolicy.Backoff(%d) = %v, %v; want within [0, %v]", attempt, d, ok, ceiling)
+			}
+		}
+	}
+	if d, _ := (NoJitterPolicy{}).Backoff(3, nil, nil); d != 2*time.Second {
+		t.Errorf("zero NoJitterPolicy.Backoff(3) = %v, want 2s", d)
+	}
+}
+
+// recordingPolicy returns fixed delays and records what it was called with.
+type recordingPolicy struct {
+	delays   []time.Duration
+	statuses []int
+}
+
+func (p *recordingPolicy) Backoff(attempt int, resp *http.Response, _ error) (time.Duration, bool) {
+	status := 0
+	if resp != nil {
+		status = resp.StatusCode
+	}
+	p.statuses = append(p.statuses, status)
+	if attempt > len(p.delays) {
+		return 0, false
+	}
+	return p.delays[attempt-1], true
+}
+
+func TestWithRetryPolicy(t *testing.T) {
+	srv, hits := newSequenceServer(t,
+		sequenceResponse{status: 503, body: `{"error":"unavailable"}`},
+		sequenceResponse{status: 502, body: `{"error":"bad gateway"}`},
+		sequenceResponse{status: 500, body: `{"error":"boom"}`},
+		sequenceResponse{status: 200},
+	)
+	defer srv.Close()
+
+	policy := &recordingPolicy{delays: []time.Duration{3 * time.Second, 20 * time.Second}}
+	fc := newFakeClock()
+	client, err := NewClient("sk_test", WithBaseURL(srv.URL), withClock(fc), WithMaxRetries(5), WithRetryPolicy(policy))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = client.Subscription.Retrieve(context.Background(), "user_1")
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) || apiErr.HTTPStatus != 500 {
+		t.Fatalf("err = %v, want the 500 after the policy gives up", err)
+	}
+	if n := hits.Load(); n != 3 {
+		t.Errorf("hits = %d, want 3", n)
+	}
+	if got := fc.Sleeps(); len(got) != 2 || got[0] != 3*time.Second || got[1] != defaultMaxRetryDelay {
+		t.Errorf("sleeps = %v, want [3s 8s]", got)
+	}
+	if len(policy.statuses) != 3 || policy.statuses[0] != 503 || policy.statuses[2] != 500 {
+		t.Errorf("policy saw statuses %v", policy.statuses)
+	}
+}
+
+func TestFailedResponseForConnectionError(t *testing.T) {
+	if resp := failedResponse(NewAPIConnectionError(ErrorParams{Message: "refused"})); resp != nil {
+		t.Errorf("failedResponse = %+v, want nil", resp)
+	}
+	resp := failedResponse(NewRateLimitError(ErrorParams{HTTPStatus: 429, Headers: map[string]string{"Retry-After": "1"}}))
+	if resp.StatusCode != 429 || resp.Header.Get("Retry-After") != "1" {
+		t.Errorf("failedResponse = %+v", resp)
+	}
+}
+
+func TestWithRetryableErrorCodes(t *testing.T) {
+	tests := []struct {
+		name     string
+		code     string
+		wantHits int32
+	}{
+		{"matching code", "provider_temporarily_unavailable", 2},
+		{"other code", "invalid_param", 1},
+	}
+	for _, tt := range tests {
+		srv, hits := newSequenceServer(t,
+			sequenceResponse{status: 400, body: `{"error":{"code":"` + tt.code + `","message":"try later"}}`},
+			sequenceResponse{status: 200},
+		)
+		client, err := NewClient("sk_test", WithBaseURL(srv.URL), withClock(newFakeClock()), WithMaxRetries(2),
+			WithRetryableErrorCodes("provider_temporarily_unavailable"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = client.Subscription.Retrieve(context.Background(), "user_1")
+		srv.Close()
+		if n := hits.Load(); n != tt.wantHits {
+			t.Errorf("%s: hits = %d, want %d", tt.name, n, tt.wantHits)
+		}
+		if (err == nil) != (tt.wantHits == 2) {
+			t.Errorf("%s: err = %v", tt.name, err)
+		}
+	}
+}
+
+func TestRetryableErrorCodesRequireIdempotentMethod(t *testing.T) {
+	hc := newHTTPClient("sk_test", "http://localhost", DefaultTimeout, http.DefaultClient)
+	hc.retryableCodes = map[string]bool{"provider_temporarily_unavailable": true}
+	err := NewInvalidRequestError(ErrorParams{HTTPStatus: 400, Code: "provider_temporarily_unavailable"})
+	if !hc.shouldRetry("GET", nil, err) {
+		t.Error("GET with a retryable code should be retried")
+	}
+	if hc.shouldRetry("POST", nil, err) {
+		t.Error("POST should not be retried")
+	}
+}
@@ -0,0 +1,119 @@
+package paylio
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// maxWatchReconnects bounds consecutive reconnection attempts after transient
+// stream failures. The count resets whenever an event is received.
+const maxWatchReconnects = 5
+
+// maxSSELineSize bounds a single line of an event stream.
+const maxSSELineSize = 1 << 20
+
+// watch consumes the event stream at path, reconnecting with backoff after
+// transient drops, until the stream ends, ctx is done, or a permanent error
+// occurs. It closes both channels on return.
+func (hc *httpClient) watch(ctx context.Context, path string, events chan<- SubscriptionEvent, errs chan<- error) {
+	defer close(errs)
+	defer close(events)
+
+	ctx = orBackground(ctx)
+	lastEventID := ""
+	failures := 0
+	for {
+		header := http.Header{"Accept": {"text/event-stream"}}
+		if lastEventID != "" {
+			header.Set("Last-Event-ID", lastEventID)
+		}
+		resp, err := hc.stream(ctx, "GET", path, &requestOptions{Header: header})
+		if err == nil {
+			var received bool
+			lastEventID, received, err = readEvents(ctx, resp.Body, lastEventID, events)
+			resp.Body.Close()
+			if err == nil {
+				return
+			}
+			if received {
+				failures = 0
+			}
+		}
+		if ctx.Err() != nil {
+			return
+		}
+		var connErr *APIConnectionError
+		if !errors.As(err, &connErr) || failures >= maxWatchReconnects {
+			errs <- err
+			return
+		}
+		failures++
+		if hc.sleep(ctx, backoffDelay(failures)) != nil {
+			return
+		}
+	}
+}
+
+// readEvents parses server-sent events from r and delivers them on events.
+// It returns the ID of the last event seen, whether any event was delivered,
+// and a nil error when the stream ends cleanly or ctx is done. Read failures
+// are reported as APIConnectionError so the caller can reconnect.
+func readEvents(ctx context.Context, r io.Reader, lastEventID string, events chan<- SubscriptionEvent) (string, bool, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 4096), maxSSELineSize)
+
+	received := false
+	var data []string
+	eventType, eventID := "", ""
+	for scanner.Scan() {
+		line := scanner.Text()
+		if line == "" {
+			if len(data) == 0 {
+				continue
+			}
+			var ev SubscriptionEvent
+			if err := json.Unmarshal([]byte(strings.Join(data, "\n")), &ev); err != nil {
+				return lastEventID, received, NewAPIError(ErrorParams{Message: "Invalid JSON in event data"})
+			}
+			if ev.Type == "" {
+				ev.Type = eventType
+			}
+			if ev.ID == "" {
+				ev.ID = eventID
+			}
+			if ev.ID != "" {
+				lastEventID = ev.ID
+			}
+			data, eventType, eventID = nil, "", ""
+
+			select {
+			case events <- ev:
+				received = true
+			case <-ctx.Done():
+				return lastEventID, received, nil
+			}
+			continue
+		}
+
+		field, value, _ := strings.Cut(line, ":")
+		value = strings.TrimPrefix(value, " ")
+		switch field {
+		case "data":
+			data = append(data, value)
+		case "event":
+			eventType = value
+		case "id":
+			eventID = value
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return lastEventID, received, NewAPIConnectionError(ErrorParams{Message: fmt.Sprintf("event stream interrupted: %v", err)})
+	}
+	return lastEventID, received, nil
+}
@@ -0,0 +1,254 @@
+package paylio
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func newTestWatchService(handler http.HandlerFunc) (*SubscriptionService, *httptest.Server) {
+	svc, srv := newTestService(handler)
+	svc.http.clock = newFakeClock()
+	return svc, srv
+}
+
+// collectWatch drains both channels returned by Watch.
+func collectWatch(t *testing.T, events <-chan SubscriptionEvent, errs <-chan error) ([]SubscriptionEvent, error) {
+	t.Helper()
+	var got []SubscriptionEvent
+	timeout := time.After(5 * time.Second)
+	for {
+		select {
+		case ev, ok := <-events:
+			if !ok {
+				return got, <-errs
+			}
+			got = append(got, ev)
+		case <-timeout:
+			t.Fatal("timed out waiting for events")
+		}
+	}
+}
+
+func TestWatchReceivesEvents(t *testing.T) {
+	svc, srv := newTestWatchService(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/subscription/user_1/events" {
+			t.Errorf("Path = %q", r.URL.Path)
+		}
+		if got := r.Header.Get("Accept"); got != "text/event-stream" {
+			t.Errorf("Accept = %q", got)
+		}
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.WriteHeader(200)
+		fmt.Fprint(w, ": keepalive\n\n")
+		fmt.Fprint(w, "id: evt_1\nevent: subscription.updated\ndata: {\"subscription\":{\"id\":\"sub_1\",\"status\":\"active\"}}\n\n")
+		fmt.Fprint(w, "data: {\"id\":\"evt_2\",\"type\":\"subscription.canceled\",\n")
+		fmt.Fprint(w, "data: \"subscription\":{\"id\":\"sub_1\",\"status\":\"canceled\"}}\n\n")
+	})
+	defer srv.Close()
+
+	events, errs := svc.Watch(context.Background(), "user_1")
+	got, err := collectWatch(t, events, errs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 2 {
+		t.Fatalf("events = %d, want 2", len(got))
+	}
+	if got[0].ID != "evt_1" || got[0].Type != "subscription.updated" || got[0].Subscription.Status != "active" {
+		t.Errorf("event[0] = %+v", got[0])
+	}
+	if got[1].ID != "evt_2" || got[1].Type != "subscription.canceled" || got[1].Subscription.Status != "canceled" {
+		t.Errorf("event[1] = %+v", got[1])
+	}
+}
+
+func TestWatchReconnectsAfterTransientDrop(t *testing.T) {
+	var conns atomic.Int32
+	svc, srv := newTestWatchService(func(w http.ResponseWriter, r *http.Request) {
+		if conns.Add(1) == 1 {
+			// Promise more bytes than are written so the client sees the
+			// connection drop mid-stream.
+			w.Header().Set("Content-Length", "4096")
+			w.WriteHeader(200)
+			fmt.Fprint(w, "id: evt_1\ndata: {\"type\":\"subscription.updated\"}\n\n")
+			return
+		}
+		if got := r.Header.Get("Last-Event-ID"); got != "evt_1" {
+			t.Errorf("Last-Event-ID = %q", got)
+		}
+		w.WriteHeader(200)
+		fmt.Fprint(w, "id: evt_2\ndata: {\"type\":\"subscription.renewed\"}\n\n")
+	})
+	defer srv.Close()
+
+	events, errs := svc.Watch(context.Background(), "user_1")
+	got, err := collectWatch(t, events, errs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 2 || got[0].ID != "evt_1" || got[1].ID != "evt_2" {
+		t.Fatalf("events = %+v", got)
+	}
+	if n := conns.Load(); n != 2 {
+		t.Errorf("connections = %d, want 2", n)
+	}
+	if sleeps := svc.http.clock.(*fakeClock).Sleeps(); len(sleeps) != 1 || sleeps[0] != backoffDelay(1) {
+		t.Errorf("sleeps = %v", sleeps)
+	}
+}
+
+func TestWatchPermanentErrorIsReported(t *testing.T) {
+	svc, srv := newTestWatchService(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(404)
+		_, _ = w.Write([]byte(`{"error":{"code":"not_found","message":"no subscription"}}`))
+	})
+	defer srv.Close()
+
+	events, errs := svc.Watch(context.Background(), "user_1")
+	got, err := collectWatch(t, events, errs)
+	if len(got) != 0 {
+		t.Errorf("events = %+v", got)
+	}
+	var nfErr *NotFoundError
+	if !errors.As(err, &nfErr) {
+		t.Fatalf("expected *NotFoundError, got %T: %v", err, err)
+	}
+}
+
+func TestWatchInvalidEventData(t *testing.T) {
+	svc, srv := newTestWatchService(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(200)
+		fmt.Fprint(w, "data: not json\n\n")
+	})
+	defer srv.Close()
+
+	events, errs := svc.Watch(context.Background(), "user_1")
+	_, err := collectWatch(t, events, errs)
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) {
+		t.Fatalf("expected *APIError, got %T: %v", err, err)
+	}
+}
+
+func TestWatchGivesUpAfterMaxReconnects(t *testing.T) {
+	hc := newHTTPClient("sk_test", "http://127.0.0.1:1", 5*time.Second, &http.Client{})
+	fc := newFakeClock()
+	hc.clock = fc
+	svc := newSubscriptionService(hc)
+
+	events, errs := svc.Watch(context.Background(), "user_1")
+	_, err := collectWatch(t, events, errs)
+	var connErr *APIConnectionError
+	if !errors.As(err, &connErr) {
+		t.Fatalf("expected *APIConnectionError, got %T: %v", err, err)
+	}
+	if n := len(fc.Sleeps()); n != maxWatchReconnects {
+		t.Errorf("reconnect sleeps = %d, want %d", n, maxWatchReconnects)
+	}
+}
+
+// cancelingClock cancels a context instead of letting time pass.
+type cancelingClock struct {
+	fakeClock
+	cancel context.CancelFunc
+}
+
+func (c *cancelingClock) After(time.Duration) <-chan time.Time {
+	c.cancel()
+	return nil
+}
+
+func TestWatchContextCanceledDuringBackoff(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	hc := newHTTPClient("sk_test", "http://127.0.0.1:1", 5*time.Second, &http.Client{})
+	hc.clock = &cancelingClock{cancel: cancel}
+	svc := newSubscriptionService(hc)
+
+	events, errs := svc.Watch(ctx, "user_1")
+	_, err := collectWatch(t, events, errs)
+	if err != nil {
+		t.Errorf("err = %v, want nil after cancellation", err)
+	}
+}
+
+func TestWatchContextCanceledMidStream(t *testing.T) {
+	svc, srv := newTestWatchService(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(200)
+		fmt.Fprint(w, "data: {\"id\":\"evt_1\"}\n\n")
+		w.(http.Flusher).Flush()
+		<-r.Context().Done()
+	})
+	defer srv.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	events, errs := svc.Watch(ctx, "user_1")
+	ev := <-events
+	if ev.ID != "evt_1" {
+		t.Errorf("ID = %q", ev.ID)
+	}
+	cancel()
+	got, err := collectWatch(t, events, errs)
+	if len(got) != 0 || err != nil {
+		t.Errorf("after cancel: events = %+v, err = %v", got, err)
+	}
+}
+
+func TestWatchEmptyUserIDReturnsError(t *testing.T) {
+	svc, srv := newTestWatchService(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(200)
+	})
+	defer srv.Close()
+
+	events, errs := svc.Watch(context.Background(), " ")
+	_, err := collectWatch(t, events, errs)
+	if err == nil || err.Error() != "userID is required" {
+		t.Errorf("err = %v", err)
+	}
+}
+
+func TestWatchClosedClient(t *testing.T) {
+	svc, srv := newTestWatchService(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(200)
+	})
+	defer srv.Close()
+	svc.http.close()
+
+	events, errs := svc.Watch(context.Background(), "user_1")
+	_, err := collectWatch(t, events, errs)
+	if !errors.Is(err, ErrClientClosed) {
+		t.Errorf("err = %v, want ErrClientClosed", err)
+	}
+}
+
+func TestStreamInvalidURL(t *testing.T) {
+	hc := newHTTPClient("sk_test", "http://localhost", 10*time.Second, &http.Client{})
+	hc.baseURL = string([]byte{0x7f})
+	_, err := hc.stream(context.Background(), "GET", "/events", nil)
+	var connErr *APIConnectionError
+	if !errors.As(err, &connErr) {
+		t.Fatalf("expected *APIConnectionError, got %T: %v", err, err)
+	}
+}
+
+func TestReadEventsStopsWhenContextDone(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	events := make(chan SubscriptionEvent)
+	lastID, received, err := readEvents(ctx, strings.NewReader("id: evt_9\ndata: {}\n\n"), "", events)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if received {
+		t.Error("no event should have been delivered")
+	}
+	if lastID != "evt_9" {
+		t.Errorf("lastID = %q", lastID)
+	}
+}
@@ -0,0 +1,67 @@
+package paylio
+
+import (
+	"fmt"
+	"strings"
+)
+
+// requiredFielder is implemented by resources that can report which of the
+// fields every valid response carries are missing.
+type requiredFielder interface {
+	missingFields() []string
+}
+
+// decodeResource converts a response into a T. When strict responses are
+// enabled and T reports required fields, a response missing any of them is
+// rejected with an APIError.
+func decodeResource[T any](hc *httpClient, data map[string]any) (*T, error) {
+	v, err := unmarshalTo[T](data)
+	if err != nil {
+		return nil, err
+	}
+	if rf, ok := any(v).(requiredFielder); ok && hc.strict {
+		if missing := rf.missingFields(); len(missing) > 0 {
+			return nil, NewAPIError(ErrorParams{
+				Message:  fmt.Sprintf("Response is missing required fields: %s", strings.Join(missing, ", ")),
+				JSONBody: data,
+			})
+		}
+	}
+	return v, nil
+}
+
+// missingRequired returns the names of the fields whose values are empty.
+// fields alternates field names and values.
+func missingRequired(fields ...string) []string {
+	var missing []string
+	for i := 0; i < len(fields); i += 2 {
+		if fields[i+1] == "" {
+			missing = append(missing, fields[i])
+		}
+	}
+	return missing
+}
+
+func (s *Subscription) missingFields() []string {
+	return missingRequired("id", s.ID, "status", string(s.Status))
+}
+
+func (c *SubscriptionCancel) missingFields() []string {
+	return missingRequired("id", c.ID)
+}
+
+func (r *Refund) missingFields() []string {
+	return missingRequired("id", r.ID, "status", r.Status)
+}
+
+func (i *Invoice) missingFields() []string {
+	return missingRequired("id", i.ID, "status", i.Status)
+}
+
+func (d *Dispute) missingFields() []string {
+	return missingRequired("id", d.ID, "status", d.Status)
+}
+
+func (u *UsageRecord) missingFields() []string {
+	return missingRequired("id", u.ID)
+}
@@ -0,0 +1,97 @@
+package paylio
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func newStrictTestClient(t *testing.T, body string, opts ...Option) *Client {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(200)
+		_, _ = w.Write([]byte(body))
+	}))
+	t.Cleanup(srv.Close)
+	client, err := NewClient("sk_test", append([]Option{WithBaseURL(srv.URL)}, opts...)...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return client
+}
+
+func TestStrictResponsesRejectsMissingID(t *testing.T) {
+	client := newStrictTestClient(t, `{"status":"active"}`, WithStrictResponses())
+	_, err := client.Subscription.Retrieve(context.Background(), "user_1")
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) {
+		t.Fatalf("expected *APIError, got %T: %v", err, err)
+	}
+	if apiErr.Message != "Response is missing required fields: id" {
+		t.Errorf("Message = %q", apiErr.Message)
+	}
+	if apiErr.JSONBody["status"] != "active" {
+		t.Errorf("JSONBody = %v", apiErr.JSONBody)
+	}
+}
+
+func TestStrictResponsesListsAllMissingFields(t *testing.T) {
+	client := newStrictTestClient(t, `{}`, WithStrictResponses())
+	_, err := client.Refunds.Retrieve(context.Background(), "re_1")
+	if err == nil || err.Error() != "Response is missing required fields: id, status" {
+		t.Errorf("err = %v", err)
+	}
+}
+
+func TestStrictResponsesAcceptsCompleteResponse(t *testing.T) {
+	client := newStrictTestClient(t, `{"id":"sub_1","status":"active"}`, WithStrictResponses())
+	sub, err := client.Subscription.Retrieve(context.Background(), "user_1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sub.ID != "sub_1" {
+		t.Errorf("ID = %q", sub.ID)
+	}
+}
+
+func TestLenientResponsesAllowMissingID(t *testing.T) {
+	client := newStrictTestClient(t, `{"status":"active"}`)
+	sub, err := client.Subscription.Retrieve(context.Background(), "user_1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sub.ID != "" || sub.Status != "active" {
+		t.Errorf("sub = %+v", sub)
+	}
+}
+
+func TestMissingFieldsPerResource(t *testing.T) {
+	tests := []struct {
+		name string
+		rf   requiredFielder
+		want int
+	}{
+		{"Subscription", &Subscription{}, 2},
+		{"SubscriptionCancel", &SubscriptionCancel{}, 1},
+		{"Refund", &Refund{}, 2},
+		{"Invoice", &Invoice{}, 2},
+		{"Dispute", &Dispute{}, 2},
+		{"UsageRecord", &UsageRecord{}, 1},
+		{"complete", &Invoice{ID: "in_1", Status: "paid"}, 0},
+	}
+	for _, tt := range tests {
+		if got := tt.rf.missingFields(); len(got) != tt.want {
+			t.Errorf("%s: missingFields() = %v, want %d fields", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestDecodeResourceUnmarshalError(t *testing.T) {
+	hc := newHTTPClient("sk_test", "http://localhost", 0, &http.Client{})
+	hc.strict = true
+	if _, err := decodeResource[Subscription](hc, map[string]any{"id": 1}); err == nil {
+		t.Error("expected unmarshal error")
+	}
+}
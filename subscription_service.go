@@ -1,85 +1,902 @@
 package paylio
 
 import (
+	"bufio"
+	"bytes"
 	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"slices"
 	"strconv"
 	"strings"
+	"sync"
+	"time"
 )
 
+// DefaultPageSize is the page size used when ListOptions.PageSize is unset.
+const DefaultPageSize = 20
+
+// MaxPageSize is the largest page size accepted by ListOptionsFromQuery, and
+// by list calls unless changed with WithMaxPageSize.
+const MaxPageSize = 100
+
 // ListOptions configures pagination for subscription list requests.
 type ListOptions struct {
-	Page     int
-	PageSize int
+	Page     int `query:"page"`
+	PageSize int `query:"page_size"`
+	// IncludeArchived includes canceled and archived entries in subscription
+	// history, which is otherwise limited to active entries.
+	IncludeArchived bool `query:"include_archived,omitempty"`
+	// Cursor requests the page after the one that returned it as
+	// PaginatedList.NextCursor, for endpoints that paginate with cursors.
+	// When it is set, Page is not sent.
+	Cursor string `query:"cursor,omitempty"`
+	// Expand names related objects, such as "plan", to embed in each item.
+	// It is sent like WithExpand.
+	Expand []string `query:"-"`
+	// Fields limits each item to the named fields. It is sent like
+	// WithFields.
+	Fields []string `query:"-"`
+}
+
+// params returns the pagination query parameters, applying defaults for
+// unset fields. page is left out when a Cursor is set. It is safe to call on
+// a nil receiver.
+func (o *ListOptions) params() map[string]string {
+	resolved := ListOptions{Page: 1, PageSize: DefaultPageSize}
+	if o != nil {
+		resolved.IncludeArchived = o.IncludeArchived
+		resolved.Cursor = o.Cursor
+		if o.Page > 0 {
+			resolved.Page = o.Page
+		}
+		if o.PageSize > 0 {
+			resolved.PageSize = o.PageSize
+		}
+	}
+	// The query fields of ListOptions are ints, a bool, and a string, which
+	// encodeQuery always supports.
+	params, _ := encodeQuery(resolved)
+	if resolved.Cursor != "" {
+		// A cursor selects the page on its own.
+		delete(params, "page")
+	}
+	if o != nil && len(o.Fields) > 0 {
+		params["fields"] = strings.Join(o.Fields, ",")
+	}
+	return params
+}
+
+// validate checks that Page and PageSize are not negative and that PageSize
+// is at most maxPageSize. Zero values select the defaults. It is safe to
+// call on a nil receiver.
+func (o *ListOptions) validate(maxPageSize int) error {
+	switch {
+	case o == nil:
+		return nil
+	case o.Page < 0:
+		return NewInvalidRequestError(ErrorParams{
+			Message: fmt.Sprintf("page must not be negative, got %d", o.Page),
+			Param:   "page",
+		})
+	case o.PageSize < 0:
+		return NewInvalidRequestError(ErrorParams{
+			Message: fmt.Sprintf("page_size must not be negative, got %d", o.PageSize),
+			Param:   "page_size",
+		})
+	case o.PageSize > maxPageSize:
+		return NewInvalidRequestError(ErrorParams{
+			Message: fmt.Sprintf("page_size must be at most %d, got %d", maxPageSize, o.PageSize),
+			Param:   "page_size",
+		})
+	}
+	return nil
+}
+
+// expand returns the fields to expand. It is safe to call on a nil receiver.
+func (o *ListOptions) expand() []string {
+	if o == nil {
+		return nil
+	}
+	return o.Expand
+}
+
+// ListOptionsFromQuery builds ListOptions from the "page", "page_size",
+// "include_archived", and "cursor" parameters of a URL query, such as one received by an
+// HTTP handler. Missing parameters take their defaults: page 1 and
+// DefaultPageSize. Page and page size must be positive integers and the page
+// size at most MaxPageSize; otherwise an InvalidRequestError naming the
+// parameter is returned.
+func ListOptionsFromQuery(values url.Values) (*ListOptions, error) {
+	opts := &ListOptions{Page: 1, PageSize: DefaultPageSize, Cursor: values.Get("cursor")}
+	var err error
+	if opts.Page, err = positiveQueryInt(values, "page", opts.Page); err != nil {
+		return nil, err
+	}
+	if opts.PageSize, err = positiveQueryInt(values, "page_size", opts.PageSize); err != nil {
+		return nil, err
+	}
+	if opts.PageSize > MaxPageSize {
+		return nil, NewInvalidRequestError(ErrorParams{
+			Message: fmt.Sprintf("page_size must be at most %d", MaxPageSize),
+			Param:   "page_size",
+		})
+	}
+	if v := values.Get("include_archived"); v != "" {
+		if opts.IncludeArchived, err = strconv.ParseBool(v); err != nil {
+			return nil, NewInvalidRequestError(ErrorParams{
+				Message: fmt.Sprintf("include_archived must be a boolean, got %q", v),
+				Param:   "include_archived",
+			})
+		}
+	}
+	return opts, nil
+}
+
+// positiveQueryInt parses the query parameter name as a positive integer,
+// returning def if it is absent.
+func positiveQueryInt(values url.Values, name string, def int) (int, error) {
+	v := values.Get(name)
+	if v == "" {
+		return def, nil
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil || n < 1 {
+		return 0, NewInvalidRequestError(ErrorParams{
+			Message: fmt.Sprintf("%s must be a positive integer, got %q", name, v),
+			Param:   name,
+		})
+	}
+	return n, nil
+}
+
+// CancelRefundParams configures SubscriptionService.CancelAndRefund.
+type CancelRefundParams struct {
+	// Amount is the prorated amount to refund. It must be positive.
+	Amount float64
+	// Reason records why the subscription is being canceled and refunded.
+	Reason string
+}
+
+// AdminListOptions filters the subscriptions of every user, for admin
+// operations such as Export. Zero fields do not filter.
+type AdminListOptions struct {
+	Status   SubscriptionStatus `query:"status,omitempty"`
+	PlanSlug string             `query:"plan_slug,omitempty"`
+	Provider Provider           `query:"provider,omitempty"`
+	// CreatedAfter and CreatedBefore bound the subscriptions' creation time.
+	CreatedAfter  time.Time `query:"created_after,omitempty"`
+	CreatedBefore time.Time `query:"created_before,omitempty"`
 }
 
 // CancelOptions configures subscription cancellation behavior.
 type CancelOptions struct {
 	CancelNow bool
+	// CancelAt schedules cancellation for a specific time instead of the end
+	// of the billing period. It cannot be combined with CancelNow.
+	CancelAt time.Time
+	// Reason records why the subscription is being canceled.
+	Reason string
+	// Feedback holds optional free-form comments from the customer.
+	Feedback string
+	// IfStatus, when set, makes the cancel conditional: the API rejects it
+	// with a ConflictError unless the subscription currently has this
+	// status.
+	IfStatus SubscriptionStatus
+}
+
+// CreateSubscriptionParams configures a new subscription.
+type CreateSubscriptionParams struct {
+	UserID   string `json:"user_id"`
+	PlanSlug string `json:"plan_slug"`
+	// Provider selects the payment provider. When empty the API default is
+	// used; otherwise it must be one of the known Provider constants.
+	Provider Provider `json:"provider,omitempty"`
+	// Quantity is the number of seats. When zero the API default of 1 is
+	// used.
+	Quantity int `json:"quantity,omitempty"`
+	// Metadata is attached to the subscription. It is merged over any
+	// client-level default metadata.
+	Metadata map[string]string `json:"metadata,omitempty"`
+	// ExternalID is a caller-supplied identifier that the API uses to detect
+	// duplicate creates, such as webhooks delivered more than once.
+	ExternalID string `json:"external_id,omitempty"`
+	// ReturnExistingOnConflict makes Create return the already existing
+	// subscription instead of a ConflictError when the API reports a
+	// duplicate. It is not sent to the API.
+	ReturnExistingOnConflict bool `json:"-"`
+}
+
+// toJSONBody returns the request body for p, omitting unset optional fields.
+func (p *CreateSubscriptionParams) toJSONBody() map[string]any {
+	return encodeBody(p)
+}
+
+// ChangePlanParams configures moving a subscription to a different plan.
+type ChangePlanParams struct {
+	PlanSlug string `json:"plan_slug"`
+	// Prorate charges or credits the difference for the rest of the current
+	// period instead of applying the new price at the next renewal.
+	Prorate bool `json:"prorate,omitempty"`
+	// Quantity sets the number of seats on the new plan. When zero the
+	// current quantity is kept.
+	Quantity int `json:"quantity,omitempty"`
+	// Metadata is attached to the subscription. It is merged over any
+	// client-level default metadata.
+	Metadata map[string]string `json:"metadata,omitempty"`
+}
+
+// toJSONBody returns the request body for p, omitting unset optional fields.
+func (p *ChangePlanParams) toJSONBody() map[string]any {
+	return encodeBody(p)
+}
+
+// UpdateSubscriptionParams lists subscription fields to change. Only fields
+// that are set are sent; the rest are left unchanged.
+type UpdateSubscriptionParams struct {
+	// Metadata replaces the subscription's metadata. It is merged over any
+	// client-level default metadata.
+	Metadata map[string]string `json:"metadata,omitempty"`
+	// Quantity sets the number of seats.
+	Quantity *int `json:"quantity,omitempty"`
+	// CancelAtPeriodEnd schedules or unschedules cancellation at the end of
+	// the current period.
+	CancelAtPeriodEnd *bool `json:"cancel_at_period_end,omitempty"`
+}
+
+// toJSONBody returns the request body for p, omitting unset fields.
+func (p *UpdateSubscriptionParams) toJSONBody() map[string]any {
+	return encodeBody(p)
+}
+
+// ExtendTrialParams sets a new trial end, either as a time or as a number of
+// days to add. Exactly one of the fields must be set.
+type ExtendTrialParams struct {
+	// TrialEnd is the new end of the trial.
+	TrialEnd time.Time
+	// TrialDays extends the trial by this many days.
+	TrialDays int
+}
+
+// toJSONBody returns the request body for p.
+func (p *ExtendTrialParams) toJSONBody() map[string]any {
+	if !p.TrialEnd.IsZero() {
+		return map[string]any{"trial_end": p.TrialEnd.UTC().Format(time.RFC3339)}
+	}
+	return map[string]any{"trial_days": p.TrialDays}
+}
+
+// BatchResult holds the outcome of one item of a batch operation.
+type BatchResult struct {
+	ID     string
+	Result *SubscriptionCancel
+	Err    error
 }
 
+// defaultBatchConcurrency bounds in-flight requests for batch operations
+// unless overridden with WithBatchConcurrency.
+const defaultBatchConcurrency = 4
+
 // SubscriptionService provides methods for interacting with subscriptions.
 type SubscriptionService struct {
-	http *httpClient
+	http             *httpClient
+	batchConcurrency int
+	// refunds issues the refund of CancelAndRefund.
+	refunds *RefundService
 }
 
 func newSubscriptionService(hc *httpClient) *SubscriptionService {
-	return &SubscriptionService{http: hc}
+	return &SubscriptionService{http: hc, batchConcurrency: defaultBatchConcurrency, refunds: newRefundService(hc)}
 }
 
 // Retrieve fetches the current subscription for a user.
-func (s *SubscriptionService) Retrieve(ctx context.Context, userID string) (*Subscription, error) {
+func (s *SubscriptionService) Retrieve(ctx context.Context, userID string, opts ...RequestOption) (*Subscription, error) {
 	if strings.TrimSpace(userID) == "" {
 		return nil, errors.New("userID is required")
 	}
-	data, err := s.http.request(ctx, "GET", fmt.Sprintf("/subscription/%s", userID), nil)
+	data, err := s.http.request(ctx, "GET", fmt.Sprintf("/subscription/%s", userID), applyRequestOptions(nil, opts))
 	if err != nil {
 		return nil, err
 	}
-	return unmarshalTo[Subscription](data)
+	return decodeResource[Subscription](s.http, data)
 }
 
-// List fetches paginated subscription history for a user.
-func (s *SubscriptionService) List(ctx context.Context, userID string, opts *ListOptions) (*PaginatedList[SubscriptionHistoryItem], error) {
-	if strings.TrimSpace(userID) == "" {
+// RetrieveWithBackoff is like Retrieve but retries while the API responds
+// 404, backing off exponentially, to read a subscription created moments ago
+// that may not have replicated yet. It gives up once maxWait has elapsed and
+// returns the last NotFoundError. Other errors are returned immediately.
+func (s *SubscriptionService) RetrieveWithBackoff(ctx context.Context, userID string, maxWait time.Duration, opts ...RequestOption) (*Subscription, error) {
+	ctx = orBackground(ctx)
+	deadline := s.http.clock.Now().Add(maxWait)
+	for attempt := 1; ; attempt++ {
+		sub, err := s.Retrieve(ctx, userID, opts...)
+		var nf *NotFoundError
+		if !errors.As(err, &nf) {
+			return sub, err
+		}
+		remaining := deadline.Sub(s.http.clock.Now())
+		if remaining <= 0 {
+			return nil, err
+		}
+		if s.http.sleep(ctx, min(backoffDelay(attempt), remaining)) != nil {
+			return nil, err
+		}
+	}
+}
+
+// HasActive reports whether a user has a subscription that is active or
+// trialing. A user with no subscription (HTTP 404) yields false and a nil
+// error.
+func (s *SubscriptionService) HasActive(ctx context.Context, userID string, opts ...RequestOption) (bool, error) {
+	sub, err := s.Retrieve(ctx, userID, opts...)
+	if err != nil {
+		var nf *NotFoundError
+		if errors.As(err, &nf) {
+			return false, nil
+		}
+		return false, err
+	}
+	return sub.IsActive() || sub.HasTrialStatus(), nil
+}
+
+// Get fetches a subscription by its own ID, as opposed to Retrieve, which
+// looks up a user's current subscription.
+func (s *SubscriptionService) Get(ctx context.Context, subscriptionID string, opts ...RequestOption) (*Subscription, error) {
+	if strings.TrimSpace(subscriptionID) == "" {
+		return nil, errors.New("subscriptionID is required")
+	}
+	data, err := s.http.request(ctx, "GET", fmt.Sprintf("/subscriptions/%s", subscriptionID), applyRequestOptions(nil, opts))
+	if err != nil {
+		return nil, err
+	}
+	return decodeResource[Subscription](s.http, data)
+}
+
+// Refresh re-fetches sub and overwrites it in place with the latest state.
+// It fetches by sub.ID with Get when set, so the same subscription is
+// refreshed, and otherwise the current subscription of sub.UserID with
+// Retrieve. sub is left unchanged on error.
+func (s *SubscriptionService) Refresh(ctx context.Context, sub *Subscription, opts ...RequestOption) error {
+	if sub == nil {
+		return errors.New("sub is required")
+	}
+	var latest *Subscription
+	var err error
+	switch {
+	case strings.TrimSpace(sub.ID) != "":
+		latest, err = s.Get(ctx, sub.ID, opts...)
+	case strings.TrimSpace(sub.UserID) != "":
+		latest, err = s.Retrieve(ctx, sub.UserID, opts...)
+	default:
+		return errors.New("sub has no ID or UserID")
+	}
+	if err != nil {
+		return err
+	}
+	*sub = *latest
+	return nil
+}
+
+// UpcomingInvoice previews the next invoice for a subscription. It returns a
+// nil invoice and a nil error when nothing is due, which the API reports as
+// a 404 with the CodeNoUpcomingInvoice code. Any other 404, such as for an
+// unknown subscription, is returned as a NotFoundError.
+func (s *SubscriptionService) UpcomingInvoice(ctx context.Context, subscriptionID string, opts ...RequestOption) (*Invoice, error) {
+	if strings.TrimSpace(subscriptionID) == "" {
+		return nil, errors.New("subscriptionID is required")
+	}
+	data, err := s.http.request(ctx, "GET", fmt.Sprintf("/subscription/%s/upcoming-invoice", subscriptionID), applyRequestOptions(nil, opts))
+	if pe, ok := AsPaylioError(err); ok && pe.HTTPStatus == http.StatusNotFound && pe.ErrorCode() == CodeNoUpcomingInvoice {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return decodeResource[Invoice](s.http, data)
+}
+
+// Exists reports whether a subscription exists, using a HEAD request so no
+// body is transferred. A 404 yields false and a nil error; any other failure
+// is returned.
+func (s *SubscriptionService) Exists(ctx context.Context, subscriptionID string, opts ...RequestOption) (bool, error) {
+	if strings.TrimSpace(subscriptionID) == "" {
+		return false, errors.New("subscriptionID is required")
+	}
+	_, err := s.http.request(ctx, "HEAD", fmt.Sprintf("/subscriptions/%s", subscriptionID), applyRequestOptions(nil, opts))
+	if err != nil {
+		var nf *NotFoundError
+		if errors.As(err, &nf) {
+			return false, nil
+		}
+		return false, err
+	}
+	return true, nil
+}
+
+// Create creates a subscription for a user.
+func (s *SubscriptionService) Create(ctx context.Context, params *CreateSubscriptionParams, opts ...RequestOption) (*Subscription, error) {
+	if params == nil {
+		return nil, errors.New("params are required")
+	}
+	if strings.TrimSpace(params.UserID) == "" {
 		return nil, errors.New("userID is required")
 	}
-	page := 1
-	pageSize := 20
-	if opts != nil {
-		if opts.Page > 0 {
-			page = opts.Page
+	if strings.TrimSpace(params.PlanSlug) == "" {
+		return nil, errors.New("planSlug is required")
+	}
+	if params.Provider != "" && !params.Provider.isKnown() {
+		return nil, NewInvalidRequestError(ErrorParams{
+			Message: fmt.Sprintf("unknown provider %q", params.Provider),
+			Param:   "provider",
+		})
+	}
+	body := params.toJSONBody()
+	if metadata := s.http.mergeMetadata(params.Metadata); metadata != nil {
+		body["metadata"] = metadata
+	}
+	data, err := s.http.request(ctx, "POST", "/subscription", applyRequestOptions(&requestOptions{JSONBody: body}, opts))
+	if err != nil {
+		var conflictErr *ConflictError
+		if params.ReturnExistingOnConflict && errors.As(err, &conflictErr) {
+			if existing, ok := conflictErr.JSONBody["subscription"].(map[string]any); ok {
+				return decodeResource[Subscription](s.http, existing)
+			}
 		}
-		if opts.PageSize > 0 {
-			pageSize = opts.PageSize
+		return nil, err
+	}
+	return decodeResource[Subscription](s.http, data)
+}
+
+// Update partially updates a subscription, sending only the fields set in
+// params.
+func (s *SubscriptionService) Update(ctx context.Context, subscriptionID string, params *UpdateSubscriptionParams, opts ...RequestOption) (*Subscription, error) {
+	if strings.TrimSpace(subscriptionID) == "" {
+		return nil, errors.New("subscriptionID is required")
+	}
+	if params == nil {
+		return nil, errors.New("params are required")
+	}
+	body := params.toJSONBody()
+	if params.Metadata != nil {
+		if metadata := s.http.mergeMetadata(params.Metadata); metadata != nil {
+			body["metadata"] = metadata
 		}
 	}
-	params := map[string]string{
-		"page":      strconv.Itoa(page),
-		"page_size": strconv.Itoa(pageSize),
+	if len(body) == 0 {
+		return nil, errors.New("at least one field to update is required")
+	}
+	data, err := s.http.request(ctx, "PATCH", fmt.Sprintf("/subscription/%s", subscriptionID), applyRequestOptions(&requestOptions{JSONBody: body}, opts))
+	if err != nil {
+		return nil, err
+	}
+	return decodeResource[Subscription](s.http, data)
+}
+
+// ChangePlan moves a subscription to the plan named in params and returns
+// the updated subscription.
+func (s *SubscriptionService) ChangePlan(ctx context.Context, subscriptionID string, params *ChangePlanParams, opts ...RequestOption) (*Subscription, error) {
+	if strings.TrimSpace(subscriptionID) == "" {
+		return nil, errors.New("subscriptionID is required")
+	}
+	if params == nil {
+		return nil, errors.New("params are required")
 	}
-	data, err := s.http.request(ctx, "GET", fmt.Sprintf("/users/%s/subscriptions", userID), &requestOptions{Params: params})
+	if strings.TrimSpace(params.PlanSlug) == "" {
+		return nil, errors.New("planSlug is required")
+	}
+	body := params.toJSONBody()
+	if metadata := s.http.mergeMetadata(params.Metadata); metadata != nil {
+		body["metadata"] = metadata
+	}
+	data, err := s.http.request(ctx, "POST", fmt.Sprintf("/subscription/%s/change-plan", subscriptionID), applyRequestOptions(&requestOptions{JSONBody: body}, opts))
 	if err != nil {
 		return nil, err
 	}
-	return unmarshalTo[PaginatedList[SubscriptionHistoryItem]](data)
+	return decodeResource[Subscription](s.http, data)
+}
+
+// ExtendTrial moves the end of a subscription's trial and returns the
+// updated subscription.
+func (s *SubscriptionService) ExtendTrial(ctx context.Context, subscriptionID string, params *ExtendTrialParams, opts ...RequestOption) (*Subscription, error) {
+	if strings.TrimSpace(subscriptionID) == "" {
+		return nil, errors.New("subscriptionID is required")
+	}
+	if params == nil {
+		return nil, errors.New("params are required")
+	}
+	switch {
+	case !params.TrialEnd.IsZero() && params.TrialDays != 0:
+		return nil, errors.New("trialEnd and trialDays are mutually exclusive")
+	case params.TrialEnd.IsZero() && params.TrialDays == 0:
+		return nil, errors.New("trialEnd or trialDays is required")
+	case params.TrialDays < 0:
+		return nil, errors.New("trialDays must be positive")
+	}
+	data, err := s.http.request(ctx, "POST", fmt.Sprintf("/subscription/%s/extend-trial", subscriptionID), applyRequestOptions(&requestOptions{JSONBody: params.toJSONBody()}, opts))
+	if err != nil {
+		return nil, err
+	}
+	return decodeResource[Subscription](s.http, data)
+}
+
+// PortalURL creates a session in the payment provider's customer portal,
+// where the user can manage billing, and returns its URL. The portal
+// redirects back to returnURL, which must be an absolute http or https URL.
+func (s *SubscriptionService) PortalURL(ctx context.Context, userID, returnURL string, opts ...RequestOption) (string, error) {
+	if strings.TrimSpace(userID) == "" {
+		return "", errors.New("userID is required")
+	}
+	if u, err := url.Parse(returnURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return "", NewInvalidRequestError(ErrorParams{
+			Message: fmt.Sprintf("Invalid return URL %q: must be an absolute http or https URL", returnURL),
+			Param:   "return_url",
+		})
+	}
+	data, err := s.http.request(ctx, "POST", fmt.Sprintf("/subscription/%s/portal", userID), applyRequestOptions(&requestOptions{
+		JSONBody: map[string]any{"return_url": returnURL},
+	}, opts))
+	if err != nil {
+		return "", err
+	}
+	portalURL, _ := data["url"].(string)
+	if portalURL == "" {
+		return "", NewAPIError(ErrorParams{Message: "Response is missing required fields: url", JSONBody: data})
+	}
+	return portalURL, nil
+}
+
+// RetrieveInto fetches the current subscription for a user and decodes it
+// into out, which must be a non-nil pointer. Use it to model fields that the
+// Subscription type does not cover.
+func (s *SubscriptionService) RetrieveInto(ctx context.Context, userID string, out any, opts ...RequestOption) error {
+	if strings.TrimSpace(userID) == "" {
+		return errors.New("userID is required")
+	}
+	data, err := s.http.request(ctx, "GET", fmt.Sprintf("/subscription/%s", userID), applyRequestOptions(nil, opts))
+	if err != nil {
+		return err
+	}
+	return decodeInto(data, out)
+}
+
+// Watch streams live subscription updates for a user using server-sent
+// events. Transient connection drops are retried with backoff, resuming from
+// the last received event. Both channels are closed when ctx is done or the
+// server ends the stream; a permanent failure is sent on the error channel
+// before closing.
+func (s *SubscriptionService) Watch(ctx context.Context, userID string) (<-chan SubscriptionEvent, <-chan error) {
+	events := make(chan SubscriptionEvent)
+	errs := make(chan error, 1)
+	if strings.TrimSpace(userID) == "" {
+		errs <- errors.New("userID is required")
+		close(events)
+		close(errs)
+		return events, errs
+	}
+	go s.http.watch(ctx, fmt.Sprintf("/subscription/%s/events", userID), events, errs)
+	return events, errs
+}
+
+// List fetches paginated subscription history for a user.
+func (s *SubscriptionService) List(ctx context.Context, userID string, opts *ListOptions, reqOpts ...RequestOption) (*PaginatedList[SubscriptionHistoryItem], error) {
+	if strings.TrimSpace(userID) == "" {
+		return nil, errors.New("userID is required")
+	}
+	return requestList[SubscriptionHistoryItem](ctx, s.http, fmt.Sprintf("/users/%s/subscriptions", userID), opts, reqOpts)
+}
+
+// StatusHistory fetches the paginated status transitions of a subscription,
+// such as trialing to active.
+func (s *SubscriptionService) StatusHistory(ctx context.Context, subscriptionID string, opts *ListOptions, reqOpts ...RequestOption) (*PaginatedList[StatusChange], error) {
+	if strings.TrimSpace(subscriptionID) == "" {
+		return nil, errors.New("subscriptionID is required")
+	}
+	return requestList[StatusChange](ctx, s.http, fmt.Sprintf("/subscription/%s/status-history", subscriptionID), opts, reqOpts)
+}
+
+// ListAutoPaging returns an Iterator over a user's entire subscription
+// history, fetching pages of opts.PageSize as needed. Iteration starts at
+// opts.Page when set. Once a page returns a NextCursor, later pages are
+// requested by cursor alone.
+//
+// ctx bounds the whole iteration, not each page: once it is done, Next
+// returns false and Err returns an error matching ctx.Err() with errors.Is,
+// such as context.DeadlineExceeded.
+func (s *SubscriptionService) ListAutoPaging(ctx context.Context, userID string, opts *ListOptions, reqOpts ...RequestOption) *Iterator[SubscriptionHistoryItem] {
+	ctx = orBackground(ctx)
+	var base ListOptions
+	if opts != nil {
+		base = *opts
+	}
+	startPage := max(base.Page, 1)
+	return newIterator(func(page int, cursor string) (*PaginatedList[SubscriptionHistoryItem], error) {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		pageOpts := base
+		pageOpts.Page = startPage + page - 1
+		if cursor != "" {
+			pageOpts.Cursor = cursor
+		}
+		return s.List(ctx, userID, &pageOpts, reqOpts...)
+	})
 }
 
 // Cancel cancels a subscription. By default cancels at end of billing period.
 // Set CancelOptions.CancelNow to true for immediate cancellation.
-func (s *SubscriptionService) Cancel(ctx context.Context, subscriptionID string, opts *CancelOptions) (*SubscriptionCancel, error) {
+//
+// The request carries a random Idempotency-Key, generated once per call and
+// reused across its retries, so it is retried like an idempotent request
+// without a retried cancel being applied twice. WithIdempotencyKey
+// overrides the generated key.
+//
+// If the subscription was already canceled, Cancel returns a result with
+// Success false together with the API error, which wraps
+// ErrAlreadyCanceled.
+func (s *SubscriptionService) Cancel(ctx context.Context, subscriptionID string, opts *CancelOptions, reqOpts ...RequestOption) (*SubscriptionCancel, error) {
 	if strings.TrimSpace(subscriptionID) == "" {
 		return nil, errors.New("subscriptionID is required")
 	}
-	cancelNow := false
+	body := map[string]any{"cancel_at_period_end": true}
 	if opts != nil {
-		cancelNow = opts.CancelNow
+		if opts.CancelNow && !opts.CancelAt.IsZero() {
+			return nil, errors.New("cancelNow and cancelAt are mutually exclusive")
+		}
+		if opts.CancelAt.IsZero() {
+			body["cancel_at_period_end"] = !opts.CancelNow
+		} else {
+			delete(body, "cancel_at_period_end")
+			body["cancel_at"] = opts.CancelAt.UTC().Format(time.RFC3339)
+		}
+		if opts.Reason != "" {
+			body["reason"] = opts.Reason
+		}
+		if opts.Feedback != "" {
+			body["feedback"] = opts.Feedback
+		}
+		if opts.IfStatus != "" {
+			body["if_status"] = string(opts.IfStatus)
+		}
+	}
+	base := &requestOptions{JSONBody: body}
+	base.setHeader(idempotencyKeyHeader, newIdempotencyKey("cancel_"))
+	data, err := s.http.request(ctx, "POST", fmt.Sprintf("/subscription/%s/cancel", subscriptionID), applyRequestOptions(base, reqOpts))
+	if pe, ok := AsPaylioError(err); ok && pe.ErrorCode() == CodeAlreadyCanceled {
+		pe.cause = ErrAlreadyCanceled
+		return &SubscriptionCancel{ID: subscriptionID, Object: "subscription_cancel"}, err
 	}
-	body := map[string]any{"cancel_at_period_end": !cancelNow}
-	data, err := s.http.request(ctx, "POST", fmt.Sprintf("/subscription/%s/cancel", subscriptionID), &requestOptions{JSONBody: body})
 	if err != nil {
 		return nil, err
 	}
-	return unmarshalTo[SubscriptionCancel](data)
+	return decodeResource[SubscriptionCancel](s.http, data)
+}
+
+// newIdempotencyKey returns a random Idempotency-Key with the given prefix.
+func newIdempotencyKey(prefix string) string {
+	var b [16]byte
+	// rand.Read does not fail on supported platforms.
+	_, _ = rand.Read(b[:])
+	return prefix + hex.EncodeToString(b[:])
+}
+
+// CancelAndRefund cancels a subscription immediately and then refunds
+// params.Amount against it, returning both results. params are validated
+// before anything is sent. If the cancel fails, no refund is attempted. If
+// the cancel succeeds but the refund fails, the cancel result is returned
+// with a nil refund and an error wrapping both ErrRefundFailed and the
+// refund's error; issue the refund again with Refunds.Create rather than
+// repeating the whole call. reqOpts apply to both requests, except that the
+// refund always carries its own Idempotency-Key: a key set with
+// WithIdempotencyKey is sent with the cancel only.
+func (s *SubscriptionService) CancelAndRefund(ctx context.Context, subscriptionID string, params *CancelRefundParams, reqOpts ...RequestOption) (*SubscriptionCancel, *Refund, error) {
+	if strings.TrimSpace(subscriptionID) == "" {
+		return nil, nil, errors.New("subscriptionID is required")
+	}
+	if params == nil {
+		return nil, nil, errors.New("params are required")
+	}
+	if params.Amount <= 0 {
+		return nil, nil, errors.New("amount must be positive")
+	}
+	canceled, err := s.Cancel(ctx, subscriptionID, &CancelOptions{CancelNow: true, Reason: params.Reason}, reqOpts...)
+	if err != nil {
+		return nil, nil, err
+	}
+	refundOpts := append(slices.Clip(reqOpts), WithIdempotencyKey(newIdempotencyKey("refund_")))
+	refund, err := s.refunds.Create(ctx, &CreateRefundParams{
+		SubscriptionID: subscriptionID,
+		Amount:         params.Amount,
+		Reason:         params.Reason,
+	}, refundOpts...)
+	if err != nil {
+		return canceled, nil, fmt.Errorf("%w after canceling %s: %w", ErrRefundFailed, subscriptionID, err)
+	}
+	return canceled, refund, nil
+}
+
+// CancelForUser cancels a user's current subscription, resolving it with
+// Retrieve first. It returns a NotFoundError if the user has no subscription.
+func (s *SubscriptionService) CancelForUser(ctx context.Context, userID string, opts *CancelOptions, reqOpts ...RequestOption) (*SubscriptionCancel, error) {
+	sub, err := s.Retrieve(ctx, userID, reqOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return s.Cancel(ctx, sub.ID, opts, reqOpts...)
+}
+
+// CancelBatch cancels many subscriptions concurrently, with at most the
+// client's batch concurrency (see WithBatchConcurrency) in flight at once.
+// Results are returned in the order of ids, each carrying its own result or
+// error. If ctx is done before every cancel is dispatched, the remaining
+// items fail with the context's error, which is also returned.
+func (s *SubscriptionService) CancelBatch(ctx context.Context, ids []string, opts *CancelOptions) ([]BatchResult, error) {
+	ctx = orBackground(ctx)
+	results := make([]BatchResult, len(ids))
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < min(s.batchConcurrency, len(ids)); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				res, err := s.Cancel(ctx, ids[i], opts)
+				results[i] = BatchResult{ID: ids[i], Result: res, Err: err}
+			}
+		}()
+	}
+
+	var ctxErr error
+	for i := range ids {
+		if ctxErr = ctx.Err(); ctxErr != nil {
+			for j := i; j < len(ids); j++ {
+				results[j] = BatchResult{ID: ids[j], Err: ctxErr}
+			}
+			break
+		}
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+	return results, ctxErr
+}
+
+// Export streams every subscription matching opts to w as newline-delimited
+// JSON, one subscription object per line. Lines are written through as they
+// arrive, so memory use stays flat however large the export; decode them
+// into Subscription as needed. An export cut off mid-line returns an
+// APIConnectionError, and w may then hold a partial export.
+func (s *SubscriptionService) Export(ctx context.Context, opts *AdminListOptions, w io.Writer, reqOpts ...RequestOption) error {
+	if w == nil {
+		return errors.New("w is required")
+	}
+	// AdminListOptions only has string and time fields, which encodeQuery
+	// always supports.
+	params, _ := encodeQuery(opts)
+	resp, err := s.http.stream(ctx, "GET", "/subscriptions/export", applyRequestOptions(&requestOptions{
+		Params: params,
+		Header: http.Header{"Accept": {"application/x-ndjson"}},
+	}, reqOpts))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	r := bufio.NewReader(resp.Body)
+	for {
+		line, readErr := r.ReadBytes('\n')
+		if record := bytes.TrimSpace(line); len(record) > 0 {
+			if !json.Valid(record) {
+				if readErr != nil {
+					return newIncompleteBodyError()
+				}
+				return NewAPIError(ErrorParams{Message: "Invalid JSON in export record"})
+			}
+			if _, err := w.Write(append(record, '\n')); err != nil {
+				return err
+			}
+		}
+		if readErr == io.EOF {
+			return nil
+		}
+		if readErr != nil {
+			return newConnectionError(readErr)
+		}
+	}
+}
+
+// RetrieveMany fetches subscriptions by ID, keyed by ID in the result. It
+// uses the bulk endpoint, falling back to individual Get calls with at most
+// the configured batch concurrency in flight when the API does not offer
+// it. Subscriptions that could not be fetched are absent from the map, and
+// their errors are combined with errors.Join.
+func (s *SubscriptionService) RetrieveMany(ctx context.Context, ids []string, opts ...RequestOption) (map[string]*Subscription, error) {
+	if len(ids) == 0 {
+		return nil, errors.New("ids are required")
+	}
+	for _, id := range ids {
+		if strings.TrimSpace(id) == "" {
+			return nil, errors.New("subscriptionID is required")
+		}
+	}
+	subs, err := s.retrieveBulk(ctx, ids, opts)
+	var pe *PaylioError
+	if errors.As(err, &pe) && isMissingEndpoint(pe.HTTPStatus) {
+		return s.retrieveEach(ctx, ids, opts)
+	}
+	return subs, err
+}
+
+// isMissingEndpoint reports whether status indicates the API does not serve
+// the requested endpoint at all.
+func isMissingEndpoint(status int) bool {
+	return status == http.StatusNotFound || status == http.StatusMethodNotAllowed || status == http.StatusNotImplemented
+}
+
+// retrieveBulk fetches ids from the bulk endpoint. IDs the response omits
+// yield a NotFoundError.
+func (s *SubscriptionService) retrieveBulk(ctx context.Context, ids []string, opts []RequestOption) (map[string]*Subscription, error) {
+	data, err := s.http.request(ctx, "GET", "/subscriptions", applyRequestOptions(&requestOptions{
+		Params: map[string]string{"ids": strings.Join(ids, ",")},
+	}, opts))
+	if err != nil {
+		return nil, err
+	}
+	items, _ := data["items"].([]any)
+	subs := make(map[string]*Subscription, len(items))
+	var errs []error
+	for _, item := range items {
+		obj, _ := item.(map[string]any)
+		sub, err := decodeResource[Subscription](s.http, obj)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		subs[sub.ID] = sub
+	}
+	for _, id := range ids {
+		if _, ok := subs[id]; !ok {
+			errs = append(errs, NewNotFoundError(ErrorParams{Message: fmt.Sprintf("subscription %s not found", id)}))
+		}
+	}
+	return subs, errors.Join(errs...)
+}
+
+// retrieveEach fetches ids one by one with bounded concurrency. Errors are
+// combined in the order of ids.
+func (s *SubscriptionService) retrieveEach(ctx context.Context, ids []string, opts []RequestOption) (map[string]*Subscription, error) {
+	results := make([]*Subscription, len(ids))
+	errs := make([]error, len(ids))
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < min(s.batchConcurrency, len(ids)); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				results[i], errs[i] = s.Get(ctx, ids[i], opts...)
+				if errs[i] != nil {
+					errs[i] = fmt.Errorf("subscription %s: %w", ids[i], errs[i])
+				}
+			}
+		}()
+	}
+	for i := range ids {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+
+	subs := make(map[string]*Subscription, len(ids))
+	for i, sub := range results {
+		if sub != nil {
+			subs[ids[i]] = sub
+		}
+	}
+	return subs, errors.Join(errs...)
 }
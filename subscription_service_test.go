@@ -1,11 +1,19 @@
 package paylio
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"io"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 )
@@ -44,6 +52,226 @@ func TestRetrieveReturnsSubscription(t *testing.T) {
 	}
 }
 
+func TestRetrieveNilContextUsesBackground(t *testing.T) {
+	svc, srv := newTestService(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(200)
+		_, _ = w.Write([]byte(`{"id":"sub_1","status":"active"}`))
+	})
+	defer srv.Close()
+
+	//lint:ignore SA1012 nil is the case under test
+	sub, err := svc.Retrieve(nil, "user_1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sub.ID != "sub_1" {
+		t.Errorf("ID = %q", sub.ID)
+	}
+}
+
+func TestRetrieveWithBackoff(t *testing.T) {
+	srv, hits := newSequenceServer(t,
+		sequenceResponse{status: 404, body: `{"error":"not found"}`},
+		sequenceResponse{status: 404, body: `{"error":"not found"}`},
+		sequenceResponse{status: 200, body: `{"id":"sub_1","status":"active"}`},
+	)
+	defer srv.Close()
+	hc, fc := newRetryingHTTPClient(srv.URL, 0)
+	svc := newSubscriptionService(hc)
+
+	sub, err := svc.RetrieveWithBackoff(context.Background(), "user_1", 10*time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sub.ID != "sub_1" || hits.Load() != 3 {
+		t.Errorf("sub = %+v after %d requests", sub, hits.Load())
+	}
+	if got := fc.Sleeps(); len(got) != 2 || got[0] != 500*time.Millisecond || got[1] != time.Second {
+		t.Errorf("sleeps = %v, want [500ms 1s]", got)
+	}
+}
+
+func TestRetrieveWithBackoffGivesUp(t *testing.T) {
+	srv, hits := newSequenceServer(t, sequenceResponse{status: 404, body: `{"error":"not found"}`})
+	defer srv.Close()
+	hc, fc := newRetryingHTTPClient(srv.URL, 0)
+	svc := newSubscriptionService(hc)
+
+	_, err := svc.RetrieveWithBackoff(context.Background(), "user_1", 2*time.Second)
+	var nf *NotFoundError
+	if !errors.As(err, &nf) {
+		t.Fatalf("expected *NotFoundError, got %T: %v", err, err)
+	}
+	if got := fc.Sleeps(); len(got) != 3 || got[2] != 500*time.Millisecond || hits.Load() != 4 {
+		t.Errorf("sleeps = %v after %d requests, want [500ms 1s 500ms] after 4", got, hits.Load())
+	}
+
+	// A context deadline that expires before the next backoff stops the
+	// retries with the last NotFoundError.
+	fc.now = time.Now()
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	if _, err := svc.RetrieveWithBackoff(ctx, "user_1", time.Minute); !errors.As(err, &nf) {
+		t.Errorf("short deadline: expected *NotFoundError, got %T: %v", err, err)
+	}
+	if _, err := svc.RetrieveWithBackoff(context.Background(), "", time.Minute); err == nil || err.Error() != "userID is required" {
+		t.Errorf("empty userID: err = %v", err)
+	}
+}
+
+func TestHasActive(t *testing.T) {
+	tests := []struct {
+		name   string
+		status int
+		body   string
+		want   bool
+	}{
+		{"active", 200, `{"id":"sub_1","status":"active"}`, true},
+		{"trialing", 200, `{"id":"sub_1","status":"trialing"}`, true},
+		{"canceled", 200, `{"id":"sub_1","status":"canceled"}`, false},
+		{"not found", 404, `{"error":{"code":"not_found","message":"No subscription"}}`, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc, srv := newTestService(func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Path != "/subscription/user_1" {
+					t.Errorf("Path = %q", r.URL.Path)
+				}
+				w.WriteHeader(tt.status)
+				_, _ = w.Write([]byte(tt.body))
+			})
+			defer srv.Close()
+
+			got, err := svc.HasActive(context.Background(), "user_1")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("HasActive() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHasActivePropagatesErrors(t *testing.T) {
+	svc, srv := newTestService(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(500)
+		_, _ = w.Write([]byte(`{"error":"boom"}`))
+	})
+	defer srv.Close()
+
+	got, err := svc.HasActive(context.Background(), "user_1")
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) {
+		t.Fatalf("expected *APIError, got %T: %v", err, err)
+	}
+	if got {
+		t.Error("HasActive() = true on error")
+	}
+}
+
+func TestGetBySubscriptionID(t *testing.T) {
+	svc, srv := newTestService(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "GET" {
+			t.Errorf("Method = %q", r.Method)
+		}
+		if r.URL.Path != "/subscriptions/sub_1" {
+			t.Errorf("Path = %q", r.URL.Path)
+		}
+		w.WriteHeader(200)
+		_, _ = w.Write([]byte(`{"id":"sub_1","status":"active","user_id":"user_123"}`))
+	})
+	defer srv.Close()
+
+	sub, err := svc.Get(context.Background(), "sub_1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sub.ID != "sub_1" || sub.UserID != "user_123" {
+		t.Errorf("sub = %+v", sub)
+	}
+}
+
+func TestRefresh(t *testing.T) {
+	status := "active"
+	svc, srv := newTestService(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/subscriptions/sub_1", "/subscription/user_1":
+		default:
+			t.Errorf("Path = %q", r.URL.Path)
+		}
+		w.WriteHeader(200)
+		_, _ = w.Write([]byte(`{"id":"sub_1","user_id":"user_1","status":"` + status + `","cancel_at_period_end":true}`))
+	})
+	defer srv.Close()
+
+	sub := &Subscription{ID: "sub_1", UserID: "user_1", Status: "active"}
+	status = "past_due"
+	if err := svc.Refresh(context.Background(), sub); err != nil {
+		t.Fatal(err)
+	}
+	if sub.Status != "past_due" || !sub.CancelAtPeriodEnd {
+		t.Errorf("refreshed by ID = %+v", sub)
+	}
+
+	byUser := &Subscription{UserID: "user_1"}
+	if err := svc.Refresh(context.Background(), byUser); err != nil {
+		t.Fatal(err)
+	}
+	if byUser.ID != "sub_1" || byUser.Status != "past_due" {
+		t.Errorf("refreshed by user = %+v", byUser)
+	}
+}
+
+func TestRefreshErrors(t *testing.T) {
+	svc, srv := newTestService(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(404)
+		_, _ = w.Write([]byte(`{"error":"not found"}`))
+	})
+	defer srv.Close()
+
+	sub := &Subscription{ID: "sub_gone", Status: "active"}
+	var nf *NotFoundError
+	if err := svc.Refresh(context.Background(), sub); !errors.As(err, &nf) {
+		t.Errorf("expected *NotFoundError, got %T: %v", err, err)
+	}
+	if sub.ID != "sub_gone" || sub.Status != "active" {
+		t.Errorf("sub changed on error: %+v", sub)
+	}
+	if err := svc.Refresh(context.Background(), &Subscription{ID: " "}); err == nil || err.Error() != "sub has no ID or UserID" {
+		t.Errorf("no identifier: err = %v", err)
+	}
+	if err := svc.Refresh(context.Background(), nil); err == nil || err.Error() != "sub is required" {
+		t.Errorf("nil sub: err = %v", err)
+	}
+}
+
+func TestGetNotFound(t *testing.T) {
+	svc, srv := newTestService(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(404)
+		_, _ = w.Write([]byte(`{"error":{"code":"not_found","message":"No such subscription"}}`))
+	})
+	defer srv.Close()
+
+	_, err := svc.Get(context.Background(), "sub_missing")
+	var nf *NotFoundError
+	if !errors.As(err, &nf) {
+		t.Fatalf("expected *NotFoundError, got %T: %v", err, err)
+	}
+}
+
+func TestGetEmptySubscriptionIDReturnsError(t *testing.T) {
+	svc, srv := newTestService(func(http.ResponseWriter, *http.Request) {
+		t.Error("request should not be sent")
+	})
+	defer srv.Close()
+
+	_, err := svc.Get(context.Background(), " ")
+	if err == nil || err.Error() != "subscriptionID is required" {
+		t.Errorf("err = %v", err)
+	}
+}
+
 func TestRetrieveEmptyUserIDReturnsError(t *testing.T) {
 	svc, srv := newTestService(func(w http.ResponseWriter, _ *http.Request) {
 		w.WriteHeader(200)
@@ -129,6 +357,287 @@ func TestListDefaultPagination(t *testing.T) {
 	}
 }
 
+func TestListIncludeArchived(t *testing.T) {
+	for _, include := range []bool{true, false} {
+		svc, srv := newTestService(func(w http.ResponseWriter, r *http.Request) {
+			got, ok := r.URL.Query()["include_archived"]
+			if include && (!ok || got[0] != "true") {
+				t.Errorf("include_archived = %q, want true", got)
+			}
+			if !include && ok {
+				t.Errorf("include_archived should be omitted, got %q", got)
+			}
+			w.WriteHeader(200)
+			_, _ = w.Write([]byte(`{"items":[],"total":0,"page":1,"page_size":20,"total_pages":0}`))
+		})
+
+		_, err := svc.List(context.Background(), "user_1", &ListOptions{IncludeArchived: include})
+		srv.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestRetrieveWithExpandSendsRepeatedParams(t *testing.T) {
+	svc, srv := newTestService(func(w http.ResponseWriter, r *http.Request) {
+		if got := r.URL.Query()["expand[]"]; strings.Join(got, ",") != "plan,discount" {
+			t.Errorf("expand[] = %q, want [plan discount]", got)
+		}
+		w.WriteHeader(200)
+		_, _ = w.Write([]byte(`{"id":"sub_1","status":"active","plan":{"slug":"pro","name":"Pro","interval":"month","amount":9.99,"currency":"usd"}}`))
+	})
+	defer srv.Close()
+
+	sub, err := svc.Retrieve(context.Background(), "user_1", WithExpand("plan"), WithExpand("discount"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sub.Plan.Slug != "pro" || sub.Plan.Interval != IntervalMonth {
+		t.Errorf("Plan = %+v", sub.Plan)
+	}
+}
+
+func TestRetrieveWithFieldsDecodesPartialResponse(t *testing.T) {
+	svc, srv := newTestService(func(w http.ResponseWriter, r *http.Request) {
+		if got := r.URL.Query().Get("fields"); got != "id,status,plan" {
+			t.Errorf("fields = %q, want id,status,plan", got)
+		}
+		w.WriteHeader(200)
+		_, _ = w.Write([]byte(`{"id":"sub_1","status":"active","plan":{"slug":"pro"}}`))
+	})
+	defer srv.Close()
+
+	sub, err := svc.Retrieve(context.Background(), "user_1", WithFields("id", "status", "plan"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sub.ID != "sub_1" || sub.Status != "active" || sub.Plan.Slug != "pro" {
+		t.Errorf("selected fields = %+v", sub)
+	}
+	if sub.UserID != "" || sub.CreatedAt != "" || sub.SubscriptionPeriod != (Period{}) {
+		t.Errorf("unselected fields should be zero: %+v", sub)
+	}
+}
+
+func TestListWithFields(t *testing.T) {
+	svc, srv := newTestService(func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		if q.Get("fields") != "id,status" || q.Get("page_size") != "20" {
+			t.Errorf("query = %v", q)
+		}
+		w.WriteHeader(200)
+		_, _ = w.Write([]byte(`{"items":[{"id":"h_1","status":"active"}],"total":1,"page":1,"page_size":20,"total_pages":1}`))
+	})
+	defer srv.Close()
+
+	list, err := svc.List(context.Background(), "user_1", &ListOptions{Fields: []string{"id", "status"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if item := list.Items[0]; item.ID != "h_1" || item.Status != "active" || item.PlanSlug != "" {
+		t.Errorf("item = %+v", item)
+	}
+}
+
+func TestListExpandDecodesNestedPlan(t *testing.T) {
+	svc, srv := newTestService(func(w http.ResponseWriter, r *http.Request) {
+		if got := r.URL.Query()["expand[]"]; len(got) != 1 || got[0] != "plan" {
+			t.Errorf("expand[] = %q, want [plan]", got)
+		}
+		if r.URL.Query().Get("page") != "1" {
+			t.Errorf("page = %q", r.URL.Query().Get("page"))
+		}
+		w.WriteHeader(200)
+		_, _ = w.Write([]byte(`{"items":[{"id":"h_1","plan_slug":"pro","plan":{"slug":"pro","name":"Pro","interval":"year","interval_count":2,"amount":99,"currency":"usd"}}],"total":1,"page":1,"page_size":20,"total_pages":1}`))
+	})
+	defer srv.Close()
+
+	list, err := svc.List(context.Background(), "user_1", &ListOptions{Expand: []string{"plan"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	plan := list.Items[0].Plan
+	if plan == nil || plan.Slug != "pro" || plan.Interval != IntervalYear || plan.IntervalCount != 2 {
+		t.Errorf("Plan = %+v", plan)
+	}
+}
+
+func TestExportStreamsNDJSON(t *testing.T) {
+	records := []string{
+		`{"id":"sub_1","status":"active","user_id":"user_1"}`,
+		`{"id":"sub_2","status":"active","user_id":"user_2"}`,
+		`{"id":"sub_3","status":"active","user_id":"user_3"}`,
+	}
+	svc, srv := newTestService(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/subscriptions/export" {
+			t.Errorf("Path = %q", r.URL.Path)
+		}
+		if got := r.Header.Get("Accept"); got != "application/x-ndjson" {
+			t.Errorf("Accept = %q", got)
+		}
+		q := r.URL.Query()
+		if q.Get("status") != "active" || q.Get("created_after") != "2025-01-01T00:00:00Z" || q.Has("plan_slug") {
+			t.Errorf("query = %v", q)
+		}
+		w.Header().Set("Content-Type", "application/x-ndjson")
+		w.WriteHeader(200)
+		for _, rec := range records {
+			_, _ = w.Write([]byte(rec + "\n\n"))
+			w.(http.Flusher).Flush()
+		}
+	})
+	defer srv.Close()
+
+	var out bytes.Buffer
+	err := svc.Export(context.Background(), &AdminListOptions{
+		Status:       SubscriptionStatusActive,
+		CreatedAfter: time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC),
+	}, &out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out.String() != strings.Join(records, "\n")+"\n" {
+		t.Fatalf("export = %q", out.String())
+	}
+
+	dec := json.NewDecoder(&out)
+	var ids []string
+	for dec.More() {
+		var sub Subscription
+		if err := dec.Decode(&sub); err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, sub.ID)
+	}
+	if strings.Join(ids, ",") != "sub_1,sub_2,sub_3" {
+		t.Errorf("ids = %v", ids)
+	}
+}
+
+func TestExportErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		status int
+		body   string
+		check  func(error) bool
+	}{
+		{"truncated", 200, `{"id":"sub_1"}` + "\n" + `{"id":"su`, func(err error) bool {
+			var connErr *APIConnectionError
+			return errors.As(err, &connErr)
+		}},
+		{"invalid record", 200, "not json\n", func(err error) bool {
+			var apiErr *APIError
+			return errors.As(err, &apiErr)
+		}},
+		{"error status", 403, `{"error":"admin key required"}`, func(err error) bool {
+			pe, ok := AsPaylioError(err)
+			return ok && pe.HTTPStatus == 403
+		}},
+	}
+	for _, tt := range tests {
+		svc, srv := newTestService(func(w http.ResponseWriter, _ *http.Request) {
+			w.WriteHeader(tt.status)
+			_, _ = w.Write([]byte(tt.body))
+		})
+		err := svc.Export(context.Background(), nil, io.Discard)
+		srv.Close()
+		if !tt.check(err) {
+			t.Errorf("%s: unexpected error %T: %v", tt.name, err, err)
+		}
+	}
+
+	if err := newSubscriptionService(nil).Export(context.Background(), nil, nil); err == nil || err.Error() != "w is required" {
+		t.Errorf("nil writer: err = %v", err)
+	}
+}
+
+func TestExportWriteAndReadFailures(t *testing.T) {
+	svc, srv := newTestService(func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = w.Write([]byte(`{"id":"sub_1"}` + "\n"))
+	})
+	defer srv.Close()
+	errWrite := errors.New("disk full")
+	if err := svc.Export(context.Background(), nil, failingWriter{errWrite}); !errors.Is(err, errWrite) {
+		t.Errorf("write failure: err = %v", err)
+	}
+
+	hc := newHTTPClient("sk_test", "http://localhost", 10*time.Second, &http.Client{
+		Transport: roundTripFunc(func(*http.Request) (*http.Response, error) {
+			return &http.Response{StatusCode: 200, Header: http.Header{}, Body: io.NopCloser(errReader{})}, nil
+		}),
+	})
+	var connErr *APIConnectionError
+	if err := newSubscriptionService(hc).Export(context.Background(), nil, io.Discard); !errors.As(err, &connErr) {
+		t.Errorf("read failure: expected *APIConnectionError, got %T: %v", err, err)
+	}
+}
+
+// failingWriter is a writer that always fails with err.
+type failingWriter struct{ err error }
+
+func (w failingWriter) Write([]byte) (int, error) { return 0, w.err }
+
+func TestListAutoPagingKeepsIncludeArchived(t *testing.T) {
+	svc, srv := newTestService(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("include_archived") != "true" {
+			t.Errorf("include_archived = %q on page %s", r.URL.Query().Get("include_archived"), r.URL.Query().Get("page"))
+		}
+		page := r.URL.Query().Get("page")
+		w.WriteHeader(200)
+		_, _ = w.Write([]byte(`{"items":[{"id":"h_` + page + `"}],"total":2,"page":` + page + `,"page_size":1,"total_pages":2}`))
+	})
+	defer srv.Close()
+
+	it := svc.ListAutoPaging(context.Background(), "user_1", &ListOptions{PageSize: 1, IncludeArchived: true})
+	n := 0
+	for it.Next() {
+		n++
+	}
+	if err := it.Err(); err != nil {
+		t.Fatal(err)
+	}
+	if n != 2 {
+		t.Errorf("items = %d, want 2", n)
+	}
+}
+
+func TestListRejectsInvalidPagination(t *testing.T) {
+	var hits atomic.Int32
+	svc, srv := newTestService(func(w http.ResponseWriter, _ *http.Request) {
+		hits.Add(1)
+		w.WriteHeader(200)
+		_, _ = w.Write([]byte(`{"items":[],"total":0,"page":1,"page_size":20,"total_pages":0}`))
+	})
+	defer srv.Close()
+
+	tests := []struct {
+		name  string
+		opts  ListOptions
+		param string
+	}{
+		{"negative page", ListOptions{Page: -1}, "page"},
+		{"negative page size", ListOptions{PageSize: -5}, "page_size"},
+		{"over cap", ListOptions{PageSize: MaxPageSize + 1}, "page_size"},
+	}
+	for _, tt := range tests {
+		_, err := svc.List(context.Background(), "user_1", &tt.opts)
+		var invalid *InvalidRequestError
+		if !errors.As(err, &invalid) || invalid.Param != tt.param {
+			t.Errorf("%s: expected *InvalidRequestError for %s, got %T: %v", tt.name, tt.param, err, err)
+		}
+	}
+	if hits.Load() != 0 {
+		t.Errorf("invalid options sent %d requests", hits.Load())
+	}
+
+	for _, opts := range []*ListOptions{nil, {}, {Page: 2, PageSize: MaxPageSize}} {
+		if _, err := svc.List(context.Background(), "user_1", opts); err != nil {
+			t.Errorf("List(%+v): %v", opts, err)
+		}
+	}
+}
+
 func TestListEmptyUserIDReturnsError(t *testing.T) {
 	svc, srv := newTestService(func(w http.ResponseWriter, _ *http.Request) {
 		w.WriteHeader(200)
@@ -214,15 +723,1544 @@ func TestCancelNowSendsFalse(t *testing.T) {
 	}
 }
 
-func TestCancelEmptySubscriptionIDReturnsError(t *testing.T) {
-	svc, srv := newTestService(func(w http.ResponseWriter, _ *http.Request) {
+func TestCancelAtSendsDate(t *testing.T) {
+	svc, srv := newTestService(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		var parsed map[string]any
+		if err := json.Unmarshal(body, &parsed); err != nil {
+			t.Fatal(err)
+		}
+		if parsed["cancel_at"] != "2025-06-30T00:00:00Z" {
+			t.Errorf("cancel_at = %v", parsed["cancel_at"])
+		}
+		if _, ok := parsed["cancel_at_period_end"]; ok {
+			t.Errorf("cancel_at_period_end should be omitted, body = %v", parsed)
+		}
 		w.WriteHeader(200)
-		_, _ = w.Write([]byte(`{}`))
+		_, _ = w.Write([]byte(`{"id":"sub_uuid","success":true}`))
 	})
 	defer srv.Close()
 
-	_, err := svc.Cancel(context.Background(), "", nil)
-	if err == nil {
-		t.Fatal("expected error for empty subscriptionID")
+	cancelAt := time.Date(2025, 6, 30, 2, 0, 0, 0, time.FixedZone("CEST", 2*60*60))
+	_, err := svc.Cancel(context.Background(), "sub_uuid", &CancelOptions{CancelAt: cancelAt})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCancelRetriesWithSameIdempotencyKey(t *testing.T) {
+	var keys []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		keys = append(keys, r.Header.Get("Idempotency-Key"))
+		if len(keys) == 1 {
+			w.WriteHeader(503)
+			_, _ = w.Write([]byte(`{"error":"unavailable"}`))
+			return
+		}
+		w.WriteHeader(200)
+		_, _ = w.Write([]byte(`{"id":"sub_1","success":true}`))
+	}))
+	defer srv.Close()
+	hc, _ := newRetryingHTTPClient(srv.URL, 2)
+	svc := newSubscriptionService(hc)
+
+	if _, err := svc.Cancel(context.Background(), "sub_1", &CancelOptions{Reason: "too_expensive"}); err != nil {
+		t.Fatal(err)
+	}
+	if len(keys) != 2 || keys[0] == "" || keys[0] != keys[1] {
+		t.Fatalf("Idempotency-Key per attempt = %q, want the same non-empty key twice", keys)
+	}
+
+	// A second, identical cancel is a new operation with its own key.
+	if _, err := svc.Cancel(context.Background(), "sub_1", &CancelOptions{Reason: "too_expensive"}); err != nil {
+		t.Fatal(err)
+	}
+	if keys[2] == "" || keys[2] == keys[0] {
+		t.Errorf("repeated cancel key = %q, want a new key distinct from %q", keys[2], keys[0])
+	}
+	if _, err := svc.Cancel(context.Background(), "sub_1", nil, WithIdempotencyKey("key_custom")); err != nil {
+		t.Fatal(err)
+	}
+	if keys[3] != "key_custom" {
+		t.Errorf("overridden key = %q, want key_custom", keys[3])
+	}
+}
+
+func TestCancelIfStatus(t *testing.T) {
+	for _, ifStatus := range []SubscriptionStatus{SubscriptionStatusActive, ""} {
+		svc, srv := newTestService(func(w http.ResponseWriter, r *http.Request) {
+			var parsed map[string]any
+			_ = json.NewDecoder(r.Body).Decode(&parsed)
+			got, ok := parsed["if_status"]
+			if ifStatus != "" && got != string(ifStatus) {
+				t.Errorf("if_status = %v, want %q", got, ifStatus)
+			}
+			if ifStatus == "" && ok {
+				t.Errorf("if_status should be omitted, got %v", got)
+			}
+			w.WriteHeader(200)
+			_, _ = w.Write([]byte(`{"id":"sub_1","success":true}`))
+		})
+		_, err := svc.Cancel(context.Background(), "sub_1", &CancelOptions{IfStatus: ifStatus})
+		srv.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestCancelIfStatusMismatchReturnsConflictError(t *testing.T) {
+	svc, srv := newTestService(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(409)
+		_, _ = w.Write([]byte(`{"error":{"code":"status_mismatch","message":"Subscription is past_due, not active"}}`))
+	})
+	defer srv.Close()
+
+	_, err := svc.Cancel(context.Background(), "sub_1", &CancelOptions{IfStatus: SubscriptionStatusActive})
+	var conflict *ConflictError
+	if !errors.As(err, &conflict) {
+		t.Fatalf("expected *ConflictError, got %T: %v", err, err)
+	}
+	if conflict.Code != "status_mismatch" || conflict.HTTPStatus != 409 {
+		t.Errorf("conflict = %+v", conflict.PaylioError)
+	}
+}
+
+func TestCancelAlreadyCanceled(t *testing.T) {
+	svc, srv := newTestService(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(409)
+		_, _ = w.Write([]byte(`{"error":{"code":"already_canceled","message":"Subscription is already canceled"}}`))
+	})
+	defer srv.Close()
+
+	res, err := svc.Cancel(context.Background(), "sub_1", nil)
+	if !errors.Is(err, ErrAlreadyCanceled) {
+		t.Fatalf("err = %v, want ErrAlreadyCanceled", err)
+	}
+	var conflict *ConflictError
+	if !errors.As(err, &conflict) || conflict.ErrorCode() != CodeAlreadyCanceled {
+		t.Errorf("expected *ConflictError with already_canceled, got %T: %v", err, err)
+	}
+	if res == nil || res.ID != "sub_1" || res.Success {
+		t.Errorf("result = %+v, want unsuccessful result for sub_1", res)
+	}
+}
+
+func TestCancelAndRefund(t *testing.T) {
+	var paths []string
+	keys := map[string]string{}
+	svc, srv := newTestService(func(w http.ResponseWriter, r *http.Request) {
+		paths = append(paths, r.URL.Path)
+		keys[r.URL.Path] = r.Header.Get("Idempotency-Key")
+		var body map[string]any
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		w.WriteHeader(200)
+		switch r.URL.Path {
+		case "/subscription/sub_1/cancel":
+			if body["cancel_at_period_end"] != false || body["reason"] != "offboarding" {
+				t.Errorf("cancel body = %v", body)
+			}
+			_, _ = w.Write([]byte(`{"id":"sub_1","success":true,"cancel_at_period_end":false}`))
+		case "/refunds":
+			if body["subscription_id"] != "sub_1" || body["amount"] != 4.5 || body["reason"] != "offboarding" {
+				t.Errorf("refund body = %v", body)
+			}
+			_, _ = w.Write([]byte(`{"id":"re_1","amount":4.5,"currency":"usd","status":"succeeded"}`))
+		}
+	})
+	defer srv.Close()
+
+	canceled, refund, err := svc.CancelAndRefund(context.Background(), "sub_1", &CancelRefundParams{Amount: 4.5, Reason: "offboarding"},
+		WithIdempotencyKey("key_caller"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !canceled.Success || refund.ID != "re_1" || refund.Amount != 4.5 {
+		t.Errorf("results = %+v, %+v", canceled, refund)
+	}
+	if strings.Join(paths, ",") != "/subscription/sub_1/cancel,/refunds" {
+		t.Errorf("paths = %v", paths)
+	}
+	if keys["/subscription/sub_1/cancel"] != "key_caller" {
+		t.Errorf("cancel Idempotency-Key = %q, want key_caller", keys["/subscription/sub_1/cancel"])
+	}
+	if k := keys["/refunds"]; k == "" || k == "key_caller" {
+		t.Errorf("refund Idempotency-Key = %q, want its own key", k)
+	}
+}
+
+func TestCancelAndRefundUsesClientRefundService(t *testing.T) {
+	client, err := NewClient("sk_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if client.Subscription.refunds != client.Refunds {
+		t.Error("CancelAndRefund should refund through the client's RefundService")
+	}
+}
+
+func TestCancelAndRefundPartialFailure(t *testing.T) {
+	svc, srv := newTestService(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/refunds" {
+			w.WriteHeader(400)
+			_, _ = w.Write([]byte(`{"error":{"code":"refund_window_closed","message":"Refund window has closed"}}`))
+			return
+		}
+		w.WriteHeader(200)
+		_, _ = w.Write([]byte(`{"id":"sub_1","success":true}`))
+	})
+	defer srv.Close()
+
+	canceled, refund, err := svc.CancelAndRefund(context.Background(), "sub_1", &CancelRefundParams{Amount: 4.5})
+	if !errors.Is(err, ErrRefundFailed) {
+		t.Fatalf("err = %v, want ErrRefundFailed", err)
+	}
+	var invalid *InvalidRequestError
+	if !errors.As(err, &invalid) || invalid.Code != "refund_window_closed" {
+		t.Errorf("expected the refund's *InvalidRequestError, got %v", err)
+	}
+	if canceled == nil || !canceled.Success || refund != nil {
+		t.Errorf("results = %+v, %+v; want the cancel result and no refund", canceled, refund)
+	}
+}
+
+func TestCancelAndRefundErrors(t *testing.T) {
+	var hits atomic.Int32
+	svc, srv := newTestService(func(w http.ResponseWriter, _ *http.Request) {
+		hits.Add(1)
+		w.WriteHeader(404)
+		_, _ = w.Write([]byte(`{"error":"not found"}`))
+	})
+	defer srv.Close()
+
+	tests := []struct {
+		id     string
+		params *CancelRefundParams
+		want   string
+	}{
+		{"", &CancelRefundParams{Amount: 1}, "subscriptionID is required"},
+		{"sub_1", nil, "params are required"},
+		{"sub_1", &CancelRefundParams{}, "amount must be positive"},
+	}
+	for _, tt := range tests {
+		if _, _, err := svc.CancelAndRefund(context.Background(), tt.id, tt.params); err == nil || err.Error() != tt.want {
+			t.Errorf("CancelAndRefund(%q, %+v) err = %v, want %q", tt.id, tt.params, err, tt.want)
+		}
+	}
+	if hits.Load() != 0 {
+		t.Errorf("invalid params sent %d requests", hits.Load())
+	}
+
+	canceled, refund, err := svc.CancelAndRefund(context.Background(), "sub_gone", &CancelRefundParams{Amount: 1})
+	var nf *NotFoundError
+	if !errors.As(err, &nf) || errors.Is(err, ErrRefundFailed) || canceled != nil || refund != nil {
+		t.Errorf("failed cancel: %v, %v, %v", canceled, refund, err)
+	}
+	if hits.Load() != 1 {
+		t.Errorf("refund attempted after failed cancel: %d requests", hits.Load())
+	}
+}
+
+func TestCancelNowAndCancelAtMutuallyExclusive(t *testing.T) {
+	svc, srv := newTestService(func(http.ResponseWriter, *http.Request) {
+		t.Error("request should not be sent")
+	})
+	defer srv.Close()
+
+	_, err := svc.Cancel(context.Background(), "sub_uuid", &CancelOptions{CancelNow: true, CancelAt: time.Now()})
+	if err == nil || err.Error() != "cancelNow and cancelAt are mutually exclusive" {
+		t.Errorf("err = %v", err)
+	}
+}
+
+func TestCancelEmptySubscriptionIDReturnsError(t *testing.T) {
+	svc, srv := newTestService(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(200)
+		_, _ = w.Write([]byte(`{}`))
+	})
+	defer srv.Close()
+
+	_, err := svc.Cancel(context.Background(), "", nil)
+	if err == nil {
+		t.Fatal("expected error for empty subscriptionID")
+	}
+}
+
+func TestListAutoPagingWalksAllPages(t *testing.T) {
+	var pages []string
+	svc, srv := newTestService(func(w http.ResponseWriter, r *http.Request) {
+		page := r.URL.Query().Get("page")
+		pages = append(pages, page)
+		if r.URL.Query().Get("page_size") != "2" {
+			t.Errorf("page_size = %q", r.URL.Query().Get("page_size"))
+		}
+		w.WriteHeader(200)
+		switch page {
+		case "2":
+			_, _ = w.Write([]byte(`{"items":[{"id":"sub_3"},{"id":"sub_4"}],"total":5,"page":2,"page_size":2,"total_pages":3}`))
+		default:
+			_, _ = w.Write([]byte(`{"items":[{"id":"sub_5"}],"total":5,"page":3,"page_size":2,"total_pages":3}`))
+		}
+	})
+	defer srv.Close()
+
+	it := svc.ListAutoPaging(context.Background(), "user_1", &ListOptions{Page: 2, PageSize: 2})
+	var ids []string
+	for it.Next() {
+		ids = append(ids, it.Value().ID)
+	}
+	if it.Err() != nil {
+		t.Fatal(it.Err())
+	}
+	if strings.Join(ids, ",") != "sub_3,sub_4,sub_5" {
+		t.Errorf("ids = %v", ids)
+	}
+	if strings.Join(pages, ",") != "2,3" {
+		t.Errorf("pages = %v", pages)
+	}
+}
+
+func TestListAutoPagingDefaults(t *testing.T) {
+	svc, srv := newTestService(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("page") != "1" {
+			t.Errorf("page = %q", r.URL.Query().Get("page"))
+		}
+		w.WriteHeader(200)
+		_, _ = w.Write([]byte(`{"items":[{"id":"sub_1"}],"total":1,"page":1,"page_size":20,"total_pages":1}`))
+	})
+	defer srv.Close()
+
+	it := svc.ListAutoPaging(context.Background(), "user_1", nil)
+	if !it.Next() || it.Value().ID != "sub_1" {
+		t.Fatalf("first item = %+v, err = %v", it.Value(), it.Err())
+	}
+	if it.Next() {
+		t.Error("expected a single item")
+	}
+}
+
+func TestListAutoPagingPropagatesError(t *testing.T) {
+	svc, srv := newTestService(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(500)
+		_, _ = w.Write([]byte(`{"error":"boom"}`))
+	})
+	defer srv.Close()
+
+	it := svc.ListAutoPaging(context.Background(), "user_1", nil)
+	if it.Next() {
+		t.Fatal("Next should return false")
+	}
+	var apiErr *APIError
+	if !errors.As(it.Err(), &apiErr) {
+		t.Errorf("Err() = %T, want *APIError", it.Err())
+	}
+}
+
+func TestListAutoPagingHonorsOverallDeadline(t *testing.T) {
+	var pages atomic.Int32
+	svc, srv := newTestService(func(w http.ResponseWriter, r *http.Request) {
+		pages.Add(1)
+		time.Sleep(40 * time.Millisecond)
+		page := r.URL.Query().Get("page")
+		w.WriteHeader(200)
+		_, _ = w.Write([]byte(`{"items":[{"id":"h_` + page + `"}],"total":100,"page":` + page + `,"page_size":1,"total_pages":100}`))
+	})
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 150*time.Millisecond)
+	defer cancel()
+	it := svc.ListAutoPaging(ctx, "user_1", &ListOptions{PageSize: 1})
+	n := 0
+	for it.Next() {
+		n++
+	}
+	if !errors.Is(it.Err(), context.DeadlineExceeded) {
+		t.Fatalf("Err() = %v, want context.DeadlineExceeded", it.Err())
+	}
+	if n == 0 || n >= 100 {
+		t.Errorf("iterated %d items, want to stop mid-iteration", n)
+	}
+	if int(pages.Load()) > n+1 {
+		t.Errorf("fetched %d pages for %d items", pages.Load(), n)
+	}
+}
+
+func TestListAutoPagingStopsBetweenPagesAfterDeadline(t *testing.T) {
+	var pages atomic.Int32
+	svc, srv := newTestService(func(w http.ResponseWriter, r *http.Request) {
+		pages.Add(1)
+		page := r.URL.Query().Get("page")
+		w.WriteHeader(200)
+		_, _ = w.Write([]byte(`{"items":[{"id":"h_` + page + `"}],"total":2,"page":` + page + `,"page_size":1,"total_pages":2}`))
+	})
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	it := svc.ListAutoPaging(ctx, "user_1", &ListOptions{PageSize: 1})
+	if !it.Next() {
+		t.Fatalf("first page: %v", it.Err())
+	}
+	<-ctx.Done()
+	if it.Next() {
+		t.Fatal("Next should return false after the deadline")
+	}
+	if it.Err() != context.DeadlineExceeded || pages.Load() != 1 {
+		t.Errorf("Err() = %v after %d pages, want context.DeadlineExceeded after 1", it.Err(), pages.Load())
+	}
+}
+
+func TestRetrieveIntoCustomStruct(t *testing.T) {
+	svc, srv := newTestService(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/subscription/user_123" {
+			t.Errorf("Path = %q", r.URL.Path)
+		}
+		w.WriteHeader(200)
+		_, _ = w.Write([]byte(`{"id":"sub_1","status":"active","seats":5,"features":["sso","audit"]}`))
+	})
+	defer srv.Close()
+
+	var out struct {
+		ID       string   `json:"id"`
+		Status   string   `json:"status"`
+		Seats    int      `json:"seats"`
+		Features []string `json:"features"`
+	}
+	if err := svc.RetrieveInto(context.Background(), "user_123", &out); err != nil {
+		t.Fatal(err)
+	}
+	if out.ID != "sub_1" || out.Status != "active" {
+		t.Errorf("out = %+v", out)
+	}
+	if out.Seats != 5 {
+		t.Errorf("Seats = %d", out.Seats)
+	}
+	if strings.Join(out.Features, ",") != "sso,audit" {
+		t.Errorf("Features = %v", out.Features)
+	}
+}
+
+func TestRetrieveIntoEmptyUserIDReturnsError(t *testing.T) {
+	svc, srv := newTestService(func(w http.ResponseWriter, _ *http.Request) {
+		t.Error("request should not be sent")
+	})
+	defer srv.Close()
+
+	var out map[string]any
+	err := svc.RetrieveInto(context.Background(), "", &out)
+	if err == nil || err.Error() != "userID is required" {
+		t.Errorf("err = %v", err)
+	}
+}
+
+func TestRetrieveIntoTypedError(t *testing.T) {
+	svc, srv := newTestService(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(404)
+		_, _ = w.Write([]byte(`{"error":{"code":"not_found","message":"no subscription"}}`))
+	})
+	defer srv.Close()
+
+	var out map[string]any
+	err := svc.RetrieveInto(context.Background(), "user_1", &out)
+	var nfErr *NotFoundError
+	if !errors.As(err, &nfErr) {
+		t.Fatalf("expected *NotFoundError, got %T: %v", err, err)
+	}
+}
+
+func TestRetrieveIntoNonPointerReturnsError(t *testing.T) {
+	svc, srv := newTestService(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(200)
+		_, _ = w.Write([]byte(`{"id":"sub_1"}`))
+	})
+	defer srv.Close()
+
+	var out struct{ ID string }
+	if err := svc.RetrieveInto(context.Background(), "user_1", out); err == nil {
+		t.Fatal("expected error for non-pointer out")
+	}
+}
+
+func TestCreateSendsExternalID(t *testing.T) {
+	svc, srv := newTestService(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "POST" {
+			t.Errorf("Method = %q", r.Method)
+		}
+		if r.URL.Path != "/subscription" {
+			t.Errorf("Path = %q", r.URL.Path)
+		}
+		body, _ := io.ReadAll(r.Body)
+		var parsed map[string]any
+		if err := json.Unmarshal(body, &parsed); err != nil {
+			t.Fatal(err)
+		}
+		if parsed["user_id"] != "user_1" || parsed["plan_slug"] != "pro" || parsed["external_id"] != "evt_abc" {
+			t.Errorf("body = %v", parsed)
+		}
+		if _, ok := parsed["return_existing_on_conflict"]; ok {
+			t.Error("ReturnExistingOnConflict must not be sent")
+		}
+		w.WriteHeader(201)
+		_, _ = w.Write([]byte(`{"id":"sub_new","status":"active","user_id":"user_1"}`))
+	})
+	defer srv.Close()
+
+	sub, err := svc.Create(context.Background(), &CreateSubscriptionParams{
+		UserID:                   "user_1",
+		PlanSlug:                 "pro",
+		ExternalID:               "evt_abc",
+		ReturnExistingOnConflict: true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sub.ID != "sub_new" {
+		t.Errorf("ID = %q", sub.ID)
+	}
+}
+
+func TestCreateOmitsEmptyExternalID(t *testing.T) {
+	svc, srv := newTestService(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		var parsed map[string]any
+		if err := json.Unmarshal(body, &parsed); err != nil {
+			t.Fatal(err)
+		}
+		if _, ok := parsed["external_id"]; ok {
+			t.Errorf("external_id should be omitted, body = %v", parsed)
+		}
+		w.WriteHeader(201)
+		_, _ = w.Write([]byte(`{"id":"sub_new"}`))
+	})
+	defer srv.Close()
+
+	if _, err := svc.Create(context.Background(), &CreateSubscriptionParams{UserID: "user_1", PlanSlug: "pro"}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+const conflictBody = `{"error":{"code":"already_subscribed","message":"subscription exists"},"subscription":{"id":"sub_existing","status":"active","user_id":"user_1"}}`
+
+func TestCreateConflictReturnsExisting(t *testing.T) {
+	svc, srv := newTestService(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(409)
+		_, _ = w.Write([]byte(conflictBody))
+	})
+	defer srv.Close()
+
+	sub, err := svc.Create(context.Background(), &CreateSubscriptionParams{
+		UserID:                   "user_1",
+		PlanSlug:                 "pro",
+		ExternalID:               "evt_abc",
+		ReturnExistingOnConflict: true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sub.ID != "sub_existing" {
+		t.Errorf("ID = %q", sub.ID)
+	}
+}
+
+func TestCreateConflictDefaultReturnsError(t *testing.T) {
+	svc, srv := newTestService(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(409)
+		_, _ = w.Write([]byte(conflictBody))
+	})
+	defer srv.Close()
+
+	_, err := svc.Create(context.Background(), &CreateSubscriptionParams{UserID: "user_1", PlanSlug: "pro", ExternalID: "evt_abc"})
+	var conflictErr *ConflictError
+	if !errors.As(err, &conflictErr) {
+		t.Fatalf("expected *ConflictError, got %T: %v", err, err)
+	}
+	if conflictErr.Code != "already_subscribed" {
+		t.Errorf("Code = %q", conflictErr.Code)
+	}
+}
+
+func TestCreateConflictWithoutExistingReturnsError(t *testing.T) {
+	svc, srv := newTestService(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(409)
+		_, _ = w.Write([]byte(`{"error":{"code":"conflict","message":"busy"}}`))
+	})
+	defer srv.Close()
+
+	_, err := svc.Create(context.Background(), &CreateSubscriptionParams{UserID: "user_1", PlanSlug: "pro", ReturnExistingOnConflict: true})
+	var conflictErr *ConflictError
+	if !errors.As(err, &conflictErr) {
+		t.Fatalf("expected *ConflictError, got %T: %v", err, err)
+	}
+}
+
+func TestCreateValidation(t *testing.T) {
+	svc, srv := newTestService(func(w http.ResponseWriter, _ *http.Request) {
+		t.Error("request should not be sent")
+	})
+	defer srv.Close()
+
+	tests := []struct {
+		name    string
+		params  *CreateSubscriptionParams
+		wantErr string
+	}{
+		{"nil params", nil, "params are required"},
+		{"missing user", &CreateSubscriptionParams{PlanSlug: "pro"}, "userID is required"},
+		{"missing plan", &CreateSubscriptionParams{UserID: "user_1"}, "planSlug is required"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := svc.Create(context.Background(), tt.params)
+			if err == nil || err.Error() != tt.wantErr {
+				t.Errorf("err = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestCancelSendsReasonAndFeedback(t *testing.T) {
+	svc, srv := newTestService(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		var parsed map[string]any
+		if err := json.Unmarshal(body, &parsed); err != nil {
+			t.Fatal(err)
+		}
+		if parsed["reason"] != "too_expensive" {
+			t.Errorf("reason = %v", parsed["reason"])
+		}
+		if parsed["feedback"] != "Will be back next year" {
+			t.Errorf("feedback = %v", parsed["feedback"])
+		}
+		if parsed["cancel_at_period_end"] != true {
+			t.Errorf("cancel_at_period_end = %v", parsed["cancel_at_period_end"])
+		}
+		w.WriteHeader(200)
+		_, _ = w.Write([]byte(`{"id":"sub_uuid","success":true,"cancel_at_period_end":true}`))
+	})
+	defer srv.Close()
+
+	_, err := svc.Cancel(context.Background(), "sub_uuid", &CancelOptions{
+		Reason:   "too_expensive",
+		Feedback: "Will be back next year",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCancelOmitsBlankReasonAndFeedback(t *testing.T) {
+	svc, srv := newTestService(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		var parsed map[string]any
+		if err := json.Unmarshal(body, &parsed); err != nil {
+			t.Fatal(err)
+		}
+		if len(parsed) != 1 || parsed["cancel_at_period_end"] != false {
+			t.Errorf("body = %v, want only cancel_at_period_end=false", parsed)
+		}
+		w.WriteHeader(200)
+		_, _ = w.Write([]byte(`{"id":"sub_uuid","success":true}`))
+	})
+	defer srv.Close()
+
+	_, err := svc.Cancel(context.Background(), "sub_uuid", &CancelOptions{CancelNow: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCreateSendsValidProvider(t *testing.T) {
+	svc, srv := newTestService(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		var parsed map[string]any
+		if err := json.Unmarshal(body, &parsed); err != nil {
+			t.Fatal(err)
+		}
+		if parsed["provider"] != "paddle" {
+			t.Errorf("provider = %v", parsed["provider"])
+		}
+		w.WriteHeader(201)
+		_, _ = w.Write([]byte(`{"id":"sub_new","provider":"paddle"}`))
+	})
+	defer srv.Close()
+
+	sub, err := svc.Create(context.Background(), &CreateSubscriptionParams{UserID: "user_1", PlanSlug: "pro", Provider: ProviderPaddle})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sub.Provider != ProviderPaddle {
+		t.Errorf("Provider = %q", sub.Provider)
+	}
+}
+
+func TestCreateRejectsUnknownProvider(t *testing.T) {
+	svc, srv := newTestService(func(w http.ResponseWriter, _ *http.Request) {
+		t.Error("request should not be sent")
+	})
+	defer srv.Close()
+
+	_, err := svc.Create(context.Background(), &CreateSubscriptionParams{UserID: "user_1", PlanSlug: "pro", Provider: "paypal"})
+	var invErr *InvalidRequestError
+	if !errors.As(err, &invErr) {
+		t.Fatalf("expected *InvalidRequestError, got %T: %v", err, err)
+	}
+	if invErr.Param != "provider" {
+		t.Errorf("Param = %q", invErr.Param)
+	}
+}
+
+func TestListRateLimitRemaining(t *testing.T) {
+	svc, srv := newTestService(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("X-Rate-Limit-Remaining", "42")
+		w.WriteHeader(200)
+		_, _ = w.Write([]byte(`{"items":[],"total":0,"page":1,"page_size":20,"total_pages":0}`))
+	})
+	defer srv.Close()
+
+	list, err := svc.List(context.Background(), "user_1", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	remaining, ok := list.RateLimitRemaining()
+	if !ok || remaining != 42 {
+		t.Errorf("RateLimitRemaining() = %d, %v; want 42, true", remaining, ok)
+	}
+}
+
+func TestListInvalidItemsReturnsError(t *testing.T) {
+	svc, srv := newTestService(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(200)
+		_, _ = w.Write([]byte(`{"items":"not-a-list"}`))
+	})
+	defer srv.Close()
+
+	if _, err := svc.List(context.Background(), "user_1", nil); err == nil {
+		t.Fatal("expected decode error")
+	}
+}
+
+func TestCancelBatchMixedResults(t *testing.T) {
+	var inFlight, maxInFlight atomic.Int32
+	svc, srv := newTestService(func(w http.ResponseWriter, r *http.Request) {
+		n := inFlight.Add(1)
+		defer inFlight.Add(-1)
+		for {
+			m := maxInFlight.Load()
+			if n <= m || maxInFlight.CompareAndSwap(m, n) {
+				break
+			}
+		}
+		time.Sleep(10 * time.Millisecond)
+
+		id := strings.TrimSuffix(strings.TrimPrefix(r.URL.Path, "/subscription/"), "/cancel")
+		if strings.HasPrefix(id, "bad_") {
+			w.WriteHeader(404)
+			_, _ = w.Write([]byte(`{"error":{"code":"not_found","message":"no such subscription"}}`))
+			return
+		}
+		w.WriteHeader(200)
+		_, _ = w.Write([]byte(`{"id":"` + id + `","success":true,"cancel_at_period_end":true}`))
+	})
+	defer srv.Close()
+	svc.batchConcurrency = 2
+
+	ids := []string{"sub_1", "bad_2", "sub_3", "sub_4", "bad_5"}
+	results, err := svc.CancelBatch(context.Background(), ids, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != len(ids) {
+		t.Fatalf("results = %d, want %d", len(results), len(ids))
+	}
+	for i, res := range results {
+		if res.ID != ids[i] {
+			t.Errorf("results[%d].ID = %q, want %q", i, res.ID, ids[i])
+		}
+		if strings.HasPrefix(res.ID, "bad_") {
+			var nfErr *NotFoundError
+			if !errors.As(res.Err, &nfErr) || res.Result != nil {
+				t.Errorf("%s: Result = %+v, Err = %v; want NotFoundError", res.ID, res.Result, res.Err)
+			}
+			continue
+		}
+		if res.Err != nil || res.Result == nil || res.Result.ID != res.ID || !res.Result.Success {
+			t.Errorf("%s: Result = %+v, Err = %v", res.ID, res.Result, res.Err)
+		}
+	}
+	if m := maxInFlight.Load(); m > 2 {
+		t.Errorf("max in-flight = %d, want <= 2", m)
+	}
+}
+
+func TestCancelBatchContextCanceled(t *testing.T) {
+	svc, srv := newTestService(func(w http.ResponseWriter, _ *http.Request) {
+		t.Error("no request should be sent")
+	})
+	defer srv.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	results, err := svc.CancelBatch(ctx, []string{"sub_1", "sub_2"}, nil)
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("err = %v, want context.Canceled", err)
+	}
+	for _, res := range results {
+		if !errors.Is(res.Err, context.Canceled) {
+			t.Errorf("%s: Err = %v", res.ID, res.Err)
+		}
+	}
+}
+
+func TestCancelBatchEmpty(t *testing.T) {
+	svc, srv := newTestService(func(w http.ResponseWriter, _ *http.Request) {
+		t.Error("no request should be sent")
+	})
+	defer srv.Close()
+
+	results, err := svc.CancelBatch(context.Background(), nil, nil)
+	if err != nil || len(results) != 0 {
+		t.Errorf("results = %v, err = %v", results, err)
+	}
+}
+
+func TestParamsToJSONBody(t *testing.T) {
+	tests := []struct {
+		name string
+		body map[string]any
+		want string
+	}{
+		{
+			name: "create minimal",
+			body: (&CreateSubscriptionParams{UserID: "user_1", PlanSlug: "pro"}).toJSONBody(),
+			want: `{"plan_slug":"pro","user_id":"user_1"}`,
+		},
+		{
+			name: "create full",
+			body: (&CreateSubscriptionParams{
+				UserID:                   "user_1",
+				PlanSlug:                 "pro",
+				Provider:                 ProviderStripe,
+				Quantity:                 5,
+				Metadata:                 map[string]string{"k": "v"},
+				ExternalID:               "ext_1",
+				ReturnExistingOnConflict: true,
+			}).toJSONBody(),
+			want: `{"external_id":"ext_1","metadata":{"k":"v"},"plan_slug":"pro","provider":"stripe","quantity":5,"user_id":"user_1"}`,
+		},
+		{
+			name: "change plan minimal",
+			body: (&ChangePlanParams{PlanSlug: "team"}).toJSONBody(),
+			want: `{"plan_slug":"team"}`,
+		},
+		{
+			name: "change plan full",
+			body: (&ChangePlanParams{PlanSlug: "team", Prorate: true, Quantity: 3, Metadata: map[string]string{"k": "v"}}).toJSONBody(),
+			want: `{"metadata":{"k":"v"},"plan_slug":"team","prorate":true,"quantity":3}`,
+		},
+	}
+	for _, tt := range tests {
+		got, err := json.Marshal(tt.body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(got) != tt.want {
+			t.Errorf("%s: body = %s, want %s", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestEncodeBodyPreservesLargeIntegers(t *testing.T) {
+	body := encodeBody(struct {
+		N int64 `json:"n"`
+	}{N: 1<<62 + 1})
+	got, _ := json.Marshal(body)
+	if string(got) != `{"n":4611686018427387905}` {
+		t.Errorf("body = %s", got)
+	}
+}
+
+func TestUpdateSendsOnlySetFields(t *testing.T) {
+	quantity := 3
+	cancelAtPeriodEnd := false
+	tests := []struct {
+		name   string
+		params *UpdateSubscriptionParams
+		want   string
+	}{
+		{"metadata", &UpdateSubscriptionParams{Metadata: map[string]string{"team": "ops"}}, `{"metadata":{"team":"ops"}}`},
+		{"quantity", &UpdateSubscriptionParams{Quantity: &quantity}, `{"quantity":3}`},
+		{"cancel at period end false", &UpdateSubscriptionParams{CancelAtPeriodEnd: &cancelAtPeriodEnd}, `{"cancel_at_period_end":false}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc, srv := newTestService(func(w http.ResponseWriter, r *http.Request) {
+				if r.Method != "PATCH" {
+					t.Errorf("Method = %q", r.Method)
+				}
+				if r.URL.Path != "/subscription/sub_1" {
+					t.Errorf("Path = %q", r.URL.Path)
+				}
+				body, _ := io.ReadAll(r.Body)
+				if string(body) != tt.want {
+					t.Errorf("body = %s, want %s", body, tt.want)
+				}
+				w.WriteHeader(200)
+				_, _ = w.Write([]byte(`{"id":"sub_1","status":"active","quantity":3}`))
+			})
+			defer srv.Close()
+
+			sub, err := svc.Update(context.Background(), "sub_1", tt.params)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if sub.ID != "sub_1" {
+				t.Errorf("ID = %q", sub.ID)
+			}
+		})
+	}
+}
+
+func TestUpdateMergesDefaultMetadata(t *testing.T) {
+	var bodies []string
+	svc, srv := newTestService(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		bodies = append(bodies, string(b))
+		w.WriteHeader(200)
+		_, _ = w.Write([]byte(`{"id":"sub_1","status":"active"}`))
+	})
+	defer srv.Close()
+	svc.http.defaultMetadata = map[string]string{"tenant_id": "t_1", "source": "sdk"}
+
+	quantity := 2
+	if _, err := svc.Update(context.Background(), "sub_1", &UpdateSubscriptionParams{Metadata: map[string]string{"source": "dashboard"}}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := svc.Update(context.Background(), "sub_1", &UpdateSubscriptionParams{Quantity: &quantity}); err != nil {
+		t.Fatal(err)
+	}
+	want := []string{`{"metadata":{"source":"dashboard","tenant_id":"t_1"}}`, `{"quantity":2}`}
+	if !reflect.DeepEqual(bodies, want) {
+		t.Errorf("bodies = %q, want %q", bodies, want)
+	}
+}
+
+func TestUpdateValidation(t *testing.T) {
+	svc, srv := newTestService(func(http.ResponseWriter, *http.Request) {
+		t.Error("request should not be sent")
+	})
+	defer srv.Close()
+
+	ctx := context.Background()
+	if _, err := svc.Update(ctx, "", &UpdateSubscriptionParams{}); err == nil || err.Error() != "subscriptionID is required" {
+		t.Errorf("empty ID: err = %v", err)
+	}
+	if _, err := svc.Update(ctx, "sub_1", nil); err == nil || err.Error() != "params are required" {
+		t.Errorf("nil params: err = %v", err)
+	}
+	if _, err := svc.Update(ctx, "sub_1", &UpdateSubscriptionParams{}); err == nil || err.Error() != "at least one field to update is required" {
+		t.Errorf("empty params: err = %v", err)
+	}
+}
+
+func TestUpdateAPIError(t *testing.T) {
+	svc, srv := newTestService(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(404)
+		_, _ = w.Write([]byte(`{"error":"not found"}`))
+	})
+	defer srv.Close()
+
+	quantity := 2
+	_, err := svc.Update(context.Background(), "sub_1", &UpdateSubscriptionParams{Quantity: &quantity})
+	var nf *NotFoundError
+	if !errors.As(err, &nf) {
+		t.Fatalf("expected *NotFoundError, got %T: %v", err, err)
+	}
+}
+
+func TestChangePlan(t *testing.T) {
+	var body string
+	svc, srv := newTestService(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "POST" || r.URL.Path != "/subscription/sub_1/change-plan" {
+			t.Errorf("request = %s %s", r.Method, r.URL.Path)
+		}
+		b, _ := io.ReadAll(r.Body)
+		body = string(b)
+		w.WriteHeader(200)
+		_, _ = w.Write([]byte(`{"id":"sub_1","status":"active","plan":{"slug":"team"}}`))
+	})
+	defer srv.Close()
+	svc.http.defaultMetadata = map[string]string{"tenant_id": "t_1", "source": "sdk"}
+
+	sub, err := svc.ChangePlan(context.Background(), "sub_1", &ChangePlanParams{
+		PlanSlug: "team",
+		Prorate:  true,
+		Metadata: map[string]string{"source": "dashboard"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sub.Plan.Slug != "team" {
+		t.Errorf("Plan.Slug = %q", sub.Plan.Slug)
+	}
+	if want := `{"metadata":{"source":"dashboard","tenant_id":"t_1"},"plan_slug":"team","prorate":true}`; body != want {
+		t.Errorf("body = %s, want %s", body, want)
+	}
+}
+
+func TestChangePlanValidation(t *testing.T) {
+	svc, srv := newTestService(func(http.ResponseWriter, *http.Request) {
+		t.Error("request should not be sent")
+	})
+	defer srv.Close()
+
+	ctx := context.Background()
+	tests := []struct {
+		id     string
+		params *ChangePlanParams
+		want   string
+	}{
+		{" ", &ChangePlanParams{PlanSlug: "team"}, "subscriptionID is required"},
+		{"sub_1", nil, "params are required"},
+		{"sub_1", &ChangePlanParams{}, "planSlug is required"},
+	}
+	for _, tt := range tests {
+		if _, err := svc.ChangePlan(ctx, tt.id, tt.params); err == nil || err.Error() != tt.want {
+			t.Errorf("ChangePlan(%q, %+v) err = %v, want %q", tt.id, tt.params, err, tt.want)
+		}
+	}
+}
+
+func TestChangePlanAPIError(t *testing.T) {
+	svc, srv := newTestService(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(404)
+		_, _ = w.Write([]byte(`{"error":{"code":"plan_not_found","message":"No such plan"}}`))
+	})
+	defer srv.Close()
+
+	_, err := svc.ChangePlan(context.Background(), "sub_1", &ChangePlanParams{PlanSlug: "gone"})
+	var nf *NotFoundError
+	if !errors.As(err, &nf) {
+		t.Fatalf("expected *NotFoundError, got %T: %v", err, err)
+	}
+}
+
+func TestPATCHIsNotRetried(t *testing.T) {
+	hc := newHTTPClient("sk_test", "http://localhost", 0, &http.Client{})
+	if hc.shouldRetry("PATCH", nil, newConnectionError(io.EOF)) {
+		t.Error("PATCH must not be retried")
+	}
+}
+
+func TestRetrieveExposesUnmodeledFieldsInRaw(t *testing.T) {
+	svc, srv := newTestService(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(200)
+		_, _ = w.Write([]byte(`{"id":"sub_1","status":"active","experiment_flag":{"variant":"b"}}`))
+	})
+	defer srv.Close()
+
+	sub, err := svc.Retrieve(context.Background(), "user_1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var flag struct {
+		Variant string `json:"variant"`
+	}
+	if err := json.Unmarshal(sub.Raw["experiment_flag"], &flag); err != nil {
+		t.Fatalf("experiment_flag: %v (Raw = %v)", err, sub.Raw)
+	}
+	if flag.Variant != "b" {
+		t.Errorf("variant = %q", flag.Variant)
+	}
+	if string(sub.Raw["id"]) != `"sub_1"` {
+		t.Errorf(`Raw["id"] = %s`, sub.Raw["id"])
+	}
+}
+
+func TestCancelForUserResolvesThenCancels(t *testing.T) {
+	var paths []string
+	svc, srv := newTestService(func(w http.ResponseWriter, r *http.Request) {
+		paths = append(paths, r.Method+" "+r.URL.Path)
+		w.WriteHeader(200)
+		if r.Method == "GET" {
+			_, _ = w.Write([]byte(`{"id":"sub_42","status":"active"}`))
+			return
+		}
+		body, _ := io.ReadAll(r.Body)
+		if string(body) != `{"cancel_at_period_end":false}` {
+			t.Errorf("body = %s", body)
+		}
+		_, _ = w.Write([]byte(`{"id":"sub_42","success":true}`))
+	})
+	defer srv.Close()
+
+	res, err := svc.CancelForUser(context.Background(), "user_1", &CancelOptions{CancelNow: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.ID != "sub_42" || !res.Success {
+		t.Errorf("result = %+v", res)
+	}
+	want := []string{"GET /subscription/user_1", "POST /subscription/sub_42/cancel"}
+	if len(paths) != 2 || paths[0] != want[0] || paths[1] != want[1] {
+		t.Errorf("requests = %q, want %q", paths, want)
+	}
+}
+
+func TestCancelForUserNoSubscription(t *testing.T) {
+	calls := 0
+	svc, srv := newTestService(func(w http.ResponseWriter, _ *http.Request) {
+		calls++
+		w.WriteHeader(404)
+		_, _ = w.Write([]byte(`{"error":{"code":"not_found","message":"No subscription"}}`))
+	})
+	defer srv.Close()
+
+	_, err := svc.CancelForUser(context.Background(), "user_1", nil)
+	var nf *NotFoundError
+	if !errors.As(err, &nf) {
+		t.Fatalf("expected *NotFoundError, got %T: %v", err, err)
+	}
+	if calls != 1 {
+		t.Errorf("calls = %d, want 1", calls)
+	}
+}
+
+func TestUpcomingInvoice(t *testing.T) {
+	svc, srv := newTestService(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "GET" || r.URL.Path != "/subscription/sub_1/upcoming-invoice" {
+			t.Errorf("request = %s %s", r.Method, r.URL.Path)
+		}
+		w.WriteHeader(200)
+		_, _ = w.Write([]byte(`{"id":"in_next","amount":15,"currency":"usd","status":"draft"}`))
+	})
+	defer srv.Close()
+
+	inv, err := svc.UpcomingInvoice(context.Background(), "sub_1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if inv.ID != "in_next" {
+		t.Errorf("ID = %q", inv.ID)
+	}
+}
+
+func TestUpcomingInvoiceNoneDue(t *testing.T) {
+	svc, srv := newTestService(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(404)
+		_, _ = w.Write([]byte(`{"error":{"code":"no_upcoming_invoice","message":"No upcoming invoice"}}`))
+	})
+	defer srv.Close()
+
+	inv, err := svc.UpcomingInvoice(context.Background(), "sub_1")
+	if err != nil || inv != nil {
+		t.Errorf("UpcomingInvoice = %v, %v; want nil, nil", inv, err)
+	}
+}
+
+func TestUpcomingInvoiceUnknownSubscription(t *testing.T) {
+	svc, srv := newTestService(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(404)
+		_, _ = w.Write([]byte(`{"error":{"code":"not_found","message":"Subscription not found"}}`))
+	})
+	defer srv.Close()
+
+	inv, err := svc.UpcomingInvoice(context.Background(), "sub_missing")
+	var nf *NotFoundError
+	if !errors.As(err, &nf) || inv != nil {
+		t.Errorf("UpcomingInvoice = %v, %v; want nil and a *NotFoundError", inv, err)
+	}
+}
+
+func TestUpcomingInvoiceErrors(t *testing.T) {
+	svc, srv := newTestService(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(401)
+		_, _ = w.Write([]byte(`{"error":{"code":"invalid_api_key","message":"bad key"}}`))
+	})
+	defer srv.Close()
+
+	if _, err := svc.UpcomingInvoice(context.Background(), " "); err == nil || err.Error() != "subscriptionID is required" {
+		t.Errorf("err = %v", err)
+	}
+	var authErr *AuthenticationError
+	if _, err := svc.UpcomingInvoice(context.Background(), "sub_1"); !errors.As(err, &authErr) {
+		t.Errorf("expected *AuthenticationError, got %T", err)
+	}
+}
+
+func TestStatusHistory(t *testing.T) {
+	svc, srv := newTestService(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "GET" || r.URL.Path != "/subscription/sub_1/status-history" {
+			t.Errorf("request = %s %s", r.Method, r.URL.Path)
+		}
+		if r.URL.Query().Get("page") != "1" || r.URL.Query().Get("page_size") != "2" {
+			t.Errorf("query = %q", r.URL.RawQuery)
+		}
+		w.WriteHeader(200)
+		_, _ = w.Write([]byte(`{"items":[
+			{"from":"trialing","to":"active","changed_at":"2025-01-15T00:00:00Z","reason":"trial_ended"},
+			{"from":"active","to":"past_due","changed_at":"2025-02-15T00:00:00Z","reason":"payment_failed"}
+		],"total":3,"page":1,"page_size":2,"total_pages":2}`))
+	})
+	defer srv.Close()
+
+	list, err := svc.StatusHistory(context.Background(), "sub_1", &ListOptions{Page: 1, PageSize: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !list.HasMore() {
+		t.Error("HasMore should be true (page 1 of 2)")
+	}
+	if len(list.Items) != 2 {
+		t.Fatalf("Items len = %d", len(list.Items))
+	}
+	first, second := list.Items[0], list.Items[1]
+	if first.From != SubscriptionStatusTrialing || first.To != SubscriptionStatusActive || first.Reason != "trial_ended" {
+		t.Errorf("Items[0] = %+v", first)
+	}
+	if !first.ChangedAt.Equal(time.Date(2025, 1, 15, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("Items[0].ChangedAt = %v", first.ChangedAt)
+	}
+	if second.From != SubscriptionStatusActive || second.To != SubscriptionStatusPastDue {
+		t.Errorf("Items[1] = %+v", second)
+	}
+}
+
+func TestStatusHistoryEmptyIDReturnsError(t *testing.T) {
+	svc := newSubscriptionService(newHTTPClient("sk_test", "http://localhost", DefaultTimeout, http.DefaultClient))
+	if _, err := svc.StatusHistory(context.Background(), "", nil); err == nil || err.Error() != "subscriptionID is required" {
+		t.Errorf("err = %v", err)
+	}
+}
+
+func TestListOptionsFromQuery(t *testing.T) {
+	tests := []struct {
+		name  string
+		query string
+		want  ListOptions
+	}{
+		{"all set", "page=3&page_size=50&include_archived=true&cursor=cur_1", ListOptions{Page: 3, PageSize: 50, IncludeArchived: true, Cursor: "cur_1"}},
+		{"missing params", "", ListOptions{Page: 1, PageSize: DefaultPageSize}},
+		{"page size cap", "page_size=100", ListOptions{Page: 1, PageSize: MaxPageSize}},
+	}
+	for _, tt := range tests {
+		values, _ := url.ParseQuery(tt.query)
+		got, err := ListOptionsFromQuery(values)
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if !reflect.DeepEqual(*got, tt.want) {
+			t.Errorf("%s: got %+v, want %+v", tt.name, *got, tt.want)
+		}
+	}
+}
+
+func TestListOptionsFromQueryInvalid(t *testing.T) {
+	tests := []struct {
+		query string
+		param string
+	}{
+		{"page=abc", "page"},
+		{"page=0", "page"},
+		{"page_size=-5", "page_size"},
+		{"page_size=1.5", "page_size"},
+		{"page_size=101", "page_size"},
+		{"include_archived=maybe", "include_archived"},
+	}
+	for _, tt := range tests {
+		values, _ := url.ParseQuery(tt.query)
+		_, err := ListOptionsFromQuery(values)
+		var invErr *InvalidRequestError
+		if !errors.As(err, &invErr) {
+			t.Errorf("%s: expected *InvalidRequestError, got %T", tt.query, err)
+			continue
+		}
+		if invErr.Param != tt.param {
+			t.Errorf("%s: Param = %q, want %q", tt.query, invErr.Param, tt.param)
+		}
+	}
+}
+
+func TestExists(t *testing.T) {
+	tests := []struct {
+		name    string
+		status  int
+		want    bool
+		wantErr bool
+	}{
+		{"found", 200, true, false},
+		{"not found", 404, false, false},
+		{"server error", 500, false, true},
+	}
+	for _, tt := range tests {
+		svc, srv := newTestService(func(w http.ResponseWriter, r *http.Request) {
+			if r.Method != "HEAD" || r.URL.Path != "/subscriptions/sub_1" {
+				t.Errorf("request = %s %s", r.Method, r.URL.Path)
+			}
+			w.WriteHeader(tt.status)
+		})
+		got, err := svc.Exists(context.Background(), "sub_1")
+		srv.Close()
+		if got != tt.want || (err != nil) != tt.wantErr {
+			t.Errorf("%s: Exists = %v, %v", tt.name, got, err)
+		}
+	}
+	svc := newSubscriptionService(newHTTPClient("sk_test", "http://localhost", DefaultTimeout, http.DefaultClient))
+	if _, err := svc.Exists(context.Background(), ""); err == nil || err.Error() != "subscriptionID is required" {
+		t.Errorf("err = %v", err)
+	}
+}
+
+func TestRetrieveManyBulk(t *testing.T) {
+	svc, srv := newTestService(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/subscriptions" || r.URL.Query().Get("ids") != "sub_1,sub_2,sub_3" {
+			t.Errorf("request = %s?%s", r.URL.Path, r.URL.RawQuery)
+		}
+		w.WriteHeader(200)
+		_, _ = w.Write([]byte(`{"items":[{"id":"sub_1","status":"active"},{"id":"sub_3","status":"canceled"}]}`))
+	})
+	defer srv.Close()
+
+	subs, err := svc.RetrieveMany(context.Background(), []string{"sub_1", "sub_2", "sub_3"})
+	var nf *NotFoundError
+	if !errors.As(err, &nf) || !strings.Contains(err.Error(), "sub_2") {
+		t.Errorf("err = %v, want a NotFoundError for sub_2", err)
+	}
+	if len(subs) != 2 || subs["sub_1"].Status != "active" || subs["sub_3"].Status != "canceled" {
+		t.Errorf("subs = %v", subs)
+	}
+}
+
+func TestRetrieveManyBulkStrictRejectsInvalidItem(t *testing.T) {
+	svc, srv := newTestService(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(200)
+		_, _ = w.Write([]byte(`{"items":[{"id":"sub_1","status":"active"},{"status":"active"}]}`))
+	})
+	defer srv.Close()
+	svc.http.strict = true
+
+	subs, err := svc.RetrieveMany(context.Background(), []string{"sub_1"})
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) {
+		t.Errorf("expected *APIError, got %T: %v", err, err)
+	}
+	if len(subs) != 1 || subs["sub_1"] == nil {
+		t.Errorf("subs = %v", subs)
+	}
+}
+
+func TestRetrieveManyFallsBackToIndividualFetches(t *testing.T) {
+	var mu sync.Mutex
+	var paths []string
+	svc, srv := newTestService(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		paths = append(paths, r.URL.Path)
+		mu.Unlock()
+		switch r.URL.Path {
+		case "/subscriptions":
+			w.WriteHeader(404)
+			_, _ = w.Write([]byte(`{"error":{"code":"not_found","message":"Not Found"}}`))
+		case "/subscriptions/sub_missing":
+			w.WriteHeader(404)
+			_, _ = w.Write([]byte(`{"error":{"code":"not_found","message":"No such subscription"}}`))
+		default:
+			w.WriteHeader(200)
+			_, _ = fmt.Fprintf(w, `{"id":%q,"status":"active"}`, strings.TrimPrefix(r.URL.Path, "/subscriptions/"))
+		}
+	})
+	defer srv.Close()
+
+	ids := []string{"sub_1", "sub_missing", "sub_2", "sub_3", "sub_4", "sub_5"}
+	subs, err := svc.RetrieveMany(context.Background(), ids)
+	var nf *NotFoundError
+	if !errors.As(err, &nf) || !strings.Contains(err.Error(), "subscription sub_missing:") {
+		t.Errorf("err = %v", err)
+	}
+	if len(subs) != 5 || subs["sub_5"].ID != "sub_5" || subs["sub_missing"] != nil {
+		t.Errorf("subs = %v", subs)
+	}
+	if len(paths) != 1+len(ids) {
+		t.Errorf("requests = %v", paths)
+	}
+}
+
+func TestRetrieveManyErrors(t *testing.T) {
+	svc, srv := newTestService(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(500)
+		_, _ = w.Write([]byte(`{"error":{"code":"server_error","message":"boom"}}`))
+	})
+	defer srv.Close()
+
+	if _, err := svc.RetrieveMany(context.Background(), nil); err == nil || err.Error() != "ids are required" {
+		t.Errorf("nil ids: err = %v", err)
+	}
+	if _, err := svc.RetrieveMany(context.Background(), []string{"sub_1", " "}); err == nil || err.Error() != "subscriptionID is required" {
+		t.Errorf("blank id: err = %v", err)
+	}
+	subs, err := svc.RetrieveMany(context.Background(), []string{"sub_1"})
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) || subs != nil {
+		t.Errorf("RetrieveMany = %v, %v; want nil and the APIError", subs, err)
+	}
+}
+
+func TestPortalURL(t *testing.T) {
+	svc, srv := newTestService(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "POST" || r.URL.Path != "/subscription/user_1/portal" {
+			t.Errorf("request = %s %s", r.Method, r.URL.Path)
+		}
+		body, _ := io.ReadAll(r.Body)
+		if string(body) != `{"return_url":"https://app.example.com/billing"}` {
+			t.Errorf("body = %s", body)
+		}
+		w.WriteHeader(200)
+		_, _ = w.Write([]byte(`{"url":"https://billing.stripe.com/session/abc"}`))
+	})
+	defer srv.Close()
+
+	got, err := svc.PortalURL(context.Background(), "user_1", "https://app.example.com/billing")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != "https://billing.stripe.com/session/abc" {
+		t.Errorf("PortalURL = %q", got)
+	}
+}
+
+func TestPortalURLErrors(t *testing.T) {
+	status, body := 200, `{}`
+	svc, srv := newTestService(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(status)
+		_, _ = w.Write([]byte(body))
+	})
+	defer srv.Close()
+	ctx := context.Background()
+
+	if _, err := svc.PortalURL(ctx, "", "https://app.example.com"); err == nil || err.Error() != "userID is required" {
+		t.Errorf("empty userID: err = %v", err)
+	}
+	for _, returnURL := range []string{"", "/billing", "ftp://example.com", "https://", "http://[::1"} {
+		_, err := svc.PortalURL(ctx, "user_1", returnURL)
+		var invErr *InvalidRequestError
+		if !errors.As(err, &invErr) || invErr.Param != "return_url" {
+			t.Errorf("returnURL %q: err = %v", returnURL, err)
+		}
+	}
+
+	var apiErr *APIError
+	if _, err := svc.PortalURL(ctx, "user_1", "https://app.example.com"); !errors.As(err, &apiErr) {
+		t.Errorf("missing url: expected *APIError, got %T", err)
+	}
+	status, body = 404, `{"error":{"code":"not_found","message":"No subscription"}}`
+	var nf *NotFoundError
+	if _, err := svc.PortalURL(ctx, "user_1", "https://app.example.com"); !errors.As(err, &nf) {
+		t.Errorf("expected *NotFoundError, got %T", err)
+	}
+}
+
+func TestListAutoPagingFollowsCursors(t *testing.T) {
+	var queries []url.Values
+	svc, srv := newTestService(func(w http.ResponseWriter, r *http.Request) {
+		queries = append(queries, r.URL.Query())
+		w.WriteHeader(200)
+		if r.URL.Query().Get("cursor") == "" {
+			_, _ = w.Write([]byte(`{"items":[{"id":"sub_1"}],"page_size":1,"next_cursor":"cur_2"}`))
+			return
+		}
+		_, _ = w.Write([]byte(`{"items":[{"id":"sub_2"}],"page_size":1,"prev_cursor":"cur_1"}`))
+	})
+	defer srv.Close()
+
+	it := svc.ListAutoPaging(context.Background(), "user_1", &ListOptions{PageSize: 1})
+	var ids []string
+	for it.Next() {
+		ids = append(ids, it.Value().ID)
+	}
+	if err := it.Err(); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(ids, ",") != "sub_1,sub_2" {
+		t.Errorf("ids = %v", ids)
+	}
+	if len(queries) != 2 || queries[0].Has("cursor") || queries[1].Get("cursor") != "cur_2" {
+		t.Fatalf("queries = %v", queries)
+	}
+	if queries[0].Get("page") != "1" || queries[1].Has("page") {
+		t.Errorf("page should be sent only without a cursor: queries = %v", queries)
+	}
+}
+
+func TestPaginatedListCursorFields(t *testing.T) {
+	var list PaginatedList[SubscriptionHistoryItem]
+	if err := json.Unmarshal([]byte(`{"items":[],"next_cursor":"n","prev_cursor":"p"}`), &list); err != nil {
+		t.Fatal(err)
+	}
+	if list.NextCursor != "n" || list.PrevCursor != "p" || !list.HasMoreCursor() {
+		t.Errorf("list = %+v", list)
+	}
+	if (&PaginatedList[int]{}).HasMoreCursor() {
+		t.Error("HasMoreCursor should be false without a next cursor")
+	}
+}
+
+func TestExtendTrial(t *testing.T) {
+	tests := []struct {
+		name   string
+		params *ExtendTrialParams
+		body   string
+	}{
+		{"trial end", &ExtendTrialParams{TrialEnd: time.Date(2025, 3, 1, 12, 0, 0, 0, time.FixedZone("CET", 3600))}, `{"trial_end":"2025-03-01T11:00:00Z"}`},
+		{"trial days", &ExtendTrialParams{TrialDays: 14}, `{"trial_days":14}`},
+	}
+	for _, tt := range tests {
+		svc, srv := newTestService(func(w http.ResponseWriter, r *http.Request) {
+			if r.Method != "POST" || r.URL.Path != "/subscription/sub_1/extend-trial" {
+				t.Errorf("%s: request = %s %s", tt.name, r.Method, r.URL.Path)
+			}
+			body, _ := io.ReadAll(r.Body)
+			if string(body) != tt.body {
+				t.Errorf("%s: body = %s, want %s", tt.name, body, tt.body)
+			}
+			w.WriteHeader(200)
+			_, _ = w.Write([]byte(`{"id":"sub_1","status":"trialing","trial_end":"2025-03-01T11:00:00Z"}`))
+		})
+		sub, err := svc.ExtendTrial(context.Background(), "sub_1", tt.params)
+		srv.Close()
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if sub.ID != "sub_1" || sub.TrialEnd == nil {
+			t.Errorf("%s: subscription = %+v", tt.name, sub)
+		}
+	}
+}
+
+func TestExtendTrialValidation(t *testing.T) {
+	svc, srv := newTestService(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(404)
+		_, _ = w.Write([]byte(`{"error":{"code":"not_found","message":"No subscription"}}`))
+	})
+	defer srv.Close()
+	ctx := context.Background()
+
+	tests := []struct {
+		id     string
+		params *ExtendTrialParams
+		want   string
+	}{
+		{"", &ExtendTrialParams{TrialDays: 1}, "subscriptionID is required"},
+		{"sub_1", nil, "params are required"},
+		{"sub_1", &ExtendTrialParams{TrialEnd: time.Now(), TrialDays: 7}, "trialEnd and trialDays are mutually exclusive"},
+		{"sub_1", &ExtendTrialParams{}, "trialEnd or trialDays is required"},
+		{"sub_1", &ExtendTrialParams{TrialDays: -3}, "trialDays must be positive"},
+	}
+	for _, tt := range tests {
+		if _, err := svc.ExtendTrial(ctx, tt.id, tt.params); err == nil || err.Error() != tt.want {
+			t.Errorf("ExtendTrial(%q, %+v) err = %v, want %q", tt.id, tt.params, err, tt.want)
+		}
+	}
+	var nf *NotFoundError
+	if _, err := svc.ExtendTrial(ctx, "sub_1", &ExtendTrialParams{TrialDays: 7}); !errors.As(err, &nf) {
+		t.Errorf("expected *NotFoundError, got %T", err)
 	}
 }
@@ -0,0 +1,77 @@
+package paylio
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// UsageAction controls how a usage record's quantity is applied.
+type UsageAction string
+
+// Supported usage actions.
+const (
+	// UsageActionIncrement adds the quantity to the usage already reported
+	// for the current period.
+	UsageActionIncrement UsageAction = "increment"
+	// UsageActionSet replaces the usage reported for the current period.
+	UsageActionSet UsageAction = "set"
+)
+
+// UsageRecordParams describes a usage quantity to report for a metered
+// subscription.
+type UsageRecordParams struct {
+	// Quantity is the amount of usage. It must not be negative.
+	Quantity int64
+	// Timestamp is when the usage occurred. The API uses the time of receipt
+	// when it is zero.
+	Timestamp time.Time
+	// Action is UsageActionIncrement (the default) or UsageActionSet.
+	Action UsageAction
+}
+
+// UsageService provides methods for reporting metered usage.
+type UsageService struct {
+	http *httpClient
+}
+
+func newUsageService(hc *httpClient) *UsageService {
+	return &UsageService{http: hc}
+}
+
+// Create reports usage for a metered subscription.
+func (s *UsageService) Create(ctx context.Context, subscriptionID string, params *UsageRecordParams, opts ...RequestOption) (*UsageRecord, error) {
+	if strings.TrimSpace(subscriptionID) == "" {
+		return nil, errors.New("subscriptionID is required")
+	}
+	if params == nil {
+		return nil, errors.New("params are required")
+	}
+	if params.Quantity < 0 {
+		return nil, errors.New("quantity must not be negative")
+	}
+	action := params.Action
+	if action == "" {
+		action = UsageActionIncrement
+	}
+	if action != UsageActionIncrement && action != UsageActionSet {
+		return nil, NewInvalidRequestError(ErrorParams{
+			Message: fmt.Sprintf("unknown usage action %q", action),
+			Param:   "action",
+		})
+	}
+	body := map[string]any{
+		"quantity": params.Quantity,
+		"action":   string(action),
+	}
+	if !params.Timestamp.IsZero() {
+		body["timestamp"] = params.Timestamp.UTC().Format(time.RFC3339)
+	}
+	data, err := s.http.request(ctx, "POST", fmt.Sprintf("/subscription/%s/usage", subscriptionID), applyRequestOptions(&requestOptions{JSONBody: body}, opts))
+	if err != nil {
+		return nil, err
+	}
+	return decodeResource[UsageRecord](s.http, data)
+}
@@ -0,0 +1,127 @@
+package paylio
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func newTestUsageService(handler http.HandlerFunc) (*UsageService, *httptest.Server) {
+	srv := httptest.NewServer(handler)
+	hc := newHTTPClient("sk_test", srv.URL, 10*time.Second, srv.Client())
+	return newUsageService(hc), srv
+}
+
+func TestUsageCreateIncrement(t *testing.T) {
+	svc, srv := newTestUsageService(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "POST" {
+			t.Errorf("Method = %q", r.Method)
+		}
+		if r.URL.Path != "/subscription/sub_1/usage" {
+			t.Errorf("Path = %q", r.URL.Path)
+		}
+		body, _ := io.ReadAll(r.Body)
+		var parsed map[string]any
+		if err := json.Unmarshal(body, &parsed); err != nil {
+			t.Fatal(err)
+		}
+		if parsed["quantity"] != float64(42) {
+			t.Errorf("quantity = %v", parsed["quantity"])
+		}
+		if parsed["action"] != "increment" {
+			t.Errorf("action = %v", parsed["action"])
+		}
+		if parsed["timestamp"] != "2025-03-01T12:00:00Z" {
+			t.Errorf("timestamp = %v", parsed["timestamp"])
+		}
+		w.WriteHeader(200)
+		_, _ = w.Write([]byte(`{"id":"ur_1","quantity":42,"timestamp":"2025-03-01T12:00:00Z"}`))
+	})
+	defer srv.Close()
+
+	record, err := svc.Create(context.Background(), "sub_1", &UsageRecordParams{
+		Quantity:  42,
+		Timestamp: time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if record.ID != "ur_1" || record.Quantity != 42 || record.Timestamp != "2025-03-01T12:00:00Z" {
+		t.Errorf("record = %+v", record)
+	}
+}
+
+func TestUsageCreateSet(t *testing.T) {
+	svc, srv := newTestUsageService(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		var parsed map[string]any
+		if err := json.Unmarshal(body, &parsed); err != nil {
+			t.Fatal(err)
+		}
+		if parsed["action"] != "set" {
+			t.Errorf("action = %v", parsed["action"])
+		}
+		if parsed["quantity"] != float64(0) {
+			t.Errorf("quantity = %v", parsed["quantity"])
+		}
+		if _, ok := parsed["timestamp"]; ok {
+			t.Errorf("timestamp should be omitted, body = %v", parsed)
+		}
+		w.WriteHeader(200)
+		_, _ = w.Write([]byte(`{"id":"ur_2","quantity":0}`))
+	})
+	defer srv.Close()
+
+	record, err := svc.Create(context.Background(), "sub_1", &UsageRecordParams{Action: UsageActionSet})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if record.ID != "ur_2" {
+		t.Errorf("ID = %q", record.ID)
+	}
+}
+
+func TestUsageCreateValidation(t *testing.T) {
+	svc, srv := newTestUsageService(func(http.ResponseWriter, *http.Request) {
+		t.Error("request should not be sent")
+	})
+	defer srv.Close()
+
+	ctx := context.Background()
+	if _, err := svc.Create(ctx, " ", &UsageRecordParams{Quantity: 1}); err == nil || err.Error() != "subscriptionID is required" {
+		t.Errorf("empty ID: err = %v", err)
+	}
+	if _, err := svc.Create(ctx, "sub_1", nil); err == nil || err.Error() != "params are required" {
+		t.Errorf("nil params: err = %v", err)
+	}
+	if _, err := svc.Create(ctx, "sub_1", &UsageRecordParams{Quantity: -1}); err == nil || err.Error() != "quantity must not be negative" {
+		t.Errorf("negative quantity: err = %v", err)
+	}
+	_, err := svc.Create(ctx, "sub_1", &UsageRecordParams{Quantity: 1, Action: "decrement"})
+	var invalidErr *InvalidRequestError
+	if !errors.As(err, &invalidErr) {
+		t.Fatalf("expected *InvalidRequestError, got %T: %v", err, err)
+	}
+	if invalidErr.Param != "action" {
+		t.Errorf("Param = %q", invalidErr.Param)
+	}
+}
+
+func TestUsageCreateAPIError(t *testing.T) {
+	svc, srv := newTestUsageService(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(404)
+		_, _ = w.Write([]byte(`{"error":{"code":"not_found","message":"No such subscription"}}`))
+	})
+	defer srv.Close()
+
+	_, err := svc.Create(context.Background(), "sub_missing", &UsageRecordParams{Quantity: 1})
+	var nf *NotFoundError
+	if !errors.As(err, &nf) {
+		t.Fatalf("expected *NotFoundError, got %T: %v", err, err)
+	}
+}
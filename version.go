@@ -1,4 +1,17 @@
 package paylio
 
+import "runtime"
+
 // Version is the current version of the paylio-go SDK.
 const Version = "0.1.3"
+
+// UserAgent returns the User-Agent header the SDK sends with every request.
+func UserAgent() string {
+	return "paylio-go/" + Version
+}
+
+// BuildInfo returns the SDK version and the Go runtime version it was built
+// with, for inclusion in logs and support requests.
+func BuildInfo() (version, goVersion string) {
+	return Version, runtime.Version()
+}
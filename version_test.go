@@ -1,9 +1,48 @@
 package paylio
 
-import "testing"
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"runtime"
+	"testing"
+	"time"
+)
 
 func TestVersion(t *testing.T) {
 	if Version != "0.1.3" {
 		t.Errorf("expected version 0.1.2, got %s", Version)
 	}
 }
+
+func TestUserAgentMatchesSentHeader(t *testing.T) {
+	if UserAgent() != "paylio-go/"+Version {
+		t.Errorf("UserAgent() = %q", UserAgent())
+	}
+
+	var got string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r.Header.Get("User-Agent")
+		w.WriteHeader(200)
+		_, _ = w.Write([]byte(`{}`))
+	}))
+	defer srv.Close()
+
+	hc := newHTTPClient("sk_test", srv.URL, 10*time.Second, srv.Client())
+	if _, err := hc.request(context.Background(), "GET", "/ua", nil); err != nil {
+		t.Fatal(err)
+	}
+	if got != UserAgent() {
+		t.Errorf("User-Agent header = %q, want %q", got, UserAgent())
+	}
+}
+
+func TestBuildInfo(t *testing.T) {
+	version, goVersion := BuildInfo()
+	if version != Version {
+		t.Errorf("version = %q", version)
+	}
+	if goVersion != runtime.Version() {
+		t.Errorf("goVersion = %q", goVersion)
+	}
+}